@@ -1,12 +1,22 @@
 package main
 
 import (
+	"context"
 	"log"
 	"music-sharing/music-microservice/internal/app"
 	"music-sharing/music-microservice/internal/app/middlewares"
+	"music-sharing/music-microservice/internal/app/subscribers"
+	"music-sharing/music-microservice/internal/database"
+	"music-sharing/music-microservice/internal/events"
 	"music-sharing/music-microservice/internal/lib"
+	"music-sharing/shared/idempotency"
+	"music-sharing/shared/logging"
+	"music-sharing/shared/openapi"
+	"music-sharing/shared/permissions"
+	"music-sharing/shared/rest"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -28,20 +38,47 @@ func main() {
 		log.Fatal(err)
 	}
 
-	router := gin.Default()
+	subscribers.Register(events.GetBroker())
+	events.NewRelay(events.GetBroker(), 2*time.Second).Start(context.Background())
+	app.NewTakedownWorker(time.Minute).Start(context.Background())
+	app.NewReleaseWorker(15 * time.Second).Start(context.Background())
+
+	err = database.EnsureIndexes()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = database.MoveStrayMusicFields()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// the access log of gin.Default would write the secrets of the queries
+	router := gin.New()
+	router.Use(logging.Logger(), gin.Recovery())
+
+	if err := router.SetTrustedProxies(rest.TrustedProxies()); err != nil {
+		log.Fatal(err)
+	}
 	controller := app.MusicsController{}
+	internalController := app.InternalController{}
+	moderationController := app.ModerationController{}
+	takedownController := app.TakedownController{}
+	commentController := app.CommentController{}
+	repostController := app.RepostController{}
+	shareLinkController := app.ShareLinkController{}
+	discoveryController := app.DiscoveryController{}
 
-	router.Use(middlewares.AuthMiddleware)
-	router.Use(middlewares.ErrorHandlerMiddleware)
+	// registered before the global middleware so these routes authenticate
+	// with the internal api key instead of a user token
+	internal := router.Group("/internal", middlewares.ErrorHandlerMiddleware, middlewares.InternalMiddleware)
+	internal.POST("/users/:userId/purge", internalController.PurgeUserData)
+	internal.GET("/users/:userId/export", internalController.ExportUserData)
 
-	router.GET("/getMusicById/:music_id", controller.GetMusicById)
-	router.GET("/getMusics", controller.GetMusics)
-	router.POST("/likeMusic/:music_id", controller.LikeMusic)
-	router.POST("/unlikeMusic/:music_id", controller.UnlikeMusic)
-	router.POST("/retrieveMusicsByIds", controller.RetrieveMusicsByIds)
-	router.POST("/uploadMusic", controller.UploadMusic)
-	router.POST("/updateMusicMetadata/:ownerId/:musicId", middlewares.IsOwnerMiddleware, controller.UpdateMusicMetadata)
-	router.POST("/changeMusicPoster/:ownerId/:musicId", middlewares.IsOwnerMiddleware, controller.ChangeMusicPoster)
+	router.Use(middlewares.ErrorHandlerMiddleware)
+	router.Use(middlewares.IdempotencyMiddleware)
 
 	router.GET("/", func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -49,6 +86,66 @@ func main() {
 		})
 	})
 
+	spec := openapi.NewSpec("music-microservice", "1.0.0")
+	router.GET("/openapi.json", spec.Serve)
+
+	// the routes before v1 keep working as deprecated aliases of the v1 ones
+	v1 := router.Group("/v1")
+	musicId := map[string]string{"id": "music_id"}
+	ownedMusicId := map[string]string{"id": "musicId"}
+	commentId := map[string]string{"id": "comment_id"}
+	takedownId := map[string]string{"id": "takedownId"}
+
+	// anonymous callers can read the public tracks, rate limited per ip. The
+	// track routes also accept the ?token= of a share link
+	public := spec.Router(
+		v1.Group("", middlewares.OptionalAuthMiddleware, middlewares.AnonymousRateLimit(time.Minute)),
+		router.Group("/", middlewares.OptionalAuthMiddleware, middlewares.AnonymousRateLimit(time.Minute)),
+	)
+	public.Handle(openapi.Operation{Method: "GET", Path: "/musics", Summary: "Search the musics", Tag: "musics", Query: []string{"q", "limit", "cursor"}, Legacy: "GET /getMusics"}, controller.GetMusics)
+	public.Handle(openapi.Operation{Method: "POST", Path: "/musics/batch", Summary: "Musics with the given ids", Tag: "musics", Body: app.RetrieveMusicsByIdsRequest{}, Legacy: "POST /retrieveMusicsByIds"}, controller.RetrieveMusicsByIds)
+	public.Handle(openapi.Operation{Method: "GET", Path: "/musics/:id", Summary: "A music", Tag: "musics", Query: []string{"share", "token"}, Params: musicId, Legacy: "GET /getMusicById/:music_id"}, controller.GetMusicById)
+	public.Handle(openapi.Operation{Method: "GET", Path: "/musics/:id/stream", Summary: "Stream a music", Tag: "musics", Query: []string{"share", "token"}, Params: musicId, Legacy: "GET /streamMusic/:music_id"}, controller.StreamMusic)
+	public.Handle(openapi.Operation{Method: "GET", Path: "/musics/:id/comments", Summary: "Comments of a music", Tag: "comments", Query: []string{"cursor", "limit"}, Params: musicId, Legacy: "GET /getComments/:music_id"}, commentController.GetComments)
+	public.Handle(openapi.Operation{Method: "GET", Path: "/comments/:id/replies", Summary: "Replies to a comment", Tag: "comments", Query: []string{"cursor", "limit"}, Params: commentId, Legacy: "GET /getReplies/:comment_id"}, commentController.GetReplies)
+	public.Handle(openapi.Operation{Method: "GET", Path: "/users/:id/reposts", Summary: "Reposts of a user", Tag: "reposts", Query: []string{"cursor", "limit"}, Params: map[string]string{"id": "userId"}, Legacy: "GET /getReposts/:userId"}, repostController.GetReposts)
+	public.Handle(openapi.Operation{Method: "GET", Path: "/charts", Summary: "Most played musics of a period", Tag: "discovery", Query: []string{"period", "limit"}, Legacy: "GET /charts"}, discoveryController.GetCharts)
+	public.Handle(openapi.Operation{Method: "GET", Path: "/artists/:id", Summary: "Page of an artist", Tag: "discovery", Params: map[string]string{"id": "artistId"}, Legacy: "GET /artists/:artistId"}, discoveryController.GetArtistPage)
+
+	authed := spec.Router(v1.Group("", middlewares.AuthMiddleware), router.Group("/", middlewares.AuthMiddleware))
+	authed.Handle(openapi.Operation{Method: "POST", Path: "/musics", Summary: "Upload a music", Tag: "musics", Auth: true, Form: app.UploadMusicReq{}, Legacy: "POST /uploadMusic"}, middlewares.RequirePermission(permissions.UploadTracks), controller.UploadMusic)
+	authed.Handle(openapi.Operation{Method: "PATCH", Path: "/musics/:id", Summary: "Update the metadata of an own music", Tag: "musics", Auth: true, Body: app.UpdateMusicMetadataReq{}, Versioned: true, Params: ownedMusicId, Legacy: "POST /updateMusicMetadata/:ownerId/:musicId"}, middlewares.IsOwnerMiddleware, controller.UpdateMusicMetadata)
+	authed.Handle(openapi.Operation{Method: "DELETE", Path: "/musics/:id", Summary: "Delete an own music", Tag: "musics", Auth: true, Params: ownedMusicId, Legacy: "POST /deleteMusic/:ownerId/:musicId"}, middlewares.IsOwnerMiddleware, controller.DeleteMusic)
+	authed.Handle(openapi.Operation{Method: "PUT", Path: "/musics/:id/visibility", Summary: "Change the visibility of an own music", Tag: "musics", Auth: true, Body: app.ChangeMusicVisibilityReq{}, Params: ownedMusicId, Legacy: "POST /changeMusicVisibility/:ownerId/:musicId"}, middlewares.IsOwnerMiddleware, controller.ChangeMusicVisibility)
+	authed.Handle(openapi.Operation{Method: "PUT", Path: "/musics/:id/release", Summary: "Reschedule the release of an own music", Tag: "musics", Auth: true, Body: app.ScheduleMusicReleaseReq{}, Params: ownedMusicId, Legacy: "POST /scheduleMusicRelease/:ownerId/:musicId"}, middlewares.IsOwnerMiddleware, controller.ScheduleMusicRelease)
+	authed.Handle(openapi.Operation{Method: "PUT", Path: "/musics/:id/poster", Summary: "Change the poster of an own music", Tag: "musics", Auth: true, Form: app.ChangeMusicPosterForm{}, Params: ownedMusicId, Legacy: "POST /changeMusicPoster/:ownerId/:musicId"}, middlewares.IsOwnerMiddleware, controller.ChangeMusicPoster)
+	authed.Handle(openapi.Operation{Method: "GET", Path: "/musics/:id/unlistedLink", Summary: "Link of an own unlisted music", Tag: "musics", Auth: true, Params: ownedMusicId, Legacy: "GET /musicShareLink/:ownerId/:musicId"}, middlewares.IsOwnerMiddleware, controller.GetMusicShareLink)
+	authed.Handle(openapi.Operation{Method: "PUT", Path: "/musics/:id/like", Summary: "Like a music", Tag: "musics", Auth: true, Params: musicId, Legacy: "POST /likeMusic/:music_id"}, controller.LikeMusic)
+	authed.Handle(openapi.Operation{Method: "DELETE", Path: "/musics/:id/like", Summary: "Unlike a music", Tag: "musics", Auth: true, Params: musicId, Legacy: "POST /unlikeMusic/:music_id"}, controller.UnlikeMusic)
+	authed.Handle(openapi.Operation{Method: "POST", Path: "/musics/:id/shareLinks", Summary: "Create a share link to an own music", Tag: "shareLinks", Auth: true, Body: app.CreateShareLinkReq{}, Params: ownedMusicId, Legacy: "POST /shareLinks/:ownerId/:musicId"}, middlewares.IsOwnerMiddleware, idempotency.Withhold, shareLinkController.CreateShareLink)
+	authed.Handle(openapi.Operation{Method: "GET", Path: "/musics/:id/shareLinks", Summary: "Share links to an own music", Tag: "shareLinks", Auth: true, Params: ownedMusicId, Legacy: "GET /shareLinks/:ownerId/:musicId"}, middlewares.IsOwnerMiddleware, shareLinkController.GetShareLinks)
+	authed.Handle(openapi.Operation{Method: "DELETE", Path: "/shareLinks/:id", Summary: "Revoke an own share link", Tag: "shareLinks", Auth: true, Params: map[string]string{"id": "linkId"}, Legacy: "POST /revokeShareLink/:ownerId/:linkId"}, middlewares.IsOwnerMiddleware, shareLinkController.RevokeShareLink)
+	authed.Handle(openapi.Operation{Method: "POST", Path: "/musics/:id/comments", Summary: "Comment a music", Tag: "comments", Auth: true, Body: app.CommentMusicReq{}, Params: musicId, Legacy: "POST /commentMusic/:music_id"}, commentController.CommentMusic)
+	authed.Handle(openapi.Operation{Method: "PATCH", Path: "/comments/:id", Summary: "Edit an own comment", Tag: "comments", Auth: true, Body: app.EditCommentReq{}, Params: commentId, Legacy: "POST /editComment/:comment_id"}, commentController.EditComment)
+	authed.Handle(openapi.Operation{Method: "DELETE", Path: "/comments/:id", Summary: "Delete a comment", Tag: "comments", Auth: true, Params: commentId, Legacy: "POST /deleteComment/:comment_id"}, commentController.DeleteComment)
+	authed.Handle(openapi.Operation{Method: "PUT", Path: "/musics/:id/repost", Summary: "Repost a music", Tag: "reposts", Auth: true, Body: app.RepostMusicReq{}, Params: musicId, Legacy: "POST /repostMusic/:music_id"}, repostController.RepostMusic)
+	authed.Handle(openapi.Operation{Method: "DELETE", Path: "/musics/:id/repost", Summary: "Undo a repost", Tag: "reposts", Auth: true, Params: musicId, Legacy: "POST /unrepostMusic/:music_id"}, repostController.UnrepostMusic)
+	authed.Handle(openapi.Operation{Method: "POST", Path: "/musics/:id/reports", Summary: "Report a music", Tag: "moderation", Auth: true, Body: app.ReportMusicReq{}, Params: musicId, Legacy: "POST /reportMusic/:music_id"}, moderationController.ReportMusic)
+	authed.Handle(openapi.Operation{Method: "PUT", Path: "/musics/:id/hidden", Summary: "Hide a music", Tag: "moderation", Auth: true, Body: app.HideMusicReq{}, Params: ownedMusicId, Legacy: "POST /hideMusic/:musicId"}, middlewares.RequirePermission(permissions.ModerateTracks), moderationController.HideMusic)
+	authed.Handle(openapi.Operation{Method: "DELETE", Path: "/musics/:id/hidden", Summary: "Unhide a music", Tag: "moderation", Auth: true, Params: ownedMusicId, Legacy: "POST /unhideMusic/:musicId"}, middlewares.RequirePermission(permissions.ModerateTracks), moderationController.UnhideMusic)
+	authed.Handle(openapi.Operation{Method: "GET", Path: "/moderationCases", Summary: "Queue of the moderation cases", Tag: "moderation", Auth: true, Query: []string{"status"}, Legacy: "GET /moderationQueue"}, middlewares.RequirePermission(permissions.ModerateTracks), moderationController.GetModerationQueue)
+	authed.Handle(openapi.Operation{Method: "GET", Path: "/moderationCases/:id", Summary: "A moderation case", Tag: "moderation", Auth: true, Params: map[string]string{"id": "caseId"}, Legacy: "GET /moderationQueue/:caseId"}, middlewares.RequirePermission(permissions.ModerateTracks), moderationController.GetModerationCase)
+	authed.Handle(openapi.Operation{Method: "POST", Path: "/moderationCases/:id/resolution", Summary: "Resolve a moderation case", Tag: "moderation", Auth: true, Body: app.ResolveModerationCaseReq{}, Params: map[string]string{"id": "caseId"}, Legacy: "POST /moderationQueue/:caseId/resolve"}, middlewares.RequirePermission(permissions.ModerateTracks), moderationController.ResolveModerationCase)
+	authed.Handle(openapi.Operation{Method: "POST", Path: "/takedowns", Summary: "Submit a takedown notice", Tag: "takedowns", Auth: true, Body: app.SubmitTakedownReq{}, Legacy: "POST /takedowns"}, takedownController.SubmitTakedown)
+	authed.Handle(openapi.Operation{Method: "GET", Path: "/takedowns", Summary: "Takedown notices", Tag: "takedowns", Auth: true, Query: []string{"status"}, Legacy: "GET /takedowns"}, middlewares.RequirePermission(permissions.ModerateTracks), takedownController.GetTakedowns)
+	authed.Handle(openapi.Operation{Method: "GET", Path: "/me/takedowns", Summary: "Takedown notices against the musics of the caller", Tag: "takedowns", Auth: true, Legacy: "GET /myTakedowns"}, takedownController.GetMyTakedowns)
+	authed.Handle(openapi.Operation{Method: "GET", Path: "/takedowns/:id", Summary: "A takedown notice", Tag: "takedowns", Auth: true, Params: takedownId, Legacy: "GET /takedowns/:takedownId"}, takedownController.GetTakedown)
+	authed.Handle(openapi.Operation{Method: "POST", Path: "/takedowns/:id/verification", Summary: "Verify a takedown notice", Tag: "takedowns", Auth: true, Params: takedownId, Legacy: "POST /takedowns/:takedownId/verify"}, middlewares.RequirePermission(permissions.ModerateTracks), takedownController.VerifyTakedown)
+	authed.Handle(openapi.Operation{Method: "POST", Path: "/takedowns/:id/rejection", Summary: "Reject a takedown notice", Tag: "takedowns", Auth: true, Body: app.RejectTakedownReq{}, Params: takedownId, Legacy: "POST /takedowns/:takedownId/reject"}, middlewares.RequirePermission(permissions.ModerateTracks), takedownController.RejectTakedown)
+	authed.Handle(openapi.Operation{Method: "POST", Path: "/takedowns/:id/action", Summary: "Take the musics of a takedown notice down", Tag: "takedowns", Auth: true, Params: takedownId, Legacy: "POST /takedowns/:takedownId/action"}, middlewares.RequirePermission(permissions.ModerateTracks), takedownController.ActionTakedown)
+	authed.Handle(openapi.Operation{Method: "POST", Path: "/takedowns/:id/counterNotice", Summary: "File a counter notice", Tag: "takedowns", Auth: true, Body: app.CounterNoticeReq{}, Params: takedownId, Legacy: "POST /takedowns/:takedownId/counterNotice"}, takedownController.FileCounterNotice)
+	authed.Handle(openapi.Operation{Method: "POST", Path: "/takedowns/:id/courtAction", Summary: "Record the court action of a counter notice", Tag: "takedowns", Auth: true, Params: takedownId, Legacy: "POST /takedowns/:takedownId/courtAction"}, middlewares.RequirePermission(permissions.ModerateTracks), takedownController.RecordCourtAction)
+
 	router.Run(port)
 
 }
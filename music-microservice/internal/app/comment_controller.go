@@ -0,0 +1,419 @@
+package app
+
+import (
+	"context"
+	"music-sharing/music-microservice/internal/app/models"
+	"music-sharing/music-microservice/internal/database"
+	"music-sharing/music-microservice/internal/events"
+	"music-sharing/shared/apperrors"
+	"music-sharing/shared/permissions"
+	"music-sharing/shared/validation"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type (
+	CommentController struct{}
+
+	CommentMusicReq struct {
+		Body       string `json:"body" validate:"required,max=2000"`
+		ParentID   string `json:"parentId"`
+		PositionMs *int   `json:"positionMs" validate:"omitempty,min=0"`
+	}
+
+	EditCommentReq struct {
+		Body string `json:"body" validate:"required,max=2000"`
+	}
+
+	// NextCursor is empty when there are no more comments
+	CommentsPage struct {
+		Comments   []models.Comment `json:"comments"`
+		NextCursor string           `json:"nextCursor"`
+	}
+)
+
+const (
+	defaultCommentsLimit = 20
+	maxCommentsLimit     = 100
+)
+
+var commentsCollection *mongo.Collection = database.OpenCollection("comments")
+
+func (ctrl *CommentController) CommentMusic(c *gin.Context) {
+	req := CommentMusicReq{}
+	authorId := viewerIdOf(c)
+
+	id, err := primitive.ObjectIDFromHex(c.Param("music_id"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := validation.Struct(req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	viewer, err := viewerOf(c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	var music models.Music
+
+	visible, err := visibleFilter(context.TODO(), viewer, bson.M{"_id": id})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := musicsCollection.FindOne(context.TODO(), visible).Decode(&music); err != nil {
+		c.Error(err)
+		return
+	}
+
+	comment := models.Comment{
+		ID:         primitive.NewObjectID(),
+		MusicID:    music.ID,
+		AuthorID:   authorId,
+		Body:       req.Body,
+		PositionMs: req.PositionMs,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	var parent *models.Comment
+
+	if len(req.ParentID) != 0 {
+		parentId, err := primitive.ObjectIDFromHex(req.ParentID)
+
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		parent = &models.Comment{}
+
+		err = commentsCollection.FindOne(context.TODO(), commentsVisibleTo(viewer, bson.M{"_id": parentId, "musicId": music.ID, "deleted": false})).Decode(parent)
+
+		if err == mongo.ErrNoDocuments {
+			c.Error(apperrors.NotFound("comment doesnt exist"))
+			return
+		}
+
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		threadId := parent.ID
+
+		if parent.ThreadID != nil {
+			threadId = *parent.ThreadID
+		}
+
+		comment.ParentID = &parent.ID
+		comment.ThreadID = &threadId
+	}
+
+	err = database.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+		if _, err := commentsCollection.InsertOne(sessCtx, comment); err != nil {
+			return err
+		}
+
+		if _, err := musicsCollection.UpdateOne(sessCtx, bson.M{"_id": music.ID}, bson.M{"$inc": bson.M{"commentCount": 1}}); err != nil {
+			return err
+		}
+
+		event := &events.MusicCommented{
+			CommentID: comment.ID.Hex(),
+			MusicID:   music.ID.Hex(),
+			ArtistID:  music.ArtistID,
+			AuthorID:  authorId,
+		}
+
+		if parent != nil {
+			if _, err := commentsCollection.UpdateOne(sessCtx, bson.M{"_id": parent.ID}, bson.M{"$inc": bson.M{"replyCount": 1}}); err != nil {
+				return err
+			}
+
+			event.ParentAuthorID = parent.AuthorID
+		}
+
+		return events.Record(sessCtx, event)
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, comment)
+}
+
+// Top level comments of a track, newest first
+func (ctrl *CommentController) GetComments(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("music_id"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	viewer, err := viewerOf(c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	visible, err := visibleFilter(context.TODO(), viewer, bson.M{"_id": id})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	count, err := musicsCollection.CountDocuments(context.TODO(), visible)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if count == 0 {
+		c.Error(apperrors.NotFound("music doesnt exist"))
+		return
+	}
+
+	findComments(c, viewer, bson.M{"musicId": id, "parentId": nil}, -1)
+}
+
+// Every reply of a thread, oldest first
+func (ctrl *CommentController) GetReplies(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("comment_id"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	viewer, err := viewerOf(c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	var thread models.Comment
+
+	if err := commentsCollection.FindOne(context.TODO(), bson.M{"_id": id, "parentId": nil}).Decode(&thread); err != nil {
+		c.Error(err)
+		return
+	}
+
+	visible, err := visibleFilter(context.TODO(), viewer, bson.M{"_id": thread.MusicID})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	count, err := musicsCollection.CountDocuments(context.TODO(), visible)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if count == 0 {
+		c.Error(apperrors.NotFound("comment doesnt exist"))
+		return
+	}
+
+	findComments(c, viewer, bson.M{"threadId": id}, 1)
+}
+
+// Pages through the comments matching filter by id, the cursor is the id of
+// the last comment of the previous page
+func findComments(c *gin.Context, v *viewer, filter bson.M, order int) {
+	ctx := context.TODO()
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	if limit < 1 {
+		limit = defaultCommentsLimit
+	}
+
+	if limit > maxCommentsLimit {
+		limit = maxCommentsLimit
+	}
+
+	if cursor := c.Query("cursor"); len(cursor) != 0 {
+		cursorId, err := primitive.ObjectIDFromHex(cursor)
+
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		if order < 0 {
+			filter["_id"] = bson.M{"$lt": cursorId}
+		} else {
+			filter["_id"] = bson.M{"$gt": cursorId}
+		}
+	}
+
+	opts := options.Find().SetSort(bson.M{"_id": order}).SetLimit(int64(limit + 1))
+
+	result, err := commentsCollection.Find(ctx, commentsVisibleTo(v, filter), opts)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	page := CommentsPage{Comments: []models.Comment{}}
+
+	if err := result.All(ctx, &page.Comments); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if len(page.Comments) > limit {
+		page.Comments = page.Comments[:limit]
+		page.NextCursor = page.Comments[limit-1].ID.Hex()
+	}
+
+	c.JSON(200, page)
+}
+
+func (ctrl *CommentController) EditComment(c *gin.Context) {
+	req := EditCommentReq{}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("comment_id"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := validation.Struct(req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	res, err := commentsCollection.UpdateOne(context.TODO(),
+		bson.M{"_id": id, "authorId": viewerIdOf(c), "deleted": false},
+		bson.M{"$set": bson.M{"body": req.Body, "editedAt": time.Now().UTC()}},
+	)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if res.MatchedCount == 0 {
+		c.Error(apperrors.NotFound("comment doesnt exist"))
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+// Comments can be deleted by their author, by the owner of the track and by
+// moderators
+func (ctrl *CommentController) DeleteComment(c *gin.Context) {
+	identity := identityOf(c)
+
+	id, err := primitive.ObjectIDFromHex(c.Param("comment_id"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = database.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+		var comment models.Comment
+
+		err := commentsCollection.FindOne(sessCtx, bson.M{"_id": id, "deleted": false}).Decode(&comment)
+
+		if err == mongo.ErrNoDocuments {
+			return apperrors.NotFound("comment doesnt exist")
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if comment.AuthorID != identity.ID && !identity.Can(permissions.ModerateTracks) {
+			count, err := musicsCollection.CountDocuments(sessCtx, bson.M{"_id": comment.MusicID, "artistId": identity.ID})
+
+			if err != nil {
+				return err
+			}
+
+			if count == 0 {
+				return apperrors.Forbidden("you are not authorized")
+			}
+		}
+
+		return deleteComment(sessCtx, &comment)
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+// Blanks the comment when it has replies and removes it otherwise
+func deleteComment(sessCtx mongo.SessionContext, comment *models.Comment) error {
+	if comment.ReplyCount > 0 {
+		_, err := commentsCollection.UpdateOne(sessCtx, bson.M{"_id": comment.ID}, bson.M{"$set": bson.M{"deleted": true, "body": ""}})
+
+		if err != nil {
+			return err
+		}
+	} else {
+		if _, err := commentsCollection.DeleteOne(sessCtx, bson.M{"_id": comment.ID}); err != nil {
+			return err
+		}
+
+		if comment.ParentID != nil {
+			_, err := commentsCollection.UpdateOne(sessCtx, bson.M{"_id": *comment.ParentID}, bson.M{"$inc": bson.M{"replyCount": -1}})
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := musicsCollection.UpdateOne(sessCtx, bson.M{"_id": comment.MusicID}, bson.M{"$inc": bson.M{"commentCount": -1}})
+
+	return err
+}
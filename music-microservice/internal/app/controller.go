@@ -2,56 +2,154 @@ package app
 
 import (
 	"context"
-	"errors"
 	"mime/multipart"
 	"music-sharing/music-microservice/internal/app/models"
 	"music-sharing/music-microservice/internal/database"
+	"music-sharing/music-microservice/internal/events"
+	"music-sharing/music-microservice/internal/lib"
+	"music-sharing/shared/apperrors"
+	"music-sharing/shared/rest"
+	"music-sharing/shared/validation"
 	"os"
+	"regexp"
+	"strconv"
+	"time"
 
 	"github.com/cloudinary/cloudinary-go/v2"
 	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
 	"github.com/gin-gonic/gin"
-	"github.com/go-playground/validator/v10"
-	"github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type (
 	MusicsController struct{}
 
 	UploadMusicReq struct {
-		Title     string                `json:"title" validate:"required"`
-		ShortDesc string                `json:"shortDesc" validate:"required"`
-		File      *multipart.FileHeader `json:"file"`
+		Title      string                `json:"title" validate:"required"`
+		ShortDesc  string                `json:"shortDesc" validate:"required"`
+		Visibility string                `json:"visibility" validate:"omitempty,oneof=public unlisted private followers"`
+		ReleaseAt  *time.Time            `json:"releaseAt"`
+		File       *multipart.FileHeader `json:"file"`
 	}
 
+	ScheduleMusicReleaseReq struct {
+		ReleaseAt time.Time `json:"releaseAt" validate:"required"`
+	}
+
+	ChangeMusicVisibilityReq struct {
+		Visibility string `json:"visibility" validate:"required,oneof=public unlisted private followers"`
+	}
+
+	// a JSON Merge Patch, the members left out are not changed
 	UpdateMusicMetadataReq struct {
-		Title     string `json:"title"`
-		ShortDesc string `json:"shortDesc"`
+		Title     *string `json:"title" validate:"omitempty,min=1"`
+		ShortDesc *string `json:"shortDesc"`
 	}
 
 	RetrieveMusicsByIdsRequest struct {
 		MusicsIds []string `json:"musicsIds"`
 	}
+
+	ChangeMusicPosterForm struct {
+		Poster *multipart.FileHeader `json:"poster"`
+	}
 )
 
-var musicsCollection *mongo.Collection = database.OpenCollection("musics")
+var (
+	musicsCollection  *mongo.Collection = database.OpenCollection("musics")
+	likesCollection   *mongo.Collection = database.OpenCollection("likes")
+	listensCollection *mongo.Collection = database.OpenCollection("listens")
+)
+
+const (
+	defaultMusicsLimit = 50
+	maxMusicsLimit     = 100
+)
 
+// Searches the musics, newest first. The array of a page is kept for the
+// clients made before the pagination, the next page is linked in the Link
+// header
 func (ctrl *MusicsController) GetMusics(c *gin.Context) {
 	ctx := context.TODO()
+	filter := bson.M{}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	if limit < 1 {
+		limit = defaultMusicsLimit
+	}
+
+	if limit > maxMusicsLimit {
+		limit = maxMusicsLimit
+	}
+
+	if cursor := c.Query("cursor"); len(cursor) != 0 {
+		cursorId, err := primitive.ObjectIDFromHex(cursor)
+
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		filter["_id"] = bson.M{"$lt": cursorId}
+	}
+
+	// plain text search on the title and the description
+	if q := c.Query("q"); len(q) != 0 {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(q), Options: "i"}
+		filter["$or"] = bson.A{
+			bson.M{"title": pattern},
+			bson.M{"shortdesc": pattern},
+		}
+	}
 
-	result, err := musicsCollection.Find(ctx, bson.M{})
+	viewer, err := viewerOf(c)
 
 	if err != nil {
 		c.Error(err)
+		return
+	}
+
+	opts := options.Find().SetSort(bson.M{"_id": -1}).SetLimit(int64(limit + 1))
+
+	visible, err := visibleFilter(ctx, viewer, filter)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	result, err := musicsCollection.Find(ctx, visible, opts)
+
+	if err != nil {
+		c.Error(err)
+		return
 	}
 
 	musics := []bson.M{}
 
 	if err := result.All(ctx, &musics); err != nil {
 		c.Error(err)
+		return
+	}
+
+	if len(musics) > limit {
+		musics = musics[:limit]
+		next := *c.Request.URL
+		query := next.Query()
+		query.Set("cursor", musics[limit-1]["_id"].(primitive.ObjectID).Hex())
+		query.Set("limit", strconv.Itoa(limit))
+		next.RawQuery = query.Encode()
+
+		c.Header("Link", "<"+next.RequestURI()+">; rel=\"next\"")
+	}
+
+	for _, music := range musics {
+		if id, _ := music["_id"].(primitive.ObjectID); !viewer.CanReadFileOf(id) {
+			delete(music, "fileurl")
+		}
 	}
 
 	c.JSON(200, musics)
@@ -64,40 +162,177 @@ func (ctrl *MusicsController) GetMusicById(c *gin.Context) {
 
 	if err != nil {
 		c.Error(err)
+		return
 	}
 
-	err = musicsCollection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&music)
+	viewer, err := viewerOf(c)
 
 	if err != nil {
 		c.Error(err)
+		return
 	}
 
+	visible, err := visibleFilter(context.TODO(), viewer, bson.M{"_id": id})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = musicsCollection.FindOne(context.TODO(), visible).Decode(&music)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if !viewer.CanReadFileOf(music.ID) {
+		music.FileUrl = ""
+	}
+
+	c.Header("ETag", rest.ETag(music.Version))
 	c.JSON(200, music)
 }
 
+// Records the listen in the user's history and redirects to the audio file
+func (ctrl *MusicsController) StreamMusic(c *gin.Context) {
+	musicId := c.Param("music_id")
+	var music models.Music
+	id, err := primitive.ObjectIDFromHex(musicId)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	viewer, err := viewerOf(c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	visible, err := visibleFilter(context.TODO(), viewer, bson.M{"_id": id})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = musicsCollection.FindOne(context.TODO(), visible).Decode(&music)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// owners still see their disabled tracks but nobody can play them
+	if len(music.TakedownIDs) != 0 {
+		c.Error(apperrors.Forbidden("this music is disabled because of a copyright takedown"))
+		return
+	}
+
+	// plays through a share link have no user to keep a history for
+	if viewer.ShareLink != nil {
+		if models.StartsPlay(c.GetHeader("Range")) {
+			if err := consumeSharePlay(viewer.ShareLink); err != nil {
+				c.Error(err)
+				return
+			}
+		}
+
+		if !viewer.CanReadFileOf(music.ID) {
+			proxyAudio(c, music.FileUrl)
+			return
+		}
+
+		c.Redirect(302, music.FileUrl)
+		return
+	}
+
+	// anonymous listens have no user and only count towards the charts
+	_, err = listensCollection.InsertOne(context.TODO(), models.Listen{
+		ID:         primitive.NewObjectID(),
+		MusicID:    music.ID,
+		UserID:     viewer.ID,
+		ListenedAt: time.Now().UTC(),
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Redirect(302, music.FileUrl)
+}
+
 func (ctrl *MusicsController) LikeMusic(c *gin.Context) {
 	musicId := c.Param("music_id")
+	userId := viewerIdOf(c)
 	id, err := primitive.ObjectIDFromHex(musicId)
 
 	if err != nil {
 		c.Error(err)
+		return
 	}
 
 	var music models.Music
 	filter := bson.M{"_id": id}
 
-	err = musicsCollection.FindOne(context.TODO(), filter).Decode(&music)
+	viewer, err := viewerOf(c)
 
 	if err != nil {
 		c.Error(err)
+		return
 	}
 
-	music.Likes++
+	visible, err := visibleFilter(context.TODO(), viewer, filter)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = musicsCollection.FindOne(context.TODO(), visible).Decode(&music)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = database.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+		_, err := likesCollection.InsertOne(sessCtx, models.Like{
+			ID:        primitive.NewObjectID(),
+			MusicID:   music.ID,
+			UserID:    userId,
+			CreatedAt: time.Now().UTC(),
+		})
+
+		if mongo.IsDuplicateKeyError(err) {
+			return apperrors.Conflict("you already liked this music")
+		}
+
+		if err != nil {
+			return err
+		}
+
+		_, err = musicsCollection.UpdateOne(sessCtx, filter, bson.M{"$inc": bson.M{"likes": 1}})
 
-	_, err = musicsCollection.UpdateOne(context.TODO(), filter, bson.M{"$set": bson.M{"likes": music.Likes}})
+		if err != nil {
+			return err
+		}
+
+		return events.Record(sessCtx, &events.MusicLiked{
+			MusicID:    music.ID.Hex(),
+			ArtistID:   music.ArtistID,
+			UserID:     userId,
+			Visibility: music.Visibility,
+		})
+	})
 
 	if err != nil {
 		c.Error(err)
+		return
 	}
 
 	c.JSON(200, gin.H{
@@ -107,10 +342,12 @@ func (ctrl *MusicsController) LikeMusic(c *gin.Context) {
 
 func (ctrl *MusicsController) UnlikeMusic(c *gin.Context) {
 	musicId := c.Param("music_id")
+	userId := viewerIdOf(c)
 	id, err := primitive.ObjectIDFromHex(musicId)
 
 	if err != nil {
 		c.Error(err)
+		return
 	}
 
 	var music models.Music
@@ -120,14 +357,36 @@ func (ctrl *MusicsController) UnlikeMusic(c *gin.Context) {
 
 	if err != nil {
 		c.Error(err)
+		return
 	}
 
-	music.Likes--
+	err = database.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+		res, err := likesCollection.DeleteOne(sessCtx, bson.M{"musicId": music.ID, "userId": userId})
 
-	_, err = musicsCollection.UpdateOne(context.TODO(), filter, bson.M{"$set": bson.M{"likes": music.Likes}})
+		if err != nil {
+			return err
+		}
+
+		if res.DeletedCount == 0 {
+			return apperrors.Conflict("you didnt like this music")
+		}
+
+		_, err = musicsCollection.UpdateOne(sessCtx, filter, bson.M{"$inc": bson.M{"likes": -1}})
+
+		if err != nil {
+			return err
+		}
+
+		return events.Record(sessCtx, &events.MusicUnliked{
+			MusicID:  music.ID.Hex(),
+			ArtistID: music.ArtistID,
+			UserID:   userId,
+		})
+	})
 
 	if err != nil {
 		c.Error(err)
+		return
 	}
 
 	c.JSON(200, gin.H{
@@ -141,6 +400,14 @@ func (ctrl *MusicsController) RetrieveMusicsByIds(c *gin.Context) {
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.Error(err)
+		return
+	}
+
+	viewer, err := viewerOf(c)
+
+	if err != nil {
+		c.Error(err)
+		return
 	}
 
 	for _, id := range req.MusicsIds {
@@ -149,12 +416,30 @@ func (ctrl *MusicsController) RetrieveMusicsByIds(c *gin.Context) {
 
 		if err != nil {
 			c.Error(err)
+			return
 		}
 
-		err = musicsCollection.FindOne(context.TODO(), bson.M{"_id": parsedId}).Decode(&music)
+		visible, err := visibleFilter(context.TODO(), viewer, bson.M{"_id": parsedId})
 
 		if err != nil {
 			c.Error(err)
+			return
+		}
+
+		err = musicsCollection.FindOne(context.TODO(), visible).Decode(&music)
+
+		// tracks the caller can't see are left out instead of failing the batch
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		if !viewer.CanReadFileOf(music.ID) {
+			music.FileUrl = ""
 		}
 
 		musics = append(musics, music)
@@ -168,26 +453,54 @@ func (ctrl *MusicsController) UploadMusic(c *gin.Context) {
 	title := c.PostForm("title")
 	shortDesc := c.PostForm("shortDesc")
 	file, err := c.FormFile("file")
-	userClaims := c.MustGet("user").(jwt.MapClaims)
+	userId := viewerIdOf(c)
 
 	if err != nil {
 		c.Error(err)
+		return
 	}
 
 	req := &UploadMusicReq{
-		Title:     title,
-		ShortDesc: shortDesc,
-		File:      file,
+		Title:      title,
+		ShortDesc:  shortDesc,
+		Visibility: c.DefaultPostForm("visibility", models.VisibilityPublic),
+		File:       file,
 	}
 
-	if err := validator.New().Struct(req); err != nil {
+	if err := validation.Struct(req); err != nil {
 		c.Error(err)
+		return
+	}
+
+	// scheduled tracks are only visible to their owner until ReleaseWorker
+	// releases them as public
+	if releaseAt := c.PostForm("releaseAt"); len(releaseAt) != 0 {
+		parsed, err := time.Parse(time.RFC3339, releaseAt)
+
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		if req.Visibility != models.VisibilityPublic {
+			c.Error(apperrors.Validation("scheduled tracks are released as public", apperrors.Field("visibility", "must be public when releaseAt is set")))
+			return
+		}
+
+		if !models.IsValidReleaseAt(parsed, time.Now()) {
+			c.Error(apperrors.Validation("releaseAt must be in the future"))
+			return
+		}
+
+		parsed = parsed.UTC()
+		req.ReleaseAt = &parsed
 	}
 
 	cid, err := cloudinary.NewFromURL(os.Getenv("CLOUDINARY_URL"))
 
 	if err != nil {
 		c.Error(err)
+		return
 	}
 
 	res, err := cid.Upload.Upload(context.TODO(), file, uploader.UploadParams{
@@ -196,22 +509,47 @@ func (ctrl *MusicsController) UploadMusic(c *gin.Context) {
 
 	if err != nil {
 		c.Error(err)
+		return
 	}
 
 	music := models.Music{
-		ID:        primitive.NewObjectID(),
-		Title:     req.Title,
-		ShortDesc: req.ShortDesc,
-		PosterUrl: "",
-		FileUrl:   res.SecureURL,
-		Likes:     0,
-		ArtistID:  userClaims["userId"].(string),
+		ID:         primitive.NewObjectID(),
+		Title:      req.Title,
+		ShortDesc:  req.ShortDesc,
+		PosterUrl:  "",
+		FileUrl:    res.SecureURL,
+		Likes:      0,
+		ArtistID:   userId,
+		Visibility: req.Visibility,
+		ReleaseAt:  req.ReleaseAt,
+	}
+
+	if music.Visibility == models.VisibilityUnlisted {
+		if music.ShareToken, err = lib.RandomToken(24); err != nil {
+			c.Error(err)
+			return
+		}
 	}
 
-	_, err = musicsCollection.InsertOne(context.TODO(), music)
+	err = database.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+		_, err := musicsCollection.InsertOne(sessCtx, music)
+
+		if err != nil {
+			return err
+		}
+
+		return events.Record(sessCtx, &events.MusicUploaded{
+			MusicID:    music.ID.Hex(),
+			ArtistID:   music.ArtistID,
+			Title:      music.Title,
+			Visibility: music.Visibility,
+			ReleaseAt:  music.ReleaseAt,
+		})
+	})
 
 	if err != nil {
 		c.Error(err)
+		return
 	}
 
 	c.JSON(200, gin.H{
@@ -224,26 +562,267 @@ func (ctrl *MusicsController) UpdateMusicMetadata(c *gin.Context) {
 	musicId := c.Param("musicId")
 
 	if len(musicId) == 0 {
-		c.Error(errors.New("no musicId param found in the incoming request params"))
+		c.Error(apperrors.Validation("no musicId param found in the incoming request params"))
+		return
 	}
 
 	id, err := primitive.ObjectIDFromHex(musicId)
 
 	if err != nil {
 		c.Error(err)
+		return
+	}
+
+	versions, versioned, err := rest.IfMatchVersion[int](c)
+
+	if err != nil {
+		c.Error(err)
+		return
 	}
 
 	req := UpdateMusicMetadataReq{}
-	filter := bson.M{"_id": id}
+	removed, err := rest.BindMergePatch(&req, c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if len(removed) != 0 {
+		fields := []apperrors.FieldError{}
+
+		for _, name := range removed {
+			fields = append(fields, apperrors.Field(name, "can't be removed"))
+		}
+
+		c.Error(apperrors.Validation("the patch removes required fields", fields...))
+		return
+	}
+
+	update := bson.M{"$inc": bson.M{"version": 1}}
+	changes := bson.M{}
+
+	if req.Title != nil {
+		changes["title"] = *req.Title
+	}
+
+	if req.ShortDesc != nil {
+		changes["shortdesc"] = *req.ShortDesc
+	}
+
+	if len(changes) != 0 {
+		update["$set"] = changes
+	}
+
+	var music models.Music
+	filter := bson.M{"_id": id, "artistId": c.Param("ownerId")}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	matched := filter
+
+	// only the legacy route takes updates without If-Match
+	if versioned {
+		matched = withVersion(filter, versions)
+	}
+
+	err = musicsCollection.FindOneAndUpdate(context.TODO(), matched, update, opts).Decode(&music)
+
+	if err == mongo.ErrNoDocuments {
+		err = versionConflict(filter)
+	}
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("ETag", rest.ETag(music.Version))
+	c.JSON(200, gin.H{
+		"success": true,
+		"music":   music,
+	})
+}
+
+// withVersion matches the track only while it still has one of the versions
+// the client edited, tracks stored before versioning are version 0
+func withVersion(filter bson.M, versions []int) bson.M {
+	matched := bson.A{}
+
+	for _, version := range versions {
+		matched = append(matched, version)
+
+		if version == 0 {
+			matched = append(matched, nil)
+		}
+	}
+
+	return bson.M{"$and": bson.A{filter, bson.M{"version": bson.M{"$in": matched}}}}
+}
+
+// Tells apart a track that doesn't exist from one edited since the client
+// read it, once a versioned update matched nothing
+func versionConflict(filter bson.M) error {
+	count, err := musicsCollection.CountDocuments(context.TODO(), filter)
+
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return apperrors.NotFound("music doesnt exist")
+	}
+
+	return apperrors.PreconditionFailed("the music was changed since it was read, read it again and retry")
+}
+
+func (ctrl *MusicsController) ChangeMusicVisibility(c *gin.Context) {
+	ownerId := c.Param("ownerId")
+	req := ChangeMusicVisibilityReq{}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("musicId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := validation.Struct(req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	filter := bson.M{"_id": id, "artistId": ownerId}
+
+	err = database.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+		var music models.Music
+
+		if err := musicsCollection.FindOne(sessCtx, filter).Decode(&music); err == mongo.ErrNoDocuments {
+			return apperrors.NotFound("music doesnt exist")
+		} else if err != nil {
+			return err
+		}
+
+		previous := music.Visibility
+
+		if len(previous) == 0 {
+			previous = models.VisibilityPublic
+		}
+
+		if previous == req.Visibility {
+			return nil
+		}
+
+		if music.IsScheduled() {
+			return apperrors.Conflict("a scheduled track is released as public, change its visibility once it is released")
+		}
+
+		set := bson.M{"visibility": req.Visibility}
+		update := bson.M{"$set": set, "$inc": bson.M{"version": 1}}
+
+		// every unlisting gets a new share link and leaving unlisted revokes
+		// the current one
+		if req.Visibility == models.VisibilityUnlisted {
+			shareToken, err := lib.RandomToken(24)
+
+			if err != nil {
+				return err
+			}
+
+			set["shareToken"] = shareToken
+		} else {
+			update["$unset"] = bson.M{"shareToken": ""}
+		}
+
+		if _, err := musicsCollection.UpdateOne(sessCtx, filter, update); err != nil {
+			return err
+		}
+
+		// reposts spread a track beyond its audience so only public tracks
+		// keep them
+		if req.Visibility != models.VisibilityPublic {
+			reposts, err := repostsCollection.Find(sessCtx, bson.M{"musicId": music.ID})
+
+			if err != nil {
+				return err
+			}
+
+			repostIds := []struct {
+				ID primitive.ObjectID `bson:"_id"`
+			}{}
+
+			if err := reposts.All(sessCtx, &repostIds); err != nil {
+				return err
+			}
+
+			for _, repost := range repostIds {
+				if _, err := removeRepost(sessCtx, bson.M{"_id": repost.ID}); err != nil {
+					return err
+				}
+			}
+		}
+
+		return events.Record(sessCtx, &events.MusicVisibilityChanged{
+			MusicID:    music.ID.Hex(),
+			ArtistID:   music.ArtistID,
+			Title:      music.Title,
+			Visibility: req.Visibility,
+			Previous:   previous,
+		})
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+// Moves the release of a track that was not released yet
+func (ctrl *MusicsController) ScheduleMusicRelease(c *gin.Context) {
+	req := ScheduleMusicReleaseReq{}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("musicId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.Error(err)
+		return
+	}
+
+	if err := validation.Struct(req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if !models.IsValidReleaseAt(req.ReleaseAt, time.Now()) {
+		c.Error(apperrors.Validation("releaseAt must be in the future"))
+		return
 	}
 
-	_, err = musicsCollection.UpdateOne(context.TODO(), filter, bson.M{"$set": bson.M{"title": req.Title, "shortDesc": req.ShortDesc}})
+	filter := bson.M{"_id": id, "artistId": c.Param("ownerId"), "releaseAt": bson.M{"$exists": true}}
+
+	res, err := musicsCollection.UpdateOne(context.TODO(), filter, bson.M{"$set": bson.M{"releaseAt": req.ReleaseAt.UTC()}, "$inc": bson.M{"version": 1}})
 
 	if err != nil {
 		c.Error(err)
+		return
+	}
+
+	if res.MatchedCount == 0 {
+		c.Error(apperrors.Conflict("music doesnt exist or is already released"))
+		return
 	}
 
 	c.JSON(200, gin.H{
@@ -251,29 +830,68 @@ func (ctrl *MusicsController) UpdateMusicMetadata(c *gin.Context) {
 	})
 }
 
+// The share link of an unlisted track, opening the track with ?share=<token>
+// works for anyone who has it
+func (ctrl *MusicsController) GetMusicShareLink(c *gin.Context) {
+	var music models.Music
+
+	id, err := primitive.ObjectIDFromHex(c.Param("musicId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = musicsCollection.FindOne(context.TODO(), bson.M{"_id": id, "artistId": c.Param("ownerId")}).Decode(&music)
+
+	if err == mongo.ErrNoDocuments {
+		c.Error(apperrors.NotFound("music doesnt exist"))
+		return
+	}
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if music.Visibility != models.VisibilityUnlisted {
+		c.Error(apperrors.Conflict("only unlisted musics have a share link"))
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"shareToken": music.ShareToken,
+		"url":        "/v1/musics/" + music.ID.Hex() + "?share=" + music.ShareToken,
+	})
+}
+
 func (ctrl *MusicsController) ChangeMusicPoster(c *gin.Context) {
 	poster, err := c.FormFile("poster")
 
 	if err != nil {
 		c.Error(err)
+		return
 	}
 
 	musicId := c.Param("musicId")
 
 	if len(musicId) == 0 {
-		c.Error(errors.New("no musicId param found in the incoming request params"))
+		c.Error(apperrors.Validation("no musicId param found in the incoming request params"))
+		return
 	}
 
 	id, err := primitive.ObjectIDFromHex(musicId)
 
 	if err != nil {
 		c.Error(err)
+		return
 	}
 
 	cid, err := cloudinary.NewFromURL(os.Getenv("CLOUDINARY_URL"))
 
 	if err != nil {
 		c.Error(err)
+		return
 	}
 
 	res, err := cid.Upload.Upload(context.TODO(), poster, uploader.UploadParams{
@@ -282,12 +900,19 @@ func (ctrl *MusicsController) ChangeMusicPoster(c *gin.Context) {
 
 	if err != nil {
 		c.Error(err)
+		return
 	}
 
-	_, err = musicsCollection.UpdateOne(context.TODO(), bson.M{"_id": id}, bson.M{"$set": bson.M{"posterUrl": res.SecureURL}})
+	updated, err := musicsCollection.UpdateOne(context.TODO(), bson.M{"_id": id, "artistId": c.Param("ownerId")}, bson.M{"$set": bson.M{"posterurl": res.SecureURL}, "$inc": bson.M{"version": 1}})
 
 	if err != nil {
 		c.Error(err)
+		return
+	}
+
+	if updated.MatchedCount == 0 {
+		c.Error(apperrors.NotFound("music doesnt exist"))
+		return
 	}
 
 	c.JSON(200, gin.H{
@@ -295,3 +920,67 @@ func (ctrl *MusicsController) ChangeMusicPoster(c *gin.Context) {
 	})
 
 }
+
+func (ctrl *MusicsController) DeleteMusic(c *gin.Context) {
+	ownerId := c.Param("ownerId")
+	musicId := c.Param("musicId")
+
+	id, err := primitive.ObjectIDFromHex(musicId)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	filter := bson.M{"_id": id, "artistId": ownerId}
+
+	err = database.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+		return removeMusic(sessCtx, filter)
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+// Deletes the track matching filter together with its likes, comments and
+// reposts
+func removeMusic(sessCtx mongo.SessionContext, filter bson.M) error {
+	var music models.Music
+
+	err := musicsCollection.FindOneAndDelete(sessCtx, filter).Decode(&music)
+
+	if err == mongo.ErrNoDocuments {
+		return apperrors.NotFound("music doesnt exist")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := likesCollection.DeleteMany(sessCtx, bson.M{"musicId": music.ID}); err != nil {
+		return err
+	}
+
+	if _, err := commentsCollection.DeleteMany(sessCtx, bson.M{"musicId": music.ID}); err != nil {
+		return err
+	}
+
+	if _, err := repostsCollection.DeleteMany(sessCtx, bson.M{"musicId": music.ID}); err != nil {
+		return err
+	}
+
+	if _, err := shareLinksCollection.DeleteMany(sessCtx, bson.M{"musicId": music.ID}); err != nil {
+		return err
+	}
+
+	return events.Record(sessCtx, &events.MusicDeleted{
+		MusicID:  music.ID.Hex(),
+		ArtistID: music.ArtistID,
+	})
+}
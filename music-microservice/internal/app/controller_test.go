@@ -0,0 +1,74 @@
+//go:build integration
+
+package app
+
+import (
+	"context"
+	"music-sharing/music-microservice/internal/app/models"
+	"music-sharing/shared/apperrors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The edit is read back from the stored document, not from the response
+func TestUpdateMusicMetadataStoresTheEdit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ctx := context.Background()
+	music := models.Music{
+		ID:         primitive.NewObjectID(),
+		ArtistID:   primitive.NewObjectID().Hex(),
+		Title:      "Before",
+		ShortDesc:  "before",
+		Visibility: models.VisibilityPublic,
+	}
+
+	if _, err := musicsCollection.InsertOne(ctx, music); err != nil {
+		t.Fatalf("insert music: %v", err)
+	}
+
+	t.Cleanup(func() {
+		musicsCollection.DeleteOne(ctx, bson.M{"_id": music.ID})
+	})
+
+	router := gin.New()
+	router.Use(apperrors.Handler())
+	router.PATCH("/musics/:ownerId/:musicId", (&MusicsController{}).UpdateMusicMetadata)
+
+	req := httptest.NewRequest("PATCH", "/musics/"+music.ArtistID+"/"+music.ID.Hex(), strings.NewReader(`{"shortDesc":"after"}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("If-Match", `"0"`)
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("PATCH answered %d: %s", res.Code, res.Body.String())
+	}
+
+	var stored models.Music
+
+	if err := musicsCollection.FindOne(ctx, bson.M{"_id": music.ID}).Decode(&stored); err != nil {
+		t.Fatalf("read back the track: %v", err)
+	}
+
+	if stored.ShortDesc != "after" || stored.Title != "Before" || stored.Version != 1 {
+		t.Fatalf("stored track = %+v, want the new shortDesc, the same title and version 1", stored)
+	}
+
+	var raw bson.M
+
+	if err := musicsCollection.FindOne(ctx, bson.M{"_id": music.ID}).Decode(&raw); err != nil {
+		t.Fatalf("read back the document: %v", err)
+	}
+
+	if _, stray := raw["shortDesc"]; stray {
+		t.Fatalf("document = %v, the edit went to a field the track is not read from", raw)
+	}
+}
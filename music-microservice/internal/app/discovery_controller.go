@@ -0,0 +1,224 @@
+package app
+
+import (
+	"context"
+	"music-sharing/music-microservice/internal/app/models"
+	"music-sharing/shared/apperrors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type (
+	DiscoveryController struct{}
+
+	ChartEntry struct {
+		Rank  int          `json:"rank"`
+		Plays int          `json:"plays"`
+		Music models.Music `json:"music"`
+	}
+
+	ArtistPage struct {
+		ArtistID   string         `json:"artistId"`
+		TrackCount int            `json:"trackCount"`
+		TotalLikes uint           `json:"totalLikes"`
+		Tracks     []models.Music `json:"tracks"`
+	}
+
+	rankedMusic struct {
+		MusicID primitive.ObjectID `bson:"_id"`
+		Plays   int                `bson:"plays"`
+	}
+
+	cachedChart struct {
+		ranking    []rankedMusic
+		computedAt time.Time
+	}
+)
+
+var chartPeriods = map[string]time.Duration{
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+}
+
+const (
+	// the ranking is shared by every viewer and filtered for each of them,
+	// more tracks than a chart shows are ranked so the filtering leaves enough
+	chartDepth    = 300
+	chartCacheTTL = 5 * time.Minute
+)
+
+var (
+	chartsMu sync.Mutex
+	charts   = map[string]cachedChart{}
+)
+
+// The most played tracks of the last day, week or month
+func (ctrl *DiscoveryController) GetCharts(c *gin.Context) {
+	period := c.DefaultQuery("period", "week")
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+
+	if _, ok := chartPeriods[period]; !ok {
+		c.Error(apperrors.Validation("period must be day, week or month"))
+		return
+	}
+
+	ranking, err := chartRanking(period)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	viewer, err := viewerOf(c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	musicIds := []primitive.ObjectID{}
+
+	for _, ranked := range ranking {
+		musicIds = append(musicIds, ranked.MusicID)
+	}
+
+	visible, err := visibleFilter(context.TODO(), viewer, bson.M{"_id": bson.M{"$in": musicIds}})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	result, err := musicsCollection.Find(context.TODO(), visible)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	musics := []models.Music{}
+
+	if err := result.All(context.TODO(), &musics); err != nil {
+		c.Error(err)
+		return
+	}
+
+	byId := map[primitive.ObjectID]models.Music{}
+
+	for _, music := range musics {
+		if !viewer.CanReadFileOf(music.ID) {
+			music.FileUrl = ""
+		}
+
+		byId[music.ID] = music
+	}
+
+	entries := []ChartEntry{}
+
+	for _, ranked := range ranking {
+		if len(entries) == limit {
+			break
+		}
+
+		if music, ok := byId[ranked.MusicID]; ok {
+			entries = append(entries, ChartEntry{Rank: len(entries) + 1, Plays: ranked.Plays, Music: music})
+		}
+	}
+
+	c.JSON(200, entries)
+}
+
+// Ranks the tracks by their listens over the period, recomputed at most once
+// every chartCacheTTL
+func chartRanking(period string) ([]rankedMusic, error) {
+	chartsMu.Lock()
+	defer chartsMu.Unlock()
+
+	if cached, ok := charts[period]; ok && time.Since(cached.computedAt) < chartCacheTTL {
+		return cached.ranking, nil
+	}
+
+	since := time.Now().UTC().Add(-chartPeriods[period])
+
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"listenedAt": bson.M{"$gte": since}}},
+		bson.M{"$group": bson.M{"_id": "$musicId", "plays": bson.M{"$sum": 1}}},
+		bson.M{"$sort": bson.D{{Key: "plays", Value: -1}, {Key: "_id", Value: 1}}},
+		bson.M{"$limit": chartDepth},
+	}
+
+	result, err := listensCollection.Aggregate(context.TODO(), pipeline)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ranking := []rankedMusic{}
+
+	if err := result.All(context.TODO(), &ranking); err != nil {
+		return nil, err
+	}
+
+	charts[period] = cachedChart{ranking: ranking, computedAt: time.Now()}
+
+	return ranking, nil
+}
+
+// The tracks of an artist the viewer can see, newest first
+func (ctrl *DiscoveryController) GetArtistPage(c *gin.Context) {
+	ctx := context.TODO()
+	artistId := c.Param("artistId")
+
+	viewer, err := viewerOf(c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	opts := options.Find().SetSort(bson.M{"_id": -1})
+
+	visible, err := visibleFilter(ctx, viewer, bson.M{"artistId": artistId})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	result, err := musicsCollection.Find(ctx, visible, opts)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	page := ArtistPage{ArtistID: artistId, Tracks: []models.Music{}}
+
+	if err := result.All(ctx, &page.Tracks); err != nil {
+		c.Error(err)
+		return
+	}
+
+	page.TrackCount = len(page.Tracks)
+
+	for i, music := range page.Tracks {
+		page.TotalLikes += music.Likes
+
+		if !viewer.CanReadFileOf(music.ID) {
+			page.Tracks[i].FileUrl = ""
+		}
+	}
+
+	c.JSON(200, page)
+}
@@ -0,0 +1,361 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"music-sharing/music-microservice/internal/app/models"
+	"music-sharing/music-microservice/internal/database"
+	"music-sharing/shared/apperrors"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type (
+	InternalController struct{}
+
+	ExportedLike struct {
+		MusicID string    `json:"musicId"`
+		Title   string    `json:"title"`
+		LikedAt time.Time `json:"likedAt"`
+	}
+
+	ExportedListen struct {
+		MusicID    string    `json:"musicId"`
+		Title      string    `json:"title"`
+		ListenedAt time.Time `json:"listenedAt"`
+	}
+
+	ExportUserDataResponse struct {
+		Likes            []ExportedLike   `json:"likes"`
+		Tracks           []models.Music   `json:"tracks"`
+		ListeningHistory []ExportedListen `json:"listeningHistory"`
+		Comments         []models.Comment `json:"comments"`
+		Reposts          []models.Repost  `json:"reposts"`
+	}
+
+	PurgeUserDataResponse struct {
+		LikesRemoved     int64 `json:"likesRemoved"`
+		ListensRemoved   int64 `json:"listensRemoved"`
+		TracksRemoved    int64 `json:"tracksRemoved"`
+		TracksAnonymised int64 `json:"tracksAnonymised"`
+		CommentsRemoved  int64 `json:"commentsRemoved"`
+		RepostsRemoved   int64 `json:"repostsRemoved"`
+	}
+)
+
+// Artist id given to the tracks of deleted accounts when they are anonymised
+const DeletedArtistID = "00000000-0000-0000-0000-000000000000"
+
+// Removes the likes of a deleted user and removes or anonymises their tracks
+// depending on ACCOUNT_DELETION_TRACKS ("delete" by default or "anonymise").
+// Every item is handled in its own transaction, so the purge can be retried
+// after a failure and calling it for an already purged user is a no-op
+func (ctrl *InternalController) PurgeUserData(c *gin.Context) {
+	userId := c.Param("userId")
+	ctx := context.TODO()
+	resp := &PurgeUserDataResponse{}
+
+	likes, err := likesCollection.Find(ctx, bson.M{"userId": userId})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	userLikes := []models.Like{}
+
+	if err := likes.All(ctx, &userLikes); err != nil {
+		c.Error(err)
+		return
+	}
+
+	for _, like := range userLikes {
+		var deleted int64
+
+		err := database.WithTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+			res, err := likesCollection.DeleteOne(sessCtx, bson.M{"_id": like.ID})
+
+			if err != nil || res.DeletedCount == 0 {
+				return err
+			}
+
+			deleted = res.DeletedCount
+			_, err = musicsCollection.UpdateOne(sessCtx, bson.M{"_id": like.MusicID}, bson.M{"$inc": bson.M{"likes": -1}})
+
+			return err
+		})
+
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		resp.LikesRemoved += deleted
+	}
+
+	listens, err := listensCollection.DeleteMany(ctx, bson.M{"userId": userId})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	resp.ListensRemoved = listens.DeletedCount
+
+	comments, err := commentsCollection.Find(ctx, bson.M{"authorId": userId, "deleted": false})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	userComments := []models.Comment{}
+
+	if err := comments.All(ctx, &userComments); err != nil {
+		c.Error(err)
+		return
+	}
+
+	for _, comment := range userComments {
+		err := database.WithTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+			return deleteComment(sessCtx, &comment)
+		})
+
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		resp.CommentsRemoved++
+	}
+
+	reposts, err := repostsCollection.Find(ctx, bson.M{"userId": userId})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	userReposts := []models.Repost{}
+
+	if err := reposts.All(ctx, &userReposts); err != nil {
+		c.Error(err)
+		return
+	}
+
+	for _, repost := range userReposts {
+		var removed bool
+
+		err := database.WithTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+			var err error
+			removed, err = removeRepost(sessCtx, bson.M{"_id": repost.ID})
+
+			return err
+		})
+
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		if removed {
+			resp.RepostsRemoved++
+		}
+	}
+
+	tracks, err := musicsCollection.Find(ctx, bson.M{"artistId": userId})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	userTracks := []models.Music{}
+
+	if err := tracks.All(ctx, &userTracks); err != nil {
+		c.Error(err)
+		return
+	}
+
+	anonymise := os.Getenv("ACCOUNT_DELETION_TRACKS") == "anonymise"
+
+	for _, music := range userTracks {
+		err := database.WithTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+			if !anonymise {
+				return removeMusic(sessCtx, bson.M{"_id": music.ID, "artistId": userId})
+			}
+
+			// links given out by a deleted account stop working either way
+			if _, err := shareLinksCollection.DeleteMany(sessCtx, bson.M{"musicId": music.ID}); err != nil {
+				return err
+			}
+
+			_, err := musicsCollection.UpdateOne(sessCtx, bson.M{"_id": music.ID}, bson.M{"$set": bson.M{"artistId": DeletedArtistID}})
+
+			return err
+		})
+
+		var appErr *apperrors.Error
+
+		// removed by its artist since it was read
+		if errors.As(err, &appErr) && appErr.Status == 404 {
+			continue
+		}
+
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		if anonymise {
+			resp.TracksAnonymised++
+		} else {
+			resp.TracksRemoved++
+		}
+	}
+
+	c.JSON(200, resp)
+}
+
+// Returns everything music-microservice stores about a user for data exports
+func (ctrl *InternalController) ExportUserData(c *gin.Context) {
+	userId := c.Param("userId")
+	ctx := context.TODO()
+	resp := &ExportUserDataResponse{
+		Likes:            []ExportedLike{},
+		Tracks:           []models.Music{},
+		ListeningHistory: []ExportedListen{},
+		Comments:         []models.Comment{},
+		Reposts:          []models.Repost{},
+	}
+
+	tracks, err := musicsCollection.Find(ctx, bson.M{"artistId": userId})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := tracks.All(ctx, &resp.Tracks); err != nil {
+		c.Error(err)
+		return
+	}
+
+	comments, err := commentsCollection.Find(ctx, bson.M{"authorId": userId, "deleted": false})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := comments.All(ctx, &resp.Comments); err != nil {
+		c.Error(err)
+		return
+	}
+
+	reposts, err := repostsCollection.Find(ctx, bson.M{"userId": userId})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := reposts.All(ctx, &resp.Reposts); err != nil {
+		c.Error(err)
+		return
+	}
+
+	likes, err := likesCollection.Find(ctx, bson.M{"userId": userId})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	userLikes := []models.Like{}
+
+	if err := likes.All(ctx, &userLikes); err != nil {
+		c.Error(err)
+		return
+	}
+
+	listens, err := listensCollection.Find(ctx, bson.M{"userId": userId})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	userListens := []models.Listen{}
+
+	if err := listens.All(ctx, &userListens); err != nil {
+		c.Error(err)
+		return
+	}
+
+	musicIds := []primitive.ObjectID{}
+
+	for _, like := range userLikes {
+		musicIds = append(musicIds, like.MusicID)
+	}
+
+	for _, listen := range userListens {
+		musicIds = append(musicIds, listen.MusicID)
+	}
+
+	titles, err := musicTitles(ctx, musicIds)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	for _, like := range userLikes {
+		resp.Likes = append(resp.Likes, ExportedLike{
+			MusicID: like.MusicID.Hex(),
+			Title:   titles[like.MusicID],
+			LikedAt: like.CreatedAt,
+		})
+	}
+
+	for _, listen := range userListens {
+		resp.ListeningHistory = append(resp.ListeningHistory, ExportedListen{
+			MusicID:    listen.MusicID.Hex(),
+			Title:      titles[listen.MusicID],
+			ListenedAt: listen.ListenedAt,
+		})
+	}
+
+	c.JSON(200, resp)
+}
+
+func musicTitles(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]string, error) {
+	titles := map[primitive.ObjectID]string{}
+
+	if len(ids) == 0 {
+		return titles, nil
+	}
+
+	result, err := musicsCollection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	musics := []models.Music{}
+
+	if err := result.All(ctx, &musics); err != nil {
+		return nil, err
+	}
+
+	for _, music := range musics {
+		titles[music.ID] = music.Title
+	}
+
+	return titles, nil
+}
@@ -1,31 +1,98 @@
 package middlewares
 
 import (
-	"errors"
 	"music-sharing/music-microservice/internal/lib"
+	"music-sharing/shared/apperrors"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 func AuthMiddleware(c *gin.Context) {
 
 	header := c.Request.Header.Get("Authorization")
+	parts := strings.Split(header, " ")
 
-	if len(header) == 0 {
-		c.Error(errors.New("no authorization header"))
+	if len(header) == 0 || len(parts) != 2 {
+		c.Error(apperrors.Unauthorized("no authorization header"))
+		c.Abort()
+		return
 	}
 
-	tokenString := strings.Split(header, " ")[1]
+	tokenString := parts[1]
 
-	userClaims, err := lib.ParseJWT(tokenString)
+	userClaims, viewerContext, err := authenticate(tokenString)
 
 	if err != nil {
 		c.Error(err)
+		c.Abort()
+		return
 	}
 
 	c.Set("user", userClaims)
 	c.Set("user_token", tokenString)
+	c.Set("viewer_context", viewerContext)
 
 	c.Next()
 }
+
+// Lets anonymous callers through, the user is only set when the request has a
+// valid session, revoked ones and suspended users browse anonymously. The
+// ?token= of a share link is kept for the handlers, which check it against the
+// link it was minted for
+func OptionalAuthMiddleware(c *gin.Context) {
+
+	parts := strings.Split(c.Request.Header.Get("Authorization"), " ")
+
+	if len(parts) == 2 {
+		if userClaims, viewerContext, err := authenticate(parts[1]); err == nil {
+			c.Set("user", userClaims)
+			c.Set("user_token", parts[1])
+			c.Set("viewer_context", viewerContext)
+		}
+	}
+
+	if token := c.Query("token"); len(token) != 0 {
+		c.Set("share_token", token)
+	}
+
+	c.Next()
+}
+
+// Checks the token and loads the context of its user once, the role and the
+// relations of the viewer are read from it by the later handlers
+func authenticate(tokenString string) (jwt.MapClaims, *lib.ViewerContext, error) {
+
+	userClaims, err := lib.ParseJWT(tokenString)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	userId, _ := userClaims["userId"].(string)
+
+	if len(userId) == 0 {
+		return nil, nil, apperrors.Unauthorized("invalid token")
+	}
+
+	viewerContext, err := lib.GetViewerContext(userId)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the same checks user-microservice makes for its own routes, tokens issued
+	// before the last password or role change are revoked
+	tokenVersion, _ := userClaims["tokenVersion"].(float64)
+
+	if uint(tokenVersion) != viewerContext.TokenVersion {
+		return nil, nil, apperrors.Unauthorized("session has been revoked, please login again")
+	}
+
+	if viewerContext.Suspended {
+		return nil, nil, apperrors.Forbidden("this account is suspended")
+	}
+
+	return userClaims, viewerContext, nil
+}
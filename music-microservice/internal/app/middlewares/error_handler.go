@@ -1,16 +1,27 @@
 package middlewares
 
-import "github.com/gin-gonic/gin"
+import (
+	"errors"
+	"music-sharing/shared/apperrors"
 
-func ErrorHandlerMiddleware(c *gin.Context) {
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
 
-	c.Next()
+// Answers a failed request with the problem of its first error, later ones
+// are usually a consequence of it. Internal errors are logged and their
+// details are never sent
+var ErrorHandlerMiddleware = apperrors.Handler(classifyError)
 
-	if len(c.Errors) > 0 {
-		c.JSON(500, gin.H{
-			"errors":  c.Errors,
-			"success": false,
-		})
+func classifyError(err error) *apperrors.Error {
+	switch {
+	case errors.Is(err, primitive.ErrInvalidHex):
+		return apperrors.Validation("invalid id")
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return apperrors.NotFound("not found")
+	case mongo.IsDuplicateKeyError(err):
+		return apperrors.Conflict("already exists")
 	}
 
+	return nil
 }
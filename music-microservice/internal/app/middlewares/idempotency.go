@@ -0,0 +1,93 @@
+package middlewares
+
+import (
+	"context"
+	"music-sharing/music-microservice/internal/app/models"
+	"music-sharing/music-microservice/internal/database"
+	"music-sharing/shared/idempotency"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var idempotencyKeysCollection *mongo.Collection = database.OpenCollection("idempotency_keys")
+
+// Replays the stored response of a mutating request retried with the same
+// Idempotency-Key header, the keys are stored in the idempotency_keys
+// collection
+var IdempotencyMiddleware = idempotency.Middleware(&idempotencyStore{})
+
+type idempotencyStore struct{}
+
+func (s *idempotencyStore) Claim(record *idempotency.Record) (*idempotency.Record, error) {
+
+	now := time.Now().UTC()
+
+	// the ttl monitor of mongo only runs every minute
+	_, err := idempotencyKeysCollection.DeleteOne(context.TODO(), bson.M{
+		"key":   record.Key,
+		"scope": record.Scope,
+		"$or": bson.A{
+			bson.M{"expiresAt": bson.M{"$lt": now}},
+			bson.M{"status": 0, "createdAt": bson.M{"$lt": now.Add(-idempotency.Lease)}},
+		},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = idempotencyKeysCollection.InsertOne(context.TODO(), models.IdempotencyKey{
+		ID:          primitive.NewObjectID(),
+		Key:         record.Key,
+		Scope:       record.Scope,
+		RequestHash: record.RequestHash,
+		CreatedAt:   record.CreatedAt,
+		ExpiresAt:   record.ExpiresAt,
+	})
+
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+
+	stored := models.IdempotencyKey{}
+
+	if err := idempotencyKeysCollection.FindOne(context.TODO(), s.scoped(record)).Decode(&stored); err != nil {
+		return nil, err
+	}
+
+	return &idempotency.Record{
+		Key:         stored.Key,
+		Scope:       stored.Scope,
+		RequestHash: stored.RequestHash,
+		Status:      stored.Status,
+		ContentType: stored.ContentType,
+		Body:        stored.Body,
+		Withheld:    stored.Withheld,
+		CreatedAt:   stored.CreatedAt,
+		ExpiresAt:   stored.ExpiresAt,
+	}, nil
+}
+
+func (s *idempotencyStore) Complete(record *idempotency.Record) error {
+	_, err := idempotencyKeysCollection.UpdateOne(context.TODO(), s.scoped(record), bson.M{"$set": bson.M{
+		"status":      record.Status,
+		"contentType": record.ContentType,
+		"body":        record.Body,
+		"withheld":    record.Withheld,
+	}})
+
+	return err
+}
+
+func (s *idempotencyStore) Release(record *idempotency.Record) error {
+	_, err := idempotencyKeysCollection.DeleteOne(context.TODO(), s.scoped(record))
+
+	return err
+}
+
+func (s *idempotencyStore) scoped(record *idempotency.Record) bson.M {
+	return bson.M{"key": record.Key, "scope": record.Scope}
+}
@@ -0,0 +1,24 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"music-sharing/shared/apperrors"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Guards the routes that are only called by the other microservices
+func InternalMiddleware(c *gin.Context) {
+
+	apiKey := os.Getenv("INTERNAL_API_KEY")
+	header := c.Request.Header.Get("X-Internal-Token")
+
+	if len(apiKey) == 0 || subtle.ConstantTimeCompare([]byte(apiKey), []byte(header)) != 1 {
+		c.Error(apperrors.Unauthorized("you are not authorized"))
+		c.Abort()
+		return
+	}
+
+	c.Next()
+}
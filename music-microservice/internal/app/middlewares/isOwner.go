@@ -1,37 +1,35 @@
 package middlewares
 
 import (
-	"errors"
-	"music-sharing/music-microservice/internal/lib"
-	"strings"
+	"music-sharing/shared/apperrors"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 )
 
+// Must run after AuthMiddleware, the owner is read from the token claims. The
+// v1 routes have no :ownerId, they act on the tracks of the caller
 func IsOwnerMiddleware(c *gin.Context) {
-	header := c.Request.Header.Get("Authorization")
+	claims, exists := c.Get("user")
 
-	if len(header) == 0 {
-		c.Error(errors.New("no authorization header"))
+	if exists && len(c.Param("ownerId")) == 0 {
+		userId, _ := claims.(jwt.MapClaims)["userId"].(string)
+		c.Params = append(c.Params, gin.Param{Key: "ownerId", Value: userId})
 	}
 
 	ownerId := c.Param("ownerId")
 
 	if len(ownerId) == 0 {
-		c.Error(errors.New("no owner_id param found in the incoming request params"))
+		c.Error(apperrors.Validation("no owner_id param found in the incoming request params"))
+		c.Abort()
+		return
 	}
 
-	tokenString := strings.Split(header, " ")[1]
-
-	userClaims, err := lib.ParseJWT(tokenString)
-
-	if err != nil {
-		c.Error(err)
+	if !exists || claims.(jwt.MapClaims)["userId"] != ownerId {
+		c.Error(apperrors.Forbidden("you are not authorized"))
+		c.Abort()
+		return
 	}
 
-	if userClaims["userId"] != ownerId {
-		c.Error(errors.New("you are not authorized"))
-	} else {
-		c.Next()
-	}
+	c.Next()
 }
@@ -0,0 +1,26 @@
+package middlewares
+
+import (
+	"music-sharing/music-microservice/internal/lib"
+	"music-sharing/shared/apperrors"
+	"music-sharing/shared/permissions"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Must run after AuthMiddleware, the role is read from the viewer context it
+// loaded and not from the token, whose claims outlive a change of the role
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		viewerContext, exists := c.Get("viewer_context")
+
+		if !exists || !permissions.HasPermission(viewerContext.(*lib.ViewerContext).Role, permission) {
+			c.Error(apperrors.Forbidden("you are not authorized"))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,58 @@
+package middlewares
+
+import (
+	"music-sharing/shared/apperrors"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limits anonymous callers to ANONYMOUS_RATE_LIMIT requests, 60 by default,
+// per window and per client ip. Authenticated callers are not limited so it
+// must run after OptionalAuthMiddleware. The counters live in memory, every
+// replica limits on its own
+func AnonymousRateLimit(window time.Duration) gin.HandlerFunc {
+	limit := 60
+
+	if configured, err := strconv.Atoi(os.Getenv("ANONYMOUS_RATE_LIMIT")); err == nil && configured > 0 {
+		limit = configured
+	}
+
+	var mu sync.Mutex
+	counts := map[string]int{}
+	windowStart := time.Now()
+
+	return func(c *gin.Context) {
+
+		if _, exists := c.Get("user"); exists {
+			c.Next()
+			return
+		}
+
+		mu.Lock()
+
+		// fixed windows, all the counters start over together
+		if time.Since(windowStart) >= window {
+			counts = map[string]int{}
+			windowStart = time.Now()
+		}
+
+		counts[c.ClientIP()]++
+		count := counts[c.ClientIP()]
+		retryAfter := window - time.Since(windowStart)
+
+		mu.Unlock()
+
+		if count > limit {
+			c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			c.Error(apperrors.TooManyRequests("too many requests, log in to lift the limit"))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Comment on a track. Replies keep the id of the comment they answer and of
+// the top level comment of their thread. PositionMs pins the comment to a
+// playback position of the track
+type Comment struct {
+	ID         primitive.ObjectID  `bson:"_id" json:"id"`
+	MusicID    primitive.ObjectID  `bson:"musicId" json:"musicId"`
+	AuthorID   string              `bson:"authorId" json:"authorId"`
+	ParentID   *primitive.ObjectID `bson:"parentId" json:"parentId"`
+	ThreadID   *primitive.ObjectID `bson:"threadId" json:"threadId"`
+	Body       string              `bson:"body" json:"body"`
+	PositionMs *int                `bson:"positionMs,omitempty" json:"positionMs,omitempty"`
+	ReplyCount int                 `bson:"replyCount" json:"replyCount"`
+	// comments with replies are only blanked when they are deleted so the
+	// thread stays readable
+	Deleted   bool       `bson:"deleted" json:"deleted"`
+	CreatedAt time.Time  `bson:"createdAt" json:"createdAt"`
+	EditedAt  *time.Time `bson:"editedAt,omitempty" json:"editedAt,omitempty"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The stored outcome of a mutating request sent with an Idempotency-Key
+// header. Keys are scoped to the caller that sent them and Status is 0 while
+// the first request is still running
+type IdempotencyKey struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Key         string             `bson:"key"`
+	Scope       string             `bson:"scope"`
+	RequestHash string             `bson:"requestHash"`
+	Status      int                `bson:"status"`
+	ContentType string             `bson:"contentType,omitempty"`
+	Body        []byte             `bson:"body,omitempty"`
+	Withheld    bool               `bson:"withheld,omitempty"`
+	CreatedAt   time.Time          `bson:"createdAt"`
+	ExpiresAt   time.Time          `bson:"expiresAt"`
+}
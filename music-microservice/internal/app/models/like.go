@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Like struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	MusicID   primitive.ObjectID `bson:"musicId" json:"musicId"`
+	UserID    string             `bson:"userId" json:"userId"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
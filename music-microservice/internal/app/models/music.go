@@ -1,13 +1,61 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
 
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Who can see a track. Tracks stored before visibility existed have none and
+// are public
+const (
+	VisibilityPublic    = "public"
+	VisibilityUnlisted  = "unlisted"
+	VisibilityPrivate   = "private"
+	VisibilityFollowers = "followers"
+)
+
+// The fields stored before the bson tags were written have the lowercase keys
+// of the driver, updates $set the same keys
 type Music struct {
 	ID        primitive.ObjectID `bson:"_id"`
 	ArtistID  string             `bson:"artistId" json:"artistId"`
-	Likes     uint               `json:"likes"`
-	FileUrl   string             `json:"fileUrl"`
-	PosterUrl string             `json:"posterUrl"`
-	Title     string             `json:"title"`
-	ShortDesc string             `json:"shortDesc"`
+	Likes     uint               `bson:"likes" json:"likes"`
+	FileUrl   string             `bson:"fileurl" json:"fileUrl"`
+	PosterUrl string             `bson:"posterurl" json:"posterUrl"`
+	Title     string             `bson:"title" json:"title"`
+	ShortDesc string             `bson:"shortdesc" json:"shortDesc"`
+	// one of the Visibility constants
+	Visibility string `bson:"visibility" json:"visibility"`
+	// secret of the share link of an unlisted track, only given to its owner
+	ShareToken string `bson:"shareToken,omitempty" json:"-"`
+	// set until a scheduled track is released, only its owner sees it before
+	ReleaseAt *time.Time `bson:"releaseAt,omitempty" json:"releaseAt,omitempty"`
+	// top level comments and replies that were not deleted
+	CommentCount int `bson:"commentCount" json:"commentCount"`
+	RepostCount  int `bson:"repostCount" json:"repostCount"`
+	// set by moderators, hidden tracks are only visible to their owner
+	Hidden       bool       `bson:"hidden" json:"hidden"`
+	HiddenReason string     `bson:"hiddenReason,omitempty" json:"hiddenReason,omitempty"`
+	HiddenBy     string     `bson:"hiddenBy,omitempty" json:"hiddenBy,omitempty"`
+	HiddenAt     *time.Time `bson:"hiddenAt,omitempty" json:"hiddenAt,omitempty"`
+	// actioned copyright takedowns, the track is disabled while it has any
+	TakedownIDs []primitive.ObjectID `bson:"takedownIds,omitempty" json:"takedownIds,omitempty"`
+	// bumped by every edit of the owner and sent as the ETag of the track.
+	// Tracks stored before versioning have none and are version 0
+	Version int `bson:"version" json:"version"`
+}
+
+func (m *Music) IsPublic() bool {
+	return len(m.Visibility) == 0 || m.Visibility == VisibilityPublic
+}
+
+// A scheduled track is released by ReleaseWorker once its ReleaseAt has
+// passed, until then its owner can move it to another time in the future
+func (m *Music) IsScheduled() bool {
+	return m.ReleaseAt != nil
+}
+
+func IsValidReleaseAt(releaseAt time.Time, now time.Time) bool {
+	return releaseAt.After(now)
 }
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The tracks stored before the bson tags have the lowercase keys of the
+// driver, the updates and the searches write and read the same keys
+func TestMusicKeepsTheStoredKeys(t *testing.T) {
+	data, err := bson.Marshal(&Music{ID: primitive.NewObjectID(), Title: "t", ShortDesc: "d", PosterUrl: "p", FileUrl: "f", Likes: 1})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var doc bson.M
+
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for key, value := range map[string]interface{}{"title": "t", "shortdesc": "d", "posterurl": "p", "fileurl": "f"} {
+		if doc[key] != value {
+			t.Fatalf("document = %v, want %q at %s", doc, value, key)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// An outbox event is dead lettered once the relay gave up on it after it failed
+// every attempt, it is never published again
+type OutboxEvent struct {
+	ID             primitive.ObjectID `bson:"_id" json:"id"`
+	Name           string             `bson:"name" json:"name"`
+	AggregateID    string             `bson:"aggregateId" json:"aggregateId"`
+	Payload        string             `bson:"payload" json:"payload"`
+	OccurredAt     time.Time          `bson:"occurredAt" json:"occurredAt"`
+	PublishedAt    *time.Time         `bson:"publishedAt" json:"publishedAt"`
+	LockedUntil    *time.Time         `bson:"lockedUntil" json:"lockedUntil"`
+	Attempts       uint               `bson:"attempts" json:"attempts"`
+	LastError      string             `bson:"lastError" json:"lastError"`
+	DeadLetteredAt *time.Time         `bson:"deadLetteredAt" json:"deadLetteredAt"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	ReportCategorySpam       = "spam"
+	ReportCategoryOffensive  = "offensive"
+	ReportCategoryCopyright  = "copyright"
+	ReportCategoryHarassment = "harassment"
+	ReportCategoryOther      = "other"
+
+	ModerationCaseOpen     = "open"
+	ModerationCaseResolved = "resolved"
+
+	ModerationActionDismiss = "dismiss"
+	ModerationActionHide    = "hide"
+	ModerationActionRemove  = "remove"
+)
+
+// A user can only report a track once
+type Report struct {
+	ID         primitive.ObjectID `bson:"_id" json:"id"`
+	MusicID    primitive.ObjectID `bson:"musicId" json:"musicId"`
+	ReporterID string             `bson:"reporterId" json:"reporterId"`
+	Category   string             `bson:"category" json:"category"`
+	Details    string             `bson:"details" json:"details"`
+	CaseID     primitive.ObjectID `bson:"caseId" json:"caseId"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// Aggregates the reports of a track until a moderator resolves it, there is
+// at most one open case per track
+type ModerationCase struct {
+	ID              primitive.ObjectID `bson:"_id" json:"id"`
+	MusicID         primitive.ObjectID `bson:"musicId" json:"musicId"`
+	ArtistID        string             `bson:"artistId" json:"artistId"`
+	Status          string             `bson:"status" json:"status"`
+	ReportCount     int                `bson:"reportCount" json:"reportCount"`
+	Categories      map[string]int     `bson:"categories" json:"categories"`
+	FirstReportedAt time.Time          `bson:"firstReportedAt" json:"firstReportedAt"`
+	LastReportedAt  time.Time          `bson:"lastReportedAt" json:"lastReportedAt"`
+	Action          string             `bson:"action,omitempty" json:"action,omitempty"`
+	Note            string             `bson:"note,omitempty" json:"note,omitempty"`
+	ResolvedBy      string             `bson:"resolvedBy,omitempty" json:"resolvedBy,omitempty"`
+	ResolvedAt      *time.Time         `bson:"resolvedAt,omitempty" json:"resolvedAt,omitempty"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// A user can repost a track once
+type Repost struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	MusicID   primitive.ObjectID `bson:"musicId" json:"musicId"`
+	UserID    string             `bson:"userId" json:"userId"`
+	Caption   string             `bson:"caption,omitempty" json:"caption,omitempty"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// A link an owner gives out to let someone play one of their tracks without an
+// account, whatever the visibility of the track. The token of the link is
+// signed and derived from its id and expiry so it is not stored. MaxPlays 0
+// means unlimited
+type ShareLink struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	MusicID   primitive.ObjectID `bson:"musicId" json:"musicId"`
+	OwnerID   string             `bson:"ownerId" json:"ownerId"`
+	Label     string             `bson:"label,omitempty" json:"label,omitempty"`
+	ExpiresAt time.Time          `bson:"expiresAt" json:"expiresAt"`
+	MaxPlays  int                `bson:"maxPlays" json:"maxPlays"`
+	Plays     int                `bson:"plays" json:"plays"`
+	RevokedAt *time.Time         `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// A player loads a track with many range requests, the first one and one per
+// seek. Only a request of the whole file or of its start begins a play, so a
+// listen through the link counts once
+func StartsPlay(byteRange string) bool {
+	byteRange = strings.TrimSpace(byteRange)
+
+	return len(byteRange) == 0 || strings.HasPrefix(byteRange, "bytes=0-")
+}
@@ -0,0 +1,26 @@
+package models
+
+import "testing"
+
+func TestStartsPlay(t *testing.T) {
+	cases := []struct {
+		byteRange string
+		starts    bool
+	}{
+		{"", true},
+		{"bytes=0-", true},
+		{"bytes=0-1048575", true},
+		{" bytes=0-", true},
+		// the next chunks of the same play and the seeks
+		{"bytes=1048576-", false},
+		{"bytes=1048576-2097151", false},
+		{"bytes=10-20", false},
+		{"bytes=-500", false},
+	}
+
+	for _, tc := range cases {
+		if starts := StartsPlay(tc.byteRange); starts != tc.starts {
+			t.Fatalf("StartsPlay(%q) = %v, want %v", tc.byteRange, starts, tc.starts)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	TakedownSubmitted      = "submitted"
+	TakedownVerified       = "verified"
+	TakedownActioned       = "actioned"
+	TakedownCounterNoticed = "counter_noticed"
+	TakedownReinstated     = "reinstated"
+	TakedownRejected       = "rejected"
+)
+
+// TakedownTransition is a step of the takedown process, it moves a takedown
+// in one of the From statuses to To
+type TakedownTransition struct {
+	From []string
+	To   string
+}
+
+// Reinstated and rejected takedowns are final. A counter-noticed takedown goes
+// back to actioned when the claimant shows a court action was filed
+var (
+	TakedownVerification  = TakedownTransition{From: []string{TakedownSubmitted}, To: TakedownVerified}
+	TakedownRejection     = TakedownTransition{From: []string{TakedownSubmitted, TakedownVerified}, To: TakedownRejected}
+	TakedownAction        = TakedownTransition{From: []string{TakedownVerified}, To: TakedownActioned}
+	TakedownCounterNotice = TakedownTransition{From: []string{TakedownActioned}, To: TakedownCounterNoticed}
+	TakedownCourtAction   = TakedownTransition{From: []string{TakedownCounterNoticed}, To: TakedownActioned}
+	TakedownReinstatement = TakedownTransition{From: []string{TakedownCounterNoticed}, To: TakedownReinstated}
+)
+
+func (t TakedownTransition) AppliesTo(status string) bool {
+	for _, from := range t.From {
+		if from == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Takedown is a copyright notice sent by a rights holder against one or more
+// tracks of the same artist
+type Takedown struct {
+	ID              primitive.ObjectID   `bson:"_id" json:"id"`
+	MusicIDs        []primitive.ObjectID `bson:"musicIds" json:"musicIds"`
+	ArtistID        string               `bson:"artistId" json:"artistId"`
+	ClaimantID      string               `bson:"claimantId" json:"claimantId"`
+	ClaimantName    string               `bson:"claimantName" json:"claimantName"`
+	ClaimantEmail   string               `bson:"claimantEmail" json:"claimantEmail"`
+	CopyrightedWork string               `bson:"copyrightedWork" json:"copyrightedWork"`
+	Statement       string               `bson:"statement" json:"statement"`
+	Status          string               `bson:"status" json:"status"`
+	SubmittedAt     time.Time            `bson:"submittedAt" json:"submittedAt"`
+	VerifiedBy      string               `bson:"verifiedBy,omitempty" json:"verifiedBy,omitempty"`
+	VerifiedAt      *time.Time           `bson:"verifiedAt,omitempty" json:"verifiedAt,omitempty"`
+	ActionedBy      string               `bson:"actionedBy,omitempty" json:"actionedBy,omitempty"`
+	ActionedAt      *time.Time           `bson:"actionedAt,omitempty" json:"actionedAt,omitempty"`
+	RejectedReason  string               `bson:"rejectedReason,omitempty" json:"rejectedReason,omitempty"`
+	// filled in by the artist, the tracks are reinstated when ReinstateAfter
+	// passes unless the claimant goes to court in the meantime
+	CounterStatement string     `bson:"counterStatement,omitempty" json:"counterStatement,omitempty"`
+	CounterNoticedAt *time.Time `bson:"counterNoticedAt,omitempty" json:"counterNoticedAt,omitempty"`
+	ReinstateAfter   *time.Time `bson:"reinstateAfter,omitempty" json:"reinstateAfter,omitempty"`
+	ReinstatedAt     *time.Time `bson:"reinstatedAt,omitempty" json:"reinstatedAt,omitempty"`
+}
+
+// ArtistStrikes counts the actioned takedowns of an artist that were not
+// reinstated after a counter-notice
+type ArtistStrikes struct {
+	ArtistID  string    `bson:"_id" json:"artistId"`
+	Strikes   int       `bson:"strikes" json:"strikes"`
+	UpdatedAt time.Time `bson:"updatedAt" json:"updatedAt"`
+}
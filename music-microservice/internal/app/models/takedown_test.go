@@ -0,0 +1,79 @@
+package models
+
+import "testing"
+
+var takedownStatuses = []string{
+	TakedownSubmitted,
+	TakedownVerified,
+	TakedownActioned,
+	TakedownCounterNoticed,
+	TakedownReinstated,
+	TakedownRejected,
+}
+
+func TestTakedownTransitions(t *testing.T) {
+	cases := []struct {
+		name       string
+		transition TakedownTransition
+		from       []string
+		to         string
+	}{
+		{"verification", TakedownVerification, []string{TakedownSubmitted}, TakedownVerified},
+		{"rejection", TakedownRejection, []string{TakedownSubmitted, TakedownVerified}, TakedownRejected},
+		{"action", TakedownAction, []string{TakedownVerified}, TakedownActioned},
+		{"counter-notice", TakedownCounterNotice, []string{TakedownActioned}, TakedownCounterNoticed},
+		{"court action", TakedownCourtAction, []string{TakedownCounterNoticed}, TakedownActioned},
+		{"reinstatement", TakedownReinstatement, []string{TakedownCounterNoticed}, TakedownReinstated},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.transition.To != tc.to {
+				t.Fatalf("moves to %s, want %s", tc.transition.To, tc.to)
+			}
+
+			allowed := map[string]bool{}
+
+			for _, status := range tc.from {
+				allowed[status] = true
+			}
+
+			for _, status := range takedownStatuses {
+				if tc.transition.AppliesTo(status) != allowed[status] {
+					t.Fatalf("AppliesTo(%s) = %v, want %v", status, !allowed[status], allowed[status])
+				}
+			}
+		})
+	}
+}
+
+// A counter-noticed takedown goes back to actioned with a court action, it
+// must not be actioned a second time and give the artist another strike
+func TestCounterNoticedTakedownsCantBeActionedAgain(t *testing.T) {
+	if TakedownAction.AppliesTo(TakedownCounterNoticed) {
+		t.Fatal("a counter-noticed takedown can be actioned")
+	}
+
+	if TakedownAction.AppliesTo(TakedownActioned) {
+		t.Fatal("an actioned takedown can be actioned again")
+	}
+}
+
+func TestFinalTakedownStatuses(t *testing.T) {
+	transitions := []TakedownTransition{
+		TakedownVerification,
+		TakedownRejection,
+		TakedownAction,
+		TakedownCounterNotice,
+		TakedownCourtAction,
+		TakedownReinstatement,
+	}
+
+	for _, status := range []string{TakedownReinstated, TakedownRejected} {
+		for _, transition := range transitions {
+			if transition.AppliesTo(status) {
+				t.Fatalf("a %s takedown can be moved to %s", status, transition.To)
+			}
+		}
+	}
+}
@@ -0,0 +1,404 @@
+package app
+
+import (
+	"context"
+	"music-sharing/music-microservice/internal/app/models"
+	"music-sharing/music-microservice/internal/database"
+	"music-sharing/music-microservice/internal/events"
+	"music-sharing/shared/apperrors"
+	"music-sharing/shared/validation"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type (
+	ModerationController struct{}
+
+	HideMusicReq struct {
+		Reason string `json:"reason" validate:"required"`
+	}
+
+	ReportMusicReq struct {
+		Category string `json:"category" validate:"required,oneof=spam offensive copyright harassment other"`
+		Details  string `json:"details" validate:"max=2000"`
+	}
+
+	ResolveModerationCaseReq struct {
+		Action string `json:"action" validate:"required,oneof=dismiss hide remove"`
+		Note   string `json:"note"`
+	}
+)
+
+var (
+	reportsCollection         *mongo.Collection = database.OpenCollection("reports")
+	moderationCasesCollection *mongo.Collection = database.OpenCollection("moderation_cases")
+)
+
+func (ctrl *ModerationController) HideMusic(c *gin.Context) {
+	req := HideMusicReq{}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("musicId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := validation.Struct(req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	moderatorId := viewerIdOf(c)
+
+	err = database.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+		return hideMusic(sessCtx, id, moderatorId, req.Reason)
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+func (ctrl *ModerationController) UnhideMusic(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("musicId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	moderatorId := viewerIdOf(c)
+
+	err = database.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+		music, err := setMusicHidden(sessCtx, id, bson.M{
+			"hidden":       false,
+			"hiddenReason": "",
+			"hiddenBy":     "",
+			"hiddenAt":     nil,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		return events.Record(sessCtx, &events.MusicUnhidden{
+			MusicID:     music.ID.Hex(),
+			ArtistID:    music.ArtistID,
+			ModeratorID: moderatorId,
+		})
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+func hideMusic(sessCtx mongo.SessionContext, id primitive.ObjectID, moderatorId string, reason string) error {
+	music, err := setMusicHidden(sessCtx, id, bson.M{
+		"hidden":       true,
+		"hiddenReason": reason,
+		"hiddenBy":     moderatorId,
+		"hiddenAt":     time.Now().UTC(),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return events.Record(sessCtx, &events.MusicHidden{
+		MusicID:     music.ID.Hex(),
+		ArtistID:    music.ArtistID,
+		ModeratorID: moderatorId,
+		Reason:      reason,
+	})
+}
+
+func setMusicHidden(ctx context.Context, id primitive.ObjectID, fields bson.M) (*models.Music, error) {
+	music := &models.Music{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	err := musicsCollection.FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{"$set": fields}, opts).Decode(music)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return music, nil
+}
+
+func (ctrl *ModerationController) ReportMusic(c *gin.Context) {
+	req := ReportMusicReq{}
+	reporterId := viewerIdOf(c)
+
+	id, err := primitive.ObjectIDFromHex(c.Param("music_id"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := validation.Struct(req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	viewer, err := viewerOf(c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	var music models.Music
+
+	visible, err := visibleFilter(context.TODO(), viewer, bson.M{"_id": id})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = musicsCollection.FindOne(context.TODO(), visible).Decode(&music)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if music.ArtistID == reporterId {
+		c.Error(apperrors.Forbidden("you cant report your own music"))
+		return
+	}
+
+	err = database.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+		now := time.Now().UTC()
+		moderationCase := &models.ModerationCase{}
+		opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+		err := moderationCasesCollection.FindOneAndUpdate(sessCtx,
+			bson.M{"musicId": music.ID, "status": models.ModerationCaseOpen},
+			bson.M{
+				"$inc": bson.M{"reportCount": 1, "categories." + req.Category: 1},
+				"$set": bson.M{"lastReportedAt": now},
+				"$setOnInsert": bson.M{
+					"_id":             primitive.NewObjectID(),
+					"artistId":        music.ArtistID,
+					"firstReportedAt": now,
+				},
+			},
+			opts,
+		).Decode(moderationCase)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = reportsCollection.InsertOne(sessCtx, models.Report{
+			ID:         primitive.NewObjectID(),
+			MusicID:    music.ID,
+			ReporterID: reporterId,
+			Category:   req.Category,
+			Details:    req.Details,
+			CaseID:     moderationCase.ID,
+			CreatedAt:  now,
+		})
+
+		if mongo.IsDuplicateKeyError(err) {
+			return apperrors.Conflict("you already reported this music")
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return events.Record(sessCtx, &events.MusicReported{
+			MusicID:    music.ID.Hex(),
+			ArtistID:   music.ArtistID,
+			ReporterID: reporterId,
+			CaseID:     moderationCase.ID.Hex(),
+			Category:   req.Category,
+		})
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+// Open cases with the most reported tracks first, ?status=resolved lists the
+// history instead
+func (ctrl *ModerationController) GetModerationQueue(c *gin.Context) {
+	ctx := context.TODO()
+	status := c.DefaultQuery("status", models.ModerationCaseOpen)
+	opts := options.Find().SetSort(bson.D{{Key: "reportCount", Value: -1}, {Key: "lastReportedAt", Value: -1}})
+
+	if status == models.ModerationCaseResolved {
+		opts.SetSort(bson.D{{Key: "resolvedAt", Value: -1}})
+	}
+
+	result, err := moderationCasesCollection.Find(ctx, bson.M{"status": status}, opts)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	cases := []models.ModerationCase{}
+
+	if err := result.All(ctx, &cases); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, cases)
+}
+
+func (ctrl *ModerationController) GetModerationCase(c *gin.Context) {
+	ctx := context.TODO()
+
+	id, err := primitive.ObjectIDFromHex(c.Param("caseId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	moderationCase := models.ModerationCase{}
+
+	if err := moderationCasesCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&moderationCase); err != nil {
+		c.Error(err)
+		return
+	}
+
+	result, err := reportsCollection.Find(ctx, bson.M{"caseId": id}, options.Find().SetSort(bson.M{"createdAt": -1}))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	reports := []models.Report{}
+
+	if err := result.All(ctx, &reports); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"case":    moderationCase,
+		"reports": reports,
+	})
+}
+
+func (ctrl *ModerationController) ResolveModerationCase(c *gin.Context) {
+	req := ResolveModerationCaseReq{}
+	moderatorId := viewerIdOf(c)
+
+	id, err := primitive.ObjectIDFromHex(c.Param("caseId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := validation.Struct(req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = database.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+		now := time.Now().UTC()
+		moderationCase := &models.ModerationCase{}
+
+		// the status condition makes sure two moderators can't resolve the
+		// same case twice
+		err := moderationCasesCollection.FindOneAndUpdate(sessCtx,
+			bson.M{"_id": id, "status": models.ModerationCaseOpen},
+			bson.M{"$set": bson.M{
+				"status":     models.ModerationCaseResolved,
+				"action":     req.Action,
+				"note":       req.Note,
+				"resolvedBy": moderatorId,
+				"resolvedAt": now,
+			}},
+		).Decode(moderationCase)
+
+		if err == mongo.ErrNoDocuments {
+			return apperrors.Conflict("moderation case doesnt exist or is already resolved")
+		}
+
+		if err != nil {
+			return err
+		}
+
+		switch req.Action {
+		case models.ModerationActionHide:
+			reason := req.Note
+
+			if len(reason) == 0 {
+				reason = "hidden after being reported"
+			}
+
+			err = hideMusic(sessCtx, moderationCase.MusicID, moderatorId, reason)
+		case models.ModerationActionRemove:
+			err = removeMusic(sessCtx, bson.M{"_id": moderationCase.MusicID})
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return events.Record(sessCtx, &events.ModerationCaseResolved{
+			CaseID:      moderationCase.ID.Hex(),
+			MusicID:     moderationCase.MusicID.Hex(),
+			ArtistID:    moderationCase.ArtistID,
+			ModeratorID: moderatorId,
+			Action:      req.Action,
+		})
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"log"
+	"music-sharing/music-microservice/internal/app/models"
+	"music-sharing/music-microservice/internal/database"
+	"music-sharing/music-microservice/internal/events"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ReleaseWorker releases the scheduled tracks whose time has come. The
+// schedule lives on the tracks so nothing is lost on a restart, and a track
+// is released with a conditional update in the same transaction as its event
+// so several replicas can run the worker without releasing it twice
+type ReleaseWorker struct {
+	interval time.Duration
+}
+
+func NewReleaseWorker(interval time.Duration) *ReleaseWorker {
+	return &ReleaseWorker{
+		interval: interval,
+	}
+}
+
+func (w *ReleaseWorker) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := w.RunDue(ctx); err != nil {
+					log.Printf("release worker: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+func (w *ReleaseWorker) RunDue(ctx context.Context) error {
+	now := time.Now().UTC()
+	due := []models.Music{}
+	opts := options.Find().SetLimit(50).SetProjection(bson.M{"_id": 1})
+
+	result, err := musicsCollection.Find(ctx, bson.M{"releaseAt": bson.M{"$lte": now}}, opts)
+
+	if err != nil {
+		return err
+	}
+
+	if err := result.All(ctx, &due); err != nil {
+		return err
+	}
+
+	for _, music := range due {
+		err := database.WithTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+			return releaseMusic(sessCtx, music.ID, now)
+		})
+
+		if err != nil {
+			log.Printf("release %s: %v", music.ID.Hex(), err)
+		}
+	}
+
+	return nil
+}
+
+// Releases the track as public if it is still due, a replica that lost the
+// race finds nothing to update
+func releaseMusic(sessCtx mongo.SessionContext, id primitive.ObjectID, now time.Time) error {
+	var music models.Music
+
+	err := musicsCollection.FindOneAndUpdate(
+		sessCtx,
+		bson.M{"_id": id, "releaseAt": bson.M{"$lte": now}},
+		bson.M{
+			"$set":   bson.M{"visibility": models.VisibilityPublic},
+			"$unset": bson.M{"releaseAt": "", "shareToken": ""},
+			"$inc":   bson.M{"version": 1},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&music)
+
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return events.Record(sessCtx, &events.MusicReleased{
+		MusicID:    music.ID.Hex(),
+		ArtistID:   music.ArtistID,
+		Title:      music.Title,
+		Visibility: music.Visibility,
+	})
+}
@@ -0,0 +1,138 @@
+//go:build integration
+
+// The tests of this file run against the mongo replica set of MONGO_URI, e.g.
+// MONGO_URI="mongodb://localhost:27017/?replicaSet=rs0" go test -tags integration ./internal/app/
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"music-sharing/music-microservice/internal/app/models"
+	"music-sharing/music-microservice/internal/database"
+	"music-sharing/music-microservice/internal/events"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Inserts a scheduled track and removes it and its outbox events once the test
+// is over
+func insertScheduledMusic(t *testing.T, releaseAt time.Time) models.Music {
+	t.Helper()
+
+	ctx := context.Background()
+	music := models.Music{
+		ID:         primitive.NewObjectID(),
+		ArtistID:   primitive.NewObjectID().Hex(),
+		Title:      "Scheduled",
+		Visibility: models.VisibilityPublic,
+		ReleaseAt:  &releaseAt,
+	}
+
+	if _, err := musicsCollection.InsertOne(ctx, music); err != nil {
+		t.Fatalf("insert music: %v", err)
+	}
+
+	t.Cleanup(func() {
+		musicsCollection.DeleteOne(ctx, bson.M{"_id": music.ID})
+		database.OpenCollection("outbox").DeleteMany(ctx, bson.M{"aggregateId": music.ID.Hex()})
+	})
+
+	return music
+}
+
+func runRelease(t *testing.T, id primitive.ObjectID, now time.Time) {
+	t.Helper()
+
+	err := database.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		return releaseMusic(sessCtx, id, now)
+	})
+
+	if err != nil {
+		t.Fatalf("releaseMusic: %v", err)
+	}
+}
+
+func releasedEvents(t *testing.T, musicId primitive.ObjectID) []models.OutboxEvent {
+	t.Helper()
+
+	ctx := context.Background()
+	outboxEvents := []models.OutboxEvent{}
+
+	result, err := database.OpenCollection("outbox").Find(ctx, bson.M{"aggregateId": musicId.Hex(), "name": events.MusicReleasedEvent})
+
+	if err != nil {
+		t.Fatalf("find outbox events: %v", err)
+	}
+
+	if err := result.All(ctx, &outboxEvents); err != nil {
+		t.Fatalf("decode outbox events: %v", err)
+	}
+
+	return outboxEvents
+}
+
+func TestReleaseMusicReleasesTheDueTrackAsPublicOnce(t *testing.T) {
+	now := time.Now().UTC()
+	music := insertScheduledMusic(t, now.Add(-time.Minute))
+
+	runRelease(t, music.ID, now)
+
+	var released models.Music
+
+	if err := musicsCollection.FindOne(context.Background(), bson.M{"_id": music.ID}).Decode(&released); err != nil {
+		t.Fatalf("read back the track: %v", err)
+	}
+
+	if released.ReleaseAt != nil || released.Visibility != models.VisibilityPublic || released.Version != 1 {
+		t.Fatalf("released track = %+v, want it public, without releaseAt and at version 1", released)
+	}
+
+	outboxEvents := releasedEvents(t, music.ID)
+
+	if len(outboxEvents) != 1 {
+		t.Fatalf("recorded %d MusicReleased events, want 1", len(outboxEvents))
+	}
+
+	payload := &events.MusicReleased{}
+
+	if err := json.Unmarshal([]byte(outboxEvents[0].Payload), payload); err != nil {
+		t.Fatalf("decode the payload: %v", err)
+	}
+
+	if payload.MusicID != music.ID.Hex() || payload.ArtistID != music.ArtistID || payload.Visibility != models.VisibilityPublic {
+		t.Fatalf("payload = %+v, want the public release of %s", payload, music.ID.Hex())
+	}
+
+	// a replica that lost the race finds nothing left to release
+	runRelease(t, music.ID, now)
+
+	if outboxEvents := releasedEvents(t, music.ID); len(outboxEvents) != 1 {
+		t.Fatalf("recorded %d MusicReleased events after a second run, want 1", len(outboxEvents))
+	}
+}
+
+func TestReleaseMusicKeepsTheTrackUntilItIsDue(t *testing.T) {
+	now := time.Now().UTC()
+	music := insertScheduledMusic(t, now.Add(time.Hour))
+
+	runRelease(t, music.ID, now)
+
+	var scheduled models.Music
+
+	if err := musicsCollection.FindOne(context.Background(), bson.M{"_id": music.ID}).Decode(&scheduled); err != nil {
+		t.Fatalf("read back the track: %v", err)
+	}
+
+	if scheduled.ReleaseAt == nil || scheduled.Version != 0 {
+		t.Fatalf("track = %+v, want it still scheduled", scheduled)
+	}
+
+	if outboxEvents := releasedEvents(t, music.ID); len(outboxEvents) != 0 {
+		t.Fatalf("recorded %d MusicReleased events before the release time, want none", len(outboxEvents))
+	}
+}
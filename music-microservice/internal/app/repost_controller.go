@@ -0,0 +1,314 @@
+package app
+
+import (
+	"context"
+	"music-sharing/music-microservice/internal/app/models"
+	"music-sharing/music-microservice/internal/database"
+	"music-sharing/music-microservice/internal/events"
+	"music-sharing/shared/apperrors"
+	"music-sharing/shared/validation"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type (
+	RepostController struct{}
+
+	RepostMusicReq struct {
+		Caption string `json:"caption" validate:"max=500"`
+	}
+
+	RepostWithMusic struct {
+		models.Repost
+		Music models.Music `json:"music"`
+	}
+
+	// NextCursor is empty when there are no more reposts
+	RepostsPage struct {
+		Reposts    []RepostWithMusic `json:"reposts"`
+		NextCursor string            `json:"nextCursor"`
+	}
+)
+
+const (
+	defaultRepostsLimit = 20
+	maxRepostsLimit     = 100
+)
+
+var repostsCollection *mongo.Collection = database.OpenCollection("reposts")
+
+func (ctrl *RepostController) RepostMusic(c *gin.Context) {
+	req := RepostMusicReq{}
+	userId := viewerIdOf(c)
+
+	id, err := primitive.ObjectIDFromHex(c.Param("music_id"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := validation.Struct(req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	viewer, err := viewerOf(c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	var music models.Music
+
+	visible, err := visibleFilter(context.TODO(), viewer, bson.M{"_id": id})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := musicsCollection.FindOne(context.TODO(), visible).Decode(&music); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if music.ArtistID == userId {
+		c.Error(apperrors.Forbidden("you cant repost your own music"))
+		return
+	}
+
+	// only tracks everyone can see can be reposted, the owner sees their
+	// hidden and disabled tracks but can't spread them
+	if music.Hidden || len(music.TakedownIDs) != 0 || !music.IsPublic() {
+		c.Error(apperrors.Forbidden("this music cant be reposted"))
+		return
+	}
+
+	repost := models.Repost{
+		ID:        primitive.NewObjectID(),
+		MusicID:   music.ID,
+		UserID:    userId,
+		Caption:   req.Caption,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	err = database.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+		_, err := repostsCollection.InsertOne(sessCtx, repost)
+
+		if mongo.IsDuplicateKeyError(err) {
+			return apperrors.Conflict("you already reposted this music")
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if _, err := musicsCollection.UpdateOne(sessCtx, bson.M{"_id": music.ID}, bson.M{"$inc": bson.M{"repostCount": 1}}); err != nil {
+			return err
+		}
+
+		return events.Record(sessCtx, &events.MusicReposted{
+			RepostID: repost.ID.Hex(),
+			MusicID:  music.ID.Hex(),
+			ArtistID: music.ArtistID,
+			UserID:   userId,
+			Caption:  req.Caption,
+		})
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, repost)
+}
+
+func (ctrl *RepostController) UnrepostMusic(c *gin.Context) {
+	userId := viewerIdOf(c)
+
+	id, err := primitive.ObjectIDFromHex(c.Param("music_id"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = database.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+		removed, err := removeRepost(sessCtx, bson.M{"musicId": id, "userId": userId})
+
+		if err != nil {
+			return err
+		}
+
+		if !removed {
+			return apperrors.Conflict("you didnt repost this music")
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+// Reposts shown on the profile of a user, newest first. Reposts of tracks the
+// viewer can't see are left out
+func (ctrl *RepostController) GetReposts(c *gin.Context) {
+	ctx := context.TODO()
+	userId := c.Param("userId")
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	if limit < 1 {
+		limit = defaultRepostsLimit
+	}
+
+	if limit > maxRepostsLimit {
+		limit = maxRepostsLimit
+	}
+
+	viewer, err := viewerOf(c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	page := RepostsPage{Reposts: []RepostWithMusic{}}
+
+	for _, hiddenId := range viewer.HiddenArtistIDs {
+		if hiddenId == userId {
+			c.JSON(200, page)
+			return
+		}
+	}
+
+	filter := bson.M{"userId": userId}
+
+	if cursor := c.Query("cursor"); len(cursor) != 0 {
+		cursorId, err := primitive.ObjectIDFromHex(cursor)
+
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		filter["_id"] = bson.M{"$lt": cursorId}
+	}
+
+	// the visibility of the tracks is checked after the page is read, so a
+	// page can be shorter than the limit while there are more reposts
+	opts := options.Find().SetSort(bson.M{"_id": -1}).SetLimit(int64(limit + 1))
+
+	result, err := repostsCollection.Find(ctx, filter, opts)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	reposts := []models.Repost{}
+
+	if err := result.All(ctx, &reposts); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if len(reposts) > limit {
+		reposts = reposts[:limit]
+		page.NextCursor = reposts[limit-1].ID.Hex()
+	}
+
+	musicIds := []primitive.ObjectID{}
+
+	for _, repost := range reposts {
+		musicIds = append(musicIds, repost.MusicID)
+	}
+
+	visible, err := visibleFilter(ctx, viewer, bson.M{"_id": bson.M{"$in": musicIds}})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	musics, err := musicsCollection.Find(ctx, visible)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	visibleMusics := []models.Music{}
+
+	if err := musics.All(ctx, &visibleMusics); err != nil {
+		c.Error(err)
+		return
+	}
+
+	byId := map[primitive.ObjectID]models.Music{}
+
+	for _, music := range visibleMusics {
+		// the owner can see their hidden tracks but not through a repost
+		if !music.Hidden && len(music.TakedownIDs) == 0 && music.IsPublic() {
+			byId[music.ID] = music
+		}
+	}
+
+	for _, repost := range reposts {
+		if music, ok := byId[repost.MusicID]; ok {
+			page.Reposts = append(page.Reposts, RepostWithMusic{Repost: repost, Music: music})
+		}
+	}
+
+	c.JSON(200, page)
+}
+
+// Removes the repost matching filter and keeps the count of its track in sync
+func removeRepost(sessCtx mongo.SessionContext, filter bson.M) (bool, error) {
+	var repost models.Repost
+
+	err := repostsCollection.FindOneAndDelete(sessCtx, filter).Decode(&repost)
+
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	var music models.Music
+
+	err = musicsCollection.FindOneAndUpdate(sessCtx, bson.M{"_id": repost.MusicID}, bson.M{"$inc": bson.M{"repostCount": -1}}).Decode(&music)
+
+	if err != nil && err != mongo.ErrNoDocuments {
+		return false, err
+	}
+
+	return true, events.Record(sessCtx, &events.MusicUnreposted{
+		RepostID: repost.ID.Hex(),
+		MusicID:  repost.MusicID.Hex(),
+		ArtistID: music.ArtistID,
+		UserID:   repost.UserID,
+	})
+}
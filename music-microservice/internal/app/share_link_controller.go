@@ -0,0 +1,276 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"music-sharing/music-microservice/internal/app/models"
+	"music-sharing/music-microservice/internal/database"
+	"music-sharing/music-microservice/internal/lib"
+	"music-sharing/shared/apperrors"
+	"music-sharing/shared/validation"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type (
+	ShareLinkController struct{}
+
+	// MaxPlays 0 means unlimited
+	CreateShareLinkReq struct {
+		Label          string `json:"label" validate:"max=100"`
+		ExpiresInHours int    `json:"expiresInHours" validate:"required,min=1,max=2160"`
+		MaxPlays       int    `json:"maxPlays" validate:"min=0"`
+	}
+
+	ShareLinkWithToken struct {
+		models.ShareLink
+		Token string `json:"token"`
+		Url   string `json:"url"`
+	}
+)
+
+var shareLinksCollection *mongo.Collection = database.OpenCollection("share_links")
+
+// A play streams for as long as the track lasts so there is no overall
+// timeout. An origin that doesn't answer, or stops sending for audioStallTimeout
+// in the middle of a play, gives up the handler instead
+var audioClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 10 * time.Second}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 15 * time.Second,
+	},
+}
+
+const audioStallTimeout = 30 * time.Second
+
+// Cancels the request of the audio file when no byte was read from it for
+// timeout
+type stallReader struct {
+	body    io.Reader
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+func (r *stallReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.timer.Reset(r.timeout)
+
+	return n, err
+}
+
+func (ctrl *ShareLinkController) CreateShareLink(c *gin.Context) {
+	ownerId := c.Param("ownerId")
+	req := CreateShareLinkReq{}
+
+	musicId, err := primitive.ObjectIDFromHex(c.Param("musicId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := validation.Struct(req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	count, err := musicsCollection.CountDocuments(context.TODO(), bson.M{"_id": musicId, "artistId": ownerId})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if count == 0 {
+		c.Error(apperrors.NotFound("music doesnt exist"))
+		return
+	}
+
+	now := time.Now().UTC()
+
+	link := models.ShareLink{
+		ID:        primitive.NewObjectID(),
+		MusicID:   musicId,
+		OwnerID:   ownerId,
+		Label:     req.Label,
+		ExpiresAt: now.Add(time.Duration(req.ExpiresInHours) * time.Hour).Truncate(time.Second),
+		MaxPlays:  req.MaxPlays,
+		CreatedAt: now,
+	}
+
+	if _, err := shareLinksCollection.InsertOne(context.TODO(), link); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, withToken(link))
+}
+
+// The links of a track that can still be used
+func (ctrl *ShareLinkController) GetShareLinks(c *gin.Context) {
+	ctx := context.TODO()
+
+	musicId, err := primitive.ObjectIDFromHex(c.Param("musicId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	filter := bson.M{
+		"musicId":   musicId,
+		"ownerId":   c.Param("ownerId"),
+		"revokedAt": bson.M{"$exists": false},
+		"expiresAt": bson.M{"$gt": time.Now().UTC()},
+	}
+
+	result, err := shareLinksCollection.Find(ctx, filter, options.Find().SetSort(bson.M{"_id": -1}))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	links := []models.ShareLink{}
+
+	if err := result.All(ctx, &links); err != nil {
+		c.Error(err)
+		return
+	}
+
+	active := []ShareLinkWithToken{}
+
+	for _, link := range links {
+		if link.MaxPlays == 0 || link.Plays < link.MaxPlays {
+			active = append(active, withToken(link))
+		}
+	}
+
+	c.JSON(200, active)
+}
+
+func (ctrl *ShareLinkController) RevokeShareLink(c *gin.Context) {
+	linkId, err := primitive.ObjectIDFromHex(c.Param("linkId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	filter := bson.M{"_id": linkId, "ownerId": c.Param("ownerId"), "revokedAt": bson.M{"$exists": false}}
+
+	res, err := shareLinksCollection.UpdateOne(context.TODO(), filter, bson.M{"$set": bson.M{"revokedAt": time.Now().UTC()}})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if res.MatchedCount == 0 {
+		c.Error(apperrors.NotFound("share link doesnt exist or is already revoked"))
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+// Counts a play against the link, the check and the increment are one update
+// so concurrent plays can't go over MaxPlays
+func consumeSharePlay(link *models.ShareLink) error {
+	filter := bson.M{
+		"_id":       link.ID,
+		"revokedAt": bson.M{"$exists": false},
+		"expiresAt": bson.M{"$gt": time.Now().UTC()},
+		"$or": bson.A{
+			bson.M{"maxPlays": 0},
+			bson.M{"$expr": bson.M{"$lt": bson.A{"$plays", "$maxPlays"}}},
+		},
+	}
+
+	res, err := shareLinksCollection.UpdateOne(context.TODO(), filter, bson.M{"$inc": bson.M{"plays": 1}})
+
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return apperrors.Forbidden("share link has no plays left")
+	}
+
+	return nil
+}
+
+// Plays of the tracks only reachable through a share link are streamed by the
+// service instead of redirected, the url of the file would outlive the link.
+// The range of the player is passed on so it can still seek
+func proxyAudio(c *gin.Context, fileUrl string) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	stalled := time.AfterFunc(audioStallTimeout, cancel)
+	defer stalled.Stop()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fileUrl, nil)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if byteRange := c.GetHeader("Range"); len(byteRange) != 0 {
+		req.Header.Set("Range", byteRange)
+	}
+
+	res, err := audioClient.Do(req)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusPartialContent {
+		c.Error(fmt.Errorf("GET of the audio file failed with status %d", res.StatusCode))
+		return
+	}
+
+	headers := map[string]string{"Cache-Control": "no-store"}
+
+	for _, name := range []string{"Accept-Ranges", "Content-Range"} {
+		if value := res.Header.Get(name); len(value) != 0 {
+			headers[name] = value
+		}
+	}
+
+	body := &stallReader{body: res.Body, timer: stalled, timeout: audioStallTimeout}
+
+	c.DataFromReader(res.StatusCode, res.ContentLength, res.Header.Get("Content-Type"), body, headers)
+}
+
+func withToken(link models.ShareLink) ShareLinkWithToken {
+	token := lib.SignShareToken(link.ID.Hex(), link.ExpiresAt)
+
+	return ShareLinkWithToken{
+		ShareLink: link,
+		Token:     token,
+		Url:       "/v1/musics/" + link.MusicID.Hex() + "?token=" + token,
+	}
+}
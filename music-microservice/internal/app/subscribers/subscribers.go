@@ -0,0 +1,136 @@
+package subscribers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"music-sharing/music-microservice/internal/events"
+	"music-sharing/shared/internalapi"
+	"os"
+	"strconv"
+)
+
+// Register wires the reactions of music-microservice to the domain events
+func Register(broker events.Broker) {
+	broker.Subscribe(events.TakedownActionedEvent, onTakedownActioned)
+	broker.Subscribe(events.TakedownCounterNoticedEvent, onTakedownCounterNoticed)
+	broker.Subscribe(events.TakedownReinstatedEvent, onTakedownReinstated)
+
+	// user-microservice builds the activity feeds and the notifications from
+	// these
+	forwarded := []string{
+		events.MusicUploadedEvent,
+		events.MusicReleasedEvent,
+		events.MusicLikedEvent,
+		events.MusicCommentedEvent,
+		events.MusicRepostedEvent,
+		events.MusicUnrepostedEvent,
+		events.MusicDeletedEvent,
+		events.MusicVisibilityChangedEvent,
+		events.MusicHiddenEvent,
+		events.MusicUnhiddenEvent,
+		events.CaseResolvedEvent,
+		events.TakedownActionedEvent,
+		events.TakedownReinstatedEvent,
+	}
+
+	for _, topic := range forwarded {
+		broker.Subscribe(topic, forwardToUserService)
+	}
+}
+
+// Delivers the event to the broker of user-microservice, a failed delivery is
+// retried by the outbox relay
+func forwardToUserService(message []byte) error {
+	url := os.Getenv("USER_SERVICE") + "/internal/events"
+
+	return internalapi.Send("POST", url, json.RawMessage(message), nil)
+}
+
+func decode(message []byte, payload interface{}) (*events.Envelope, error) {
+	envelope := &events.Envelope{}
+
+	if err := json.Unmarshal(message, envelope); err != nil {
+		return nil, err
+	}
+
+	return envelope, json.Unmarshal(envelope.Payload, payload)
+}
+
+// Read from REPEAT_INFRINGER_STRIKES, 3 by default
+func repeatInfringerStrikes() int {
+	if strikes, err := strconv.Atoi(os.Getenv("REPEAT_INFRINGER_STRIKES")); err == nil && strikes > 0 {
+		return strikes
+	}
+
+	return 3
+}
+
+// The id of the event is sent along so user-microservice mails the notice only
+// once when the event is redelivered
+func notifyUser(eventId string, userId string, subject string, message string) error {
+	url := os.Getenv("USER_SERVICE") + "/internal/users/" + userId + "/notify"
+
+	return internalapi.Send("POST", url, map[string]string{
+		"eventId": eventId,
+		"subject": subject,
+		"message": message,
+	}, nil)
+}
+
+// The suspension goes first, it is skipped by user-microservice when the
+// account is already suspended so both steps are safe to retry
+func onTakedownActioned(message []byte) error {
+	event := &events.TakedownActioned{}
+	envelope, err := decode(message, event)
+
+	if err != nil {
+		return err
+	}
+
+	if event.Strikes >= repeatInfringerStrikes() {
+		log.Printf("artist %s reached %d copyright strikes, suspending the account", event.ArtistID, event.Strikes)
+
+		url := os.Getenv("USER_SERVICE") + "/internal/users/" + event.ArtistID + "/suspend"
+
+		err = internalapi.Send("POST", url, map[string]string{
+			"reason": "repeat copyright infringement",
+		}, nil)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return notifyUser(envelope.ID, event.ArtistID, "Your music was taken down",
+		fmt.Sprintf("%d of your musics were disabled after a copyright takedown (%s). You can file a counter-notice if you believe this is a mistake. You now have %d strike(s).",
+			len(event.MusicIDs), event.TakedownID, event.Strikes),
+	)
+}
+
+func onTakedownCounterNoticed(message []byte) error {
+	event := &events.TakedownCounterNoticed{}
+	envelope, err := decode(message, event)
+
+	if err != nil {
+		return err
+	}
+
+	return notifyUser(envelope.ID, event.ClaimantID, "A counter-notice was filed against your takedown",
+		fmt.Sprintf("The artist filed a counter-notice against your takedown (%s). The musics will be reinstated after %s unless you tell us you started a court action.",
+			event.TakedownID, event.ReinstateAfter.Format("2006-01-02 15:04 MST")),
+	)
+}
+
+func onTakedownReinstated(message []byte) error {
+	event := &events.TakedownReinstated{}
+	envelope, err := decode(message, event)
+
+	if err != nil {
+		return err
+	}
+
+	return notifyUser(envelope.ID, event.ArtistID, "Your music was reinstated",
+		fmt.Sprintf("The musics of the takedown %s are available again and its strike was removed.", event.TakedownID),
+	)
+}
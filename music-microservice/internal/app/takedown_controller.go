@@ -0,0 +1,525 @@
+package app
+
+import (
+	"context"
+	"music-sharing/music-microservice/internal/app/models"
+	"music-sharing/music-microservice/internal/database"
+	"music-sharing/music-microservice/internal/events"
+	"music-sharing/shared/apperrors"
+	"music-sharing/shared/permissions"
+	"music-sharing/shared/validation"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type (
+	TakedownController struct{}
+
+	SubmitTakedownReq struct {
+		MusicIDs        []string `json:"musicIds" validate:"required,min=1,max=50,dive,required"`
+		ClaimantName    string   `json:"claimantName" validate:"required"`
+		ClaimantEmail   string   `json:"claimantEmail" validate:"required,email"`
+		CopyrightedWork string   `json:"copyrightedWork" validate:"required"`
+		Statement       string   `json:"statement" validate:"required"`
+		// the claimant must state in good faith that the use is not authorized
+		GoodFaith bool `json:"goodFaith" validate:"eq=true"`
+	}
+
+	RejectTakedownReq struct {
+		Reason string `json:"reason" validate:"required"`
+	}
+
+	CounterNoticeReq struct {
+		Statement string `json:"statement" validate:"required"`
+		// the artist must consent to the jurisdiction of the courts
+		ConsentToJurisdiction bool `json:"consentToJurisdiction" validate:"eq=true"`
+	}
+)
+
+var (
+	takedownsCollection     *mongo.Collection = database.OpenCollection("takedowns")
+	artistStrikesCollection *mongo.Collection = database.OpenCollection("artist_strikes")
+)
+
+// Read from TAKEDOWN_COUNTER_NOTICE_WINDOW, 14 days by default
+func counterNoticeWindow() time.Duration {
+	if window, err := time.ParseDuration(os.Getenv("TAKEDOWN_COUNTER_NOTICE_WINDOW")); err == nil && window > 0 {
+		return window
+	}
+
+	return 14 * 24 * time.Hour
+}
+
+func hexIds(ids []primitive.ObjectID) []string {
+	hexes := make([]string, 0, len(ids))
+
+	for _, id := range ids {
+		hexes = append(hexes, id.Hex())
+	}
+
+	return hexes
+}
+
+func (ctrl *TakedownController) SubmitTakedown(c *gin.Context) {
+	req := SubmitTakedownReq{}
+	claimantId := viewerIdOf(c)
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := validation.Struct(req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	musicIds := []primitive.ObjectID{}
+
+	for _, musicId := range req.MusicIDs {
+		id, err := primitive.ObjectIDFromHex(musicId)
+
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		musicIds = append(musicIds, id)
+	}
+
+	ctx := context.TODO()
+	musics := []models.Music{}
+
+	// blocks dont apply, a rights holder can always claim their work
+	result, err := musicsCollection.Find(ctx, visibleTo(&viewer{ID: claimantId}, bson.M{"_id": bson.M{"$in": musicIds}}))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := result.All(ctx, &musics); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if len(musics) != len(musicIds) {
+		c.Error(apperrors.NotFound("some of the musics dont exist"))
+		return
+	}
+
+	for _, music := range musics {
+		if music.ArtistID != musics[0].ArtistID {
+			c.Error(apperrors.Validation("a takedown can only target the musics of one artist"))
+			return
+		}
+	}
+
+	takedown := models.Takedown{
+		ID:              primitive.NewObjectID(),
+		MusicIDs:        musicIds,
+		ArtistID:        musics[0].ArtistID,
+		ClaimantID:      claimantId,
+		ClaimantName:    req.ClaimantName,
+		ClaimantEmail:   req.ClaimantEmail,
+		CopyrightedWork: req.CopyrightedWork,
+		Statement:       req.Statement,
+		Status:          models.TakedownSubmitted,
+		SubmittedAt:     time.Now().UTC(),
+	}
+
+	err = database.WithTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		if _, err := takedownsCollection.InsertOne(sessCtx, takedown); err != nil {
+			return err
+		}
+
+		return events.Record(sessCtx, &events.TakedownSubmitted{
+			TakedownID: takedown.ID.Hex(),
+			MusicIDs:   req.MusicIDs,
+			ArtistID:   takedown.ArtistID,
+			ClaimantID: claimantId,
+		})
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success":    true,
+		"takedownId": takedown.ID.Hex(),
+	})
+}
+
+// Lists the takedowns for moderators, filtered with ?status=
+func (ctrl *TakedownController) GetTakedowns(c *gin.Context) {
+	filter := bson.M{}
+
+	if status := c.Query("status"); len(status) != 0 {
+		filter["status"] = status
+	}
+
+	findTakedowns(c, filter)
+}
+
+// Takedowns against the tracks of the authenticated artist
+func (ctrl *TakedownController) GetMyTakedowns(c *gin.Context) {
+	findTakedowns(c, bson.M{"artistId": viewerIdOf(c)})
+}
+
+func findTakedowns(c *gin.Context, filter bson.M) {
+	ctx := context.TODO()
+	opts := options.Find().SetSort(bson.M{"submittedAt": -1})
+
+	result, err := takedownsCollection.Find(ctx, filter, opts)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	takedowns := []models.Takedown{}
+
+	if err := result.All(ctx, &takedowns); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, takedowns)
+}
+
+// Visible to moderators and to the two parties of the takedown
+func (ctrl *TakedownController) GetTakedown(c *gin.Context) {
+	identity := identityOf(c)
+
+	id, err := primitive.ObjectIDFromHex(c.Param("takedownId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	takedown := models.Takedown{}
+
+	if err := takedownsCollection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&takedown); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if takedown.ArtistID != identity.ID && takedown.ClaimantID != identity.ID && !identity.Can(permissions.ModerateTracks) {
+		c.Error(apperrors.Forbidden("you are not authorized"))
+		return
+	}
+
+	c.JSON(200, takedown)
+}
+
+func (ctrl *TakedownController) VerifyTakedown(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("takedownId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	_, err = transitionTakedown(context.TODO(), id, models.TakedownVerification, bson.M{
+		"verifiedBy": viewerIdOf(c),
+		"verifiedAt": time.Now().UTC(),
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+func (ctrl *TakedownController) RejectTakedown(c *gin.Context) {
+	req := RejectTakedownReq{}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("takedownId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := validation.Struct(req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = database.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+		takedown, err := transitionTakedown(sessCtx, id, models.TakedownRejection, bson.M{
+			"rejectedReason": req.Reason,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		return events.Record(sessCtx, &events.TakedownRejected{
+			TakedownID: takedown.ID.Hex(),
+			ClaimantID: takedown.ClaimantID,
+			Reason:     req.Reason,
+		})
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+// Disables the tracks of a verified takedown and gives the artist a strike
+func (ctrl *TakedownController) ActionTakedown(c *gin.Context) {
+	moderatorId := viewerIdOf(c)
+
+	id, err := primitive.ObjectIDFromHex(c.Param("takedownId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = database.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+		now := time.Now().UTC()
+
+		takedown, err := transitionTakedown(sessCtx, id, models.TakedownAction, bson.M{
+			"actionedBy": moderatorId,
+			"actionedAt": now,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		_, err = musicsCollection.UpdateMany(sessCtx,
+			bson.M{"_id": bson.M{"$in": takedown.MusicIDs}},
+			bson.M{"$addToSet": bson.M{"takedownIds": takedown.ID}},
+		)
+
+		if err != nil {
+			return err
+		}
+
+		strikes, err := addArtistStrikes(sessCtx, takedown.ArtistID, 1)
+
+		if err != nil {
+			return err
+		}
+
+		return events.Record(sessCtx, &events.TakedownActioned{
+			TakedownID:  takedown.ID.Hex(),
+			MusicIDs:    hexIds(takedown.MusicIDs),
+			ArtistID:    takedown.ArtistID,
+			ModeratorID: moderatorId,
+			Strikes:     strikes,
+		})
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+// Filed by the artist, starts the counter-notice window after which the
+// tracks are reinstated
+func (ctrl *TakedownController) FileCounterNotice(c *gin.Context) {
+	req := CounterNoticeReq{}
+	artistId := viewerIdOf(c)
+
+	id, err := primitive.ObjectIDFromHex(c.Param("takedownId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := validation.Struct(req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = database.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) error {
+		now := time.Now().UTC()
+		reinstateAfter := now.Add(counterNoticeWindow())
+		takedown := &models.Takedown{}
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+		err := takedownsCollection.FindOneAndUpdate(sessCtx,
+			// only one counter-notice per takedown
+			bson.M{"_id": id, "artistId": artistId, "status": bson.M{"$in": models.TakedownCounterNotice.From}, "counterNoticedAt": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{
+				"status":           models.TakedownCounterNotice.To,
+				"counterStatement": req.Statement,
+				"counterNoticedAt": now,
+				"reinstateAfter":   reinstateAfter,
+			}},
+			opts,
+		).Decode(takedown)
+
+		if err == mongo.ErrNoDocuments {
+			return apperrors.Conflict("takedown doesnt exist or cant receive a counter-notice")
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return events.Record(sessCtx, &events.TakedownCounterNoticed{
+			TakedownID:     takedown.ID.Hex(),
+			ArtistID:       takedown.ArtistID,
+			ClaimantID:     takedown.ClaimantID,
+			ReinstateAfter: reinstateAfter,
+		})
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+// Recorded by a moderator when the claimant shows a court action was filed
+// before the window passed, the tracks stay disabled
+func (ctrl *TakedownController) RecordCourtAction(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("takedownId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	_, err = transitionTakedown(context.TODO(), id, models.TakedownCourtAction, bson.M{
+		"reinstateAfter": nil,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+// Applies the transition to the takedown and sets the other fields of set.
+// The status condition makes concurrent transitions safe
+func transitionTakedown(ctx context.Context, id primitive.ObjectID, transition models.TakedownTransition, set bson.M) (*models.Takedown, error) {
+	takedown := &models.Takedown{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	set["status"] = transition.To
+
+	err := takedownsCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "status": bson.M{"$in": transition.From}},
+		bson.M{"$set": set},
+		opts,
+	).Decode(takedown)
+
+	if err == mongo.ErrNoDocuments {
+		return nil, apperrors.Conflict("takedown doesnt exist or cant be moved to " + transition.To)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return takedown, nil
+}
+
+// Returns the number of strikes of the artist after the change
+func addArtistStrikes(ctx context.Context, artistId string, delta int) (int, error) {
+	strikes := &models.ArtistStrikes{}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	err := artistStrikesCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": artistId},
+		bson.M{
+			"$inc": bson.M{"strikes": delta},
+			"$set": bson.M{"updatedAt": time.Now().UTC()},
+		},
+		opts,
+	).Decode(strikes)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return strikes.Strikes, nil
+}
+
+// Reinstates the counter-noticed takedown if its window has passed, returns
+// false when there was nothing to reinstate
+func reinstateTakedown(sessCtx mongo.SessionContext, id primitive.ObjectID, now time.Time) (bool, error) {
+	takedown := &models.Takedown{}
+
+	err := takedownsCollection.FindOneAndUpdate(sessCtx,
+		bson.M{"_id": id, "status": bson.M{"$in": models.TakedownReinstatement.From}, "reinstateAfter": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"status": models.TakedownReinstatement.To, "reinstatedAt": now}},
+	).Decode(takedown)
+
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	_, err = musicsCollection.UpdateMany(sessCtx,
+		bson.M{"_id": bson.M{"$in": takedown.MusicIDs}},
+		bson.M{"$pull": bson.M{"takedownIds": takedown.ID}},
+	)
+
+	if err != nil {
+		return false, err
+	}
+
+	// a successful counter-notice removes the strike of the takedown
+	if _, err := addArtistStrikes(sessCtx, takedown.ArtistID, -1); err != nil {
+		return false, err
+	}
+
+	return true, events.Record(sessCtx, &events.TakedownReinstated{
+		TakedownID: takedown.ID.Hex(),
+		MusicIDs:   hexIds(takedown.MusicIDs),
+		ArtistID:   takedown.ArtistID,
+		ClaimantID: takedown.ClaimantID,
+	})
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"context"
+	"log"
+	"music-sharing/music-microservice/internal/app/models"
+	"music-sharing/music-microservice/internal/database"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TakedownWorker reinstates the tracks of the counter-noticed takedowns whose
+// window has passed. Each takedown is moved with a conditional update so
+// several replicas can run the worker
+type TakedownWorker struct {
+	interval time.Duration
+}
+
+func NewTakedownWorker(interval time.Duration) *TakedownWorker {
+	return &TakedownWorker{
+		interval: interval,
+	}
+}
+
+func (w *TakedownWorker) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := w.RunDue(ctx); err != nil {
+					log.Printf("takedown worker: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+func (w *TakedownWorker) RunDue(ctx context.Context) error {
+	now := time.Now().UTC()
+	due := []models.Takedown{}
+	opts := options.Find().SetLimit(50).SetProjection(bson.M{"_id": 1})
+
+	result, err := takedownsCollection.Find(ctx, bson.M{"status": bson.M{"$in": models.TakedownReinstatement.From}, "reinstateAfter": bson.M{"$lte": now}}, opts)
+
+	if err != nil {
+		return err
+	}
+
+	if err := result.All(ctx, &due); err != nil {
+		return err
+	}
+
+	for _, takedown := range due {
+		err := database.WithTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+			_, err := reinstateTakedown(sessCtx, takedown.ID, now)
+			return err
+		})
+
+		if err != nil {
+			log.Printf("takedown %s: %v", takedown.ID.Hex(), err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,271 @@
+package app
+
+import (
+	"context"
+	"music-sharing/music-microservice/internal/app/models"
+	"music-sharing/music-microservice/internal/lib"
+	"music-sharing/shared/apperrors"
+	"music-sharing/shared/permissions"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Who a request is made by. Handlers read the caller from it instead of the
+// token claims, anonymous viewers have no ID. Everything the visibility rules
+// need to know about the viewer is here too
+type viewer struct {
+	ID   string
+	Role string
+	// artists the viewer blocked or was blocked by
+	HiddenArtistIDs []string
+	// artists whose followers only tracks the viewer can see, only the artists
+	// in checkedArtistIDs were asked about
+	FollowedArtistIDs []string
+	checkedArtistIDs  map[string]bool
+	// secret of the share link the track was opened with, it unlocks one
+	// unlisted track
+	ShareToken string
+	// signed share link the request was made with instead of a user token, it
+	// unlocks its track whatever the visibility
+	ShareLink *models.ShareLink
+	// the track of ShareLink can only be seen through it, its plays are
+	// counted so its file is kept from the viewer
+	LinkOnly bool
+}
+
+func (v *viewer) IsAnonymous() bool {
+	return len(v.ID) == 0
+}
+
+func (v *viewer) Can(permission string) bool {
+	return !v.IsAnonymous() && permissions.HasPermission(v.Role, permission)
+}
+
+// Viewers that only reach a track through a share link play it from the
+// stream, which counts the plays, and never get the url of its file
+func (v *viewer) CanReadFileOf(musicId primitive.ObjectID) bool {
+	return !v.LinkOnly || v.ShareLink.MusicID != musicId
+}
+
+// The caller of the request and its role, as the auth middlewares loaded them.
+// Without a viewer context the caller is anonymous and can do nothing
+func identityOf(c *gin.Context) *viewer {
+	claims, exists := c.Get("user")
+
+	if !exists {
+		return &viewer{}
+	}
+
+	userClaims, ok := claims.(jwt.MapClaims)
+
+	if !ok {
+		return &viewer{}
+	}
+
+	viewerContext, exists := c.Get("viewer_context")
+
+	if !exists {
+		return &viewer{}
+	}
+
+	viewerId, _ := userClaims["userId"].(string)
+
+	return &viewer{ID: viewerId, Role: viewerContext.(*lib.ViewerContext).Role}
+}
+
+// Id of the authenticated user, empty when the request has no valid token
+func viewerIdOf(c *gin.Context) string {
+	return identityOf(c).ID
+}
+
+// Loads the viewer of the request once and keeps it in the context. The block
+// list comes from the viewer context of the token, the followed artists are
+// asked for by visibleFilter
+func viewerOf(c *gin.Context) (*viewer, error) {
+	if cached, exists := c.Get("viewer"); exists {
+		return cached.(*viewer), nil
+	}
+
+	v := identityOf(c)
+	v.ShareToken = c.Query("share")
+
+	if viewerContext, exists := c.Get("viewer_context"); exists && !v.IsAnonymous() {
+		v.HiddenArtistIDs = viewerContext.(*lib.ViewerContext).HiddenUserIDs
+	}
+
+	if token, exists := c.Get("share_token"); exists {
+		link, err := shareLinkOf(token.(string))
+
+		if err != nil {
+			return nil, err
+		}
+
+		// the viewer may see the track without the link, e.g. a public one
+		visible, err := visibleFilter(context.TODO(), v, bson.M{"_id": link.MusicID})
+
+		if err != nil {
+			return nil, err
+		}
+
+		count, err := musicsCollection.CountDocuments(context.TODO(), visible)
+
+		if err != nil {
+			return nil, err
+		}
+
+		v.ShareLink = link
+		v.LinkOnly = count == 0
+	}
+
+	c.Set("viewer", v)
+
+	return v, nil
+}
+
+// The share link of a token, as long as it is not expired, revoked or out of
+// plays
+func shareLinkOf(token string) (*models.ShareLink, error) {
+	linkId, err := lib.ParseShareToken(token)
+
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := primitive.ObjectIDFromHex(linkId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var link models.ShareLink
+
+	err = shareLinksCollection.FindOne(context.TODO(), bson.M{"_id": id, "revokedAt": bson.M{"$exists": false}}).Decode(&link)
+
+	if err == mongo.ErrNoDocuments {
+		return nil, apperrors.Forbidden("share link was revoked")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if link.MaxPlays != 0 && link.Plays >= link.MaxPlays {
+		return nil, apperrors.Forbidden("share link has no plays left")
+	}
+
+	return &link, nil
+}
+
+// visibleTo for a viewer whose follows of the artists of the followers only
+// tracks of filter are known. They are asked to user-microservice, once per
+// artist and request, so a viewer following many artists sees all of them
+func visibleFilter(ctx context.Context, v *viewer, filter bson.M) (bson.M, error) {
+	if v.IsAnonymous() {
+		return visibleTo(v, filter), nil
+	}
+
+	artistIds, err := musicsCollection.Distinct(ctx, "artistId", bson.M{"$and": bson.A{
+		filter,
+		bson.M{"visibility": models.VisibilityFollowers, "artistId": bson.M{"$ne": v.ID}},
+	}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if v.checkedArtistIDs == nil {
+		v.checkedArtistIDs = map[string]bool{}
+	}
+
+	unchecked := []string{}
+
+	for _, artistId := range artistIds {
+		if id, ok := artistId.(string); ok && !v.checkedArtistIDs[id] {
+			unchecked = append(unchecked, id)
+		}
+	}
+
+	if len(unchecked) != 0 {
+		followed, err := lib.GetFollowedUsers(v.ID, unchecked)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range unchecked {
+			v.checkedArtistIDs[id] = true
+		}
+
+		v.FollowedArtistIDs = append(v.FollowedArtistIDs, followed...)
+	}
+
+	return visibleTo(v, filter), nil
+}
+
+// Restricts a musics filter to the tracks the viewer is allowed to see. Every
+// read path goes through it so the rules live in one place. Only the followers
+// only tracks of FollowedArtistIDs are let through, see visibleFilter
+func visibleTo(v *viewer, filter bson.M) bson.M {
+	// tracks without a visibility predate it and are public
+	audience := bson.A{
+		bson.M{"visibility": bson.M{"$in": bson.A{nil, models.VisibilityPublic}}},
+	}
+
+	if len(v.FollowedArtistIDs) != 0 {
+		audience = append(audience, bson.M{
+			"visibility": models.VisibilityFollowers,
+			"artistId":   bson.M{"$in": v.FollowedArtistIDs},
+		})
+	}
+
+	if len(v.ShareToken) != 0 {
+		audience = append(audience, bson.M{
+			"visibility": models.VisibilityUnlisted,
+			"shareToken": v.ShareToken,
+		})
+	}
+
+	reachable := bson.A{
+		bson.M{"releaseAt": bson.M{"$exists": false}, "$or": audience},
+	}
+
+	// share links are also given out for unreleased tracks
+	if v.ShareLink != nil {
+		reachable = append(reachable, bson.M{"_id": v.ShareLink.MusicID})
+	}
+
+	// owners see all of their tracks whatever their state
+	rules := bson.A{
+		filter,
+		bson.M{"$or": bson.A{
+			bson.M{
+				"hidden":        bson.M{"$ne": true},
+				"takedownIds.0": bson.M{"$exists": false},
+				"$or":           reachable,
+			},
+			bson.M{"artistId": v.ID},
+		}},
+	}
+
+	if len(v.HiddenArtistIDs) != 0 {
+		rules = append(rules, bson.M{"artistId": bson.M{"$nin": v.HiddenArtistIDs}})
+	}
+
+	return bson.M{"$and": rules}
+}
+
+// Leaves out the comments of the users the viewer blocked or was blocked by
+func commentsVisibleTo(v *viewer, filter bson.M) bson.M {
+	if len(v.HiddenArtistIDs) == 0 {
+		return filter
+	}
+
+	return bson.M{"$and": bson.A{
+		filter,
+		bson.M{"authorId": bson.M{"$nin": v.HiddenArtistIDs}},
+	}}
+}
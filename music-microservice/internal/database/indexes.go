@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Creates the indexes the services rely on, it is safe to call on every start
+func EnsureIndexes() error {
+
+	indexes := map[string][]mongo.IndexModel{
+		"likes": {
+			{Keys: bson.D{{Key: "musicId", Value: 1}, {Key: "userId", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "userId", Value: 1}}},
+		},
+		"listens": {
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "listenedAt", Value: -1}}},
+			{Keys: bson.D{{Key: "listenedAt", Value: -1}}},
+		},
+		"musics": {
+			{Keys: bson.D{{Key: "artistId", Value: 1}}},
+			{Keys: bson.D{{Key: "releaseAt", Value: 1}}, Options: options.Index().SetSparse(true)},
+		},
+		"reports": {
+			{Keys: bson.D{{Key: "musicId", Value: 1}, {Key: "reporterId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		},
+		"moderation_cases": {
+			// only one open case per track, resolved ones are kept as history
+			{
+				Keys:    bson.D{{Key: "musicId", Value: 1}},
+				Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"status": "open"}),
+			},
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "reportCount", Value: -1}}},
+		},
+		"reposts": {
+			{Keys: bson.D{{Key: "musicId", Value: 1}, {Key: "userId", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "_id", Value: -1}}},
+		},
+		"comments": {
+			{Keys: bson.D{{Key: "musicId", Value: 1}, {Key: "parentId", Value: 1}, {Key: "_id", Value: -1}}},
+			{Keys: bson.D{{Key: "threadId", Value: 1}, {Key: "_id", Value: 1}}},
+			{Keys: bson.D{{Key: "authorId", Value: 1}}},
+		},
+		"share_links": {
+			{Keys: bson.D{{Key: "musicId", Value: 1}, {Key: "_id", Value: -1}}},
+		},
+		"idempotency_keys": {
+			{Keys: bson.D{{Key: "key", Value: 1}, {Key: "scope", Value: 1}}, Options: options.Index().SetUnique(true)},
+			// mongo drops the keys once their window ended
+			{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+		"takedowns": {
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "reinstateAfter", Value: 1}}},
+			{Keys: bson.D{{Key: "artistId", Value: 1}}},
+		},
+	}
+
+	for collectionName, models := range indexes {
+		if _, err := OpenCollection(collectionName).Indexes().CreateMany(context.TODO(), models); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -41,3 +41,22 @@ var Client *mongo.Client = initDB()
 func OpenCollection(collectionName string) *mongo.Collection {
 	return Client.Database("music-sharing").Collection(collectionName)
 }
+
+// Runs fn inside a mongo transaction, the session context passed to fn must be
+// used for every operation that should be part of the transaction. Mongo only
+// runs transactions on a replica set, a standalone server fails them
+func WithTransaction(ctx context.Context, fn func(sessCtx mongo.SessionContext) error) error {
+	session, err := Client.StartSession()
+
+	if err != nil {
+		return err
+	}
+
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+
+	return err
+}
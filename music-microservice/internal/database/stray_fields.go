@@ -0,0 +1,33 @@
+package database
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Edits of the metadata and poster of a track once wrote shortDesc and
+// posterUrl while the track is read from shortdesc and posterurl. Those edits
+// are the latest ones so they are moved over the stored values. It is safe to
+// call on every start, nothing matches once they are moved
+func MoveStrayMusicFields() error {
+
+	musics := OpenCollection("musics")
+
+	for stray, field := range map[string]string{"shortDesc": "shortdesc", "posterUrl": "posterurl"} {
+		_, err := musics.UpdateMany(context.TODO(),
+			bson.M{stray: bson.M{"$exists": true}},
+			mongo.Pipeline{
+				{{Key: "$set", Value: bson.M{field: "$" + stray}}},
+				{{Key: "$unset", Value: stray}},
+			},
+		)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,11 @@
+package events
+
+import "music-sharing/shared/broker"
+
+type Broker = broker.Broker
+
+var inMemoryBroker = broker.NewInMemoryBroker()
+
+func GetBroker() *broker.InMemoryBroker {
+	return inMemoryBroker
+}
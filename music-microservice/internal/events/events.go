@@ -0,0 +1,218 @@
+package events
+
+import "time"
+
+const (
+	MusicUploadedEvent          = "MusicUploaded"
+	MusicLikedEvent             = "MusicLiked"
+	MusicUnlikedEvent           = "MusicUnliked"
+	MusicDeletedEvent           = "MusicDeleted"
+	MusicHiddenEvent            = "MusicHidden"
+	MusicUnhiddenEvent          = "MusicUnhidden"
+	MusicReportedEvent          = "MusicReported"
+	MusicCommentedEvent         = "MusicCommented"
+	MusicRepostedEvent          = "MusicReposted"
+	MusicUnrepostedEvent        = "MusicUnreposted"
+	MusicVisibilityChangedEvent = "MusicVisibilityChanged"
+	MusicReleasedEvent          = "MusicReleased"
+	CaseResolvedEvent           = "ModerationCaseResolved"
+
+	TakedownSubmittedEvent      = "TakedownSubmitted"
+	TakedownActionedEvent       = "TakedownActioned"
+	TakedownRejectedEvent       = "TakedownRejected"
+	TakedownCounterNoticedEvent = "TakedownCounterNoticed"
+	TakedownReinstatedEvent     = "TakedownReinstated"
+)
+
+type (
+	Event interface {
+		EventName() string
+		AggregateID() string
+	}
+
+	// ReleaseAt is set for scheduled tracks, MusicReleased follows at that
+	// time
+	MusicUploaded struct {
+		MusicID    string     `json:"musicId"`
+		ArtistID   string     `json:"artistId"`
+		Title      string     `json:"title"`
+		Visibility string     `json:"visibility"`
+		ReleaseAt  *time.Time `json:"releaseAt,omitempty"`
+	}
+
+	MusicReleased struct {
+		MusicID    string `json:"musicId"`
+		ArtistID   string `json:"artistId"`
+		Title      string `json:"title"`
+		Visibility string `json:"visibility"`
+	}
+
+	MusicLiked struct {
+		MusicID    string `json:"musicId"`
+		ArtistID   string `json:"artistId"`
+		UserID     string `json:"userId"`
+		Visibility string `json:"visibility"`
+	}
+
+	MusicVisibilityChanged struct {
+		MusicID    string `json:"musicId"`
+		ArtistID   string `json:"artistId"`
+		Title      string `json:"title"`
+		Visibility string `json:"visibility"`
+		Previous   string `json:"previous"`
+	}
+
+	MusicUnliked struct {
+		MusicID  string `json:"musicId"`
+		ArtistID string `json:"artistId"`
+		UserID   string `json:"userId"`
+	}
+
+	MusicDeleted struct {
+		MusicID  string `json:"musicId"`
+		ArtistID string `json:"artistId"`
+	}
+
+	MusicHidden struct {
+		MusicID     string `json:"musicId"`
+		ArtistID    string `json:"artistId"`
+		ModeratorID string `json:"moderatorId"`
+		Reason      string `json:"reason"`
+	}
+
+	MusicUnhidden struct {
+		MusicID     string `json:"musicId"`
+		ArtistID    string `json:"artistId"`
+		ModeratorID string `json:"moderatorId"`
+	}
+
+	MusicReposted struct {
+		RepostID string `json:"repostId"`
+		MusicID  string `json:"musicId"`
+		ArtistID string `json:"artistId"`
+		UserID   string `json:"userId"`
+		Caption  string `json:"caption"`
+	}
+
+	MusicUnreposted struct {
+		RepostID string `json:"repostId"`
+		MusicID  string `json:"musicId"`
+		ArtistID string `json:"artistId"`
+		UserID   string `json:"userId"`
+	}
+
+	// ParentAuthorID is set for replies
+	MusicCommented struct {
+		CommentID      string `json:"commentId"`
+		MusicID        string `json:"musicId"`
+		ArtistID       string `json:"artistId"`
+		AuthorID       string `json:"authorId"`
+		ParentAuthorID string `json:"parentAuthorId,omitempty"`
+	}
+
+	MusicReported struct {
+		MusicID    string `json:"musicId"`
+		ArtistID   string `json:"artistId"`
+		ReporterID string `json:"reporterId"`
+		CaseID     string `json:"caseId"`
+		Category   string `json:"category"`
+	}
+
+	ModerationCaseResolved struct {
+		CaseID      string `json:"caseId"`
+		MusicID     string `json:"musicId"`
+		ArtistID    string `json:"artistId"`
+		ModeratorID string `json:"moderatorId"`
+		Action      string `json:"action"`
+	}
+
+	TakedownSubmitted struct {
+		TakedownID string   `json:"takedownId"`
+		MusicIDs   []string `json:"musicIds"`
+		ArtistID   string   `json:"artistId"`
+		ClaimantID string   `json:"claimantId"`
+	}
+
+	// Strikes is the number of strikes of the artist after this takedown
+	TakedownActioned struct {
+		TakedownID  string   `json:"takedownId"`
+		MusicIDs    []string `json:"musicIds"`
+		ArtistID    string   `json:"artistId"`
+		ModeratorID string   `json:"moderatorId"`
+		Strikes     int      `json:"strikes"`
+	}
+
+	TakedownRejected struct {
+		TakedownID string `json:"takedownId"`
+		ClaimantID string `json:"claimantId"`
+		Reason     string `json:"reason"`
+	}
+
+	TakedownCounterNoticed struct {
+		TakedownID     string    `json:"takedownId"`
+		ArtistID       string    `json:"artistId"`
+		ClaimantID     string    `json:"claimantId"`
+		ReinstateAfter time.Time `json:"reinstateAfter"`
+	}
+
+	TakedownReinstated struct {
+		TakedownID string   `json:"takedownId"`
+		MusicIDs   []string `json:"musicIds"`
+		ArtistID   string   `json:"artistId"`
+		ClaimantID string   `json:"claimantId"`
+	}
+)
+
+func (e *MusicUploaded) EventName() string   { return MusicUploadedEvent }
+func (e *MusicUploaded) AggregateID() string { return e.MusicID }
+
+func (e *MusicVisibilityChanged) EventName() string   { return MusicVisibilityChangedEvent }
+func (e *MusicVisibilityChanged) AggregateID() string { return e.MusicID }
+
+func (e *MusicReleased) EventName() string   { return MusicReleasedEvent }
+func (e *MusicReleased) AggregateID() string { return e.MusicID }
+
+func (e *MusicLiked) EventName() string   { return MusicLikedEvent }
+func (e *MusicLiked) AggregateID() string { return e.MusicID }
+
+func (e *MusicUnliked) EventName() string   { return MusicUnlikedEvent }
+func (e *MusicUnliked) AggregateID() string { return e.MusicID }
+
+func (e *MusicDeleted) EventName() string   { return MusicDeletedEvent }
+func (e *MusicDeleted) AggregateID() string { return e.MusicID }
+
+func (e *MusicHidden) EventName() string   { return MusicHiddenEvent }
+func (e *MusicHidden) AggregateID() string { return e.MusicID }
+
+func (e *MusicUnhidden) EventName() string   { return MusicUnhiddenEvent }
+func (e *MusicUnhidden) AggregateID() string { return e.MusicID }
+
+func (e *MusicReposted) EventName() string   { return MusicRepostedEvent }
+func (e *MusicReposted) AggregateID() string { return e.MusicID }
+
+func (e *MusicUnreposted) EventName() string   { return MusicUnrepostedEvent }
+func (e *MusicUnreposted) AggregateID() string { return e.MusicID }
+
+func (e *MusicCommented) EventName() string   { return MusicCommentedEvent }
+func (e *MusicCommented) AggregateID() string { return e.MusicID }
+
+func (e *MusicReported) EventName() string   { return MusicReportedEvent }
+func (e *MusicReported) AggregateID() string { return e.MusicID }
+
+func (e *ModerationCaseResolved) EventName() string   { return CaseResolvedEvent }
+func (e *ModerationCaseResolved) AggregateID() string { return e.CaseID }
+
+func (e *TakedownSubmitted) EventName() string   { return TakedownSubmittedEvent }
+func (e *TakedownSubmitted) AggregateID() string { return e.TakedownID }
+
+func (e *TakedownActioned) EventName() string   { return TakedownActionedEvent }
+func (e *TakedownActioned) AggregateID() string { return e.TakedownID }
+
+func (e *TakedownRejected) EventName() string   { return TakedownRejectedEvent }
+func (e *TakedownRejected) AggregateID() string { return e.TakedownID }
+
+func (e *TakedownCounterNoticed) EventName() string   { return TakedownCounterNoticedEvent }
+func (e *TakedownCounterNoticed) AggregateID() string { return e.TakedownID }
+
+func (e *TakedownReinstated) EventName() string   { return TakedownReinstatedEvent }
+func (e *TakedownReinstated) AggregateID() string { return e.TakedownID }
@@ -0,0 +1,54 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"music-sharing/music-microservice/internal/app/models"
+	"music-sharing/music-microservice/internal/database"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Envelope is the message published to the broker for every outbox event
+type Envelope struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	AggregateID string          `json:"aggregateId"`
+	OccurredAt  time.Time       `json:"occurredAt"`
+	Payload     json.RawMessage `json:"payload"`
+}
+
+var outboxCollection *mongo.Collection = database.OpenCollection("outbox")
+
+// Record writes the event to the outbox collection. Pass the session context of
+// database.WithTransaction so the event is committed together with the change
+func Record(ctx context.Context, event Event) error {
+
+	payload, err := json.Marshal(event)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = outboxCollection.InsertOne(ctx, models.OutboxEvent{
+		ID:          primitive.NewObjectID(),
+		Name:        event.EventName(),
+		AggregateID: event.AggregateID(),
+		Payload:     string(payload),
+		OccurredAt:  time.Now().UTC(),
+	})
+
+	return err
+}
+
+func NewEnvelope(outboxEvent *models.OutboxEvent) *Envelope {
+	return &Envelope{
+		ID:          outboxEvent.ID.Hex(),
+		Name:        outboxEvent.Name,
+		AggregateID: outboxEvent.AggregateID,
+		OccurredAt:  outboxEvent.OccurredAt,
+		Payload:     json.RawMessage(outboxEvent.Payload),
+	}
+}
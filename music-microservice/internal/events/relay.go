@@ -0,0 +1,124 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"music-sharing/music-microservice/internal/app/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Relay publishes pending outbox events to the broker. Each event is claimed
+// with a short lease before publishing so several replicas can run a relay.
+// An event still failing after maxAttempts is dead lettered
+type Relay struct {
+	broker      Broker
+	interval    time.Duration
+	lease       time.Duration
+	maxAttempts uint
+}
+
+func NewRelay(broker Broker, interval time.Duration) *Relay {
+	return &Relay{
+		broker:      broker,
+		interval:    interval,
+		lease:       30 * time.Second,
+		maxAttempts: 20,
+	}
+}
+
+func (r *Relay) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := r.Flush(ctx); err != nil {
+					log.Printf("outbox relay: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Flush publishes every pending event that is not claimed by another relay
+func (r *Relay) Flush(ctx context.Context) error {
+	for {
+		outboxEvent, err := r.claim(ctx)
+
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		message, err := json.Marshal(NewEnvelope(outboxEvent))
+
+		if err == nil {
+			err = r.broker.Publish(outboxEvent.Name, message)
+		}
+
+		update := bson.M{"$set": bson.M{"publishedAt": time.Now().UTC(), "lastError": "", "lockedUntil": nil}}
+
+		if err != nil {
+			update = bson.M{"$set": bson.M{"lastError": err.Error()}}
+		}
+
+		if err != nil && outboxEvent.Attempts >= r.maxAttempts {
+			log.Printf("outbox relay: dead lettering %s %s after %d attempts: %v", outboxEvent.Name, outboxEvent.ID.Hex(), outboxEvent.Attempts, err)
+
+			update = bson.M{"$set": bson.M{"lastError": err.Error(), "deadLetteredAt": time.Now().UTC(), "lockedUntil": nil}}
+		}
+
+		_, updateErr := outboxCollection.UpdateByID(ctx, outboxEvent.ID, update)
+
+		if updateErr != nil {
+			return updateErr
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (r *Relay) claim(ctx context.Context) (*models.OutboxEvent, error) {
+	now := time.Now().UTC()
+	outboxEvent := &models.OutboxEvent{}
+
+	filter := bson.M{
+		"publishedAt":    nil,
+		"deadLetteredAt": nil,
+		"$or": bson.A{
+			bson.M{"lockedUntil": nil},
+			bson.M{"lockedUntil": bson.M{"$lt": now}},
+		},
+	}
+
+	update := bson.M{
+		"$set": bson.M{"lockedUntil": now.Add(r.lease)},
+		"$inc": bson.M{"attempts": 1},
+	}
+
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.M{"occurredAt": 1}).
+		SetReturnDocument(options.After)
+
+	err := outboxCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(outboxEvent)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return outboxEvent, nil
+}
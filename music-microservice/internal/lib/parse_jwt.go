@@ -1,7 +1,7 @@
 package lib
 
 import (
-	"errors"
+	"music-sharing/shared/apperrors"
 	"os"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -13,14 +13,14 @@ func ParseJWT(tokenString string) (jwt.MapClaims, error) {
 
 	token, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
 		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 
 	if err != nil {
-		return nil, err
+		return nil, apperrors.Unauthorized(err.Error())
 	}
 
 	if !token.Valid {
-		return nil, errors.New("invalid token")
+		return nil, apperrors.Unauthorized("invalid token")
 	}
 
 	return claims, nil
@@ -0,0 +1,17 @@
+package lib
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+)
+
+// Url safe random token of size random bytes
+func RandomToken(size int) (string, error) {
+	data := make([]byte, size)
+
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
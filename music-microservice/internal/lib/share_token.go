@@ -0,0 +1,68 @@
+package lib
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"music-sharing/shared/apperrors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Token of a share link, "<link id>.<expiry unix>.<signature>" signed with
+// SHARE_LINK_SECRET, or a key derived from JWT_SECRET when it is not set
+func SignShareToken(linkId string, expiresAt time.Time) string {
+	payload := linkId + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+
+	return payload + "." + shareTokenSignature(payload)
+}
+
+// Checks the signature and the expiry of a share token and returns the id of
+// its link
+func ParseShareToken(token string) (string, error) {
+	parts := strings.Split(token, ".")
+
+	if len(parts) != 3 {
+		return "", apperrors.Unauthorized("invalid share token")
+	}
+
+	payload := parts[0] + "." + parts[1]
+
+	if !hmac.Equal([]byte(parts[2]), []byte(shareTokenSignature(payload))) {
+		return "", apperrors.Unauthorized("invalid share token")
+	}
+
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+
+	if err != nil {
+		return "", apperrors.Unauthorized("invalid share token")
+	}
+
+	if time.Now().Unix() >= expiresAt {
+		return "", apperrors.Unauthorized("share token expired")
+	}
+
+	return parts[0], nil
+}
+
+func shareTokenSignature(payload string) string {
+	mac := hmac.New(sha256.New, shareTokenKey())
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// The key derived from JWT_SECRET is only good for share tokens, a session
+// token signature can never pass for one
+func shareTokenKey() []byte {
+	if secret := os.Getenv("SHARE_LINK_SECRET"); len(secret) != 0 {
+		return []byte(secret)
+	}
+
+	mac := hmac.New(sha256.New, []byte(os.Getenv("JWT_SECRET")))
+	mac.Write([]byte("share_link"))
+
+	return mac.Sum(nil)
+}
@@ -0,0 +1,70 @@
+package lib
+
+import (
+	"music-sharing/shared/apperrors"
+	"music-sharing/shared/internalapi"
+	"net/http"
+	"os"
+)
+
+// What user-microservice knows of a user that decides what they can do and
+// see. The role of the token claims can be stale, this one is current
+type ViewerContext struct {
+	Role          string   `json:"role"`
+	TokenVersion  uint     `json:"tokenVersion"`
+	Suspended     bool     `json:"suspended"`
+	HiddenUserIDs []string `json:"hiddenUserIds"`
+}
+
+// Largest number of users a follow lookup asks about at once
+const followLookupBatch = 500
+
+// A token of a user that doesn't exist anymore is not a session
+func GetViewerContext(userId string) (*ViewerContext, error) {
+
+	viewerContext := &ViewerContext{}
+
+	url := os.Getenv("USER_SERVICE") + "/internal/users/" + userId + "/viewerContext"
+
+	err := internalapi.Send("GET", url, nil, viewerContext)
+
+	if internalapi.Status(err) == http.StatusNotFound {
+		return nil, apperrors.Unauthorized("user doesnt exist")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return viewerContext, nil
+}
+
+// The users among userIds that userId follows. The ids are sent in batches so
+// a lookup of many artists stays within the limits of user-microservice
+func GetFollowedUsers(userId string, userIds []string) ([]string, error) {
+
+	followed := []string{}
+	url := os.Getenv("USER_SERVICE") + "/internal/users/" + userId + "/followings/lookup"
+
+	for start := 0; start < len(userIds); start += followLookupBatch {
+		end := start + followLookupBatch
+
+		if end > len(userIds) {
+			end = len(userIds)
+		}
+
+		resp := &struct {
+			FollowingUserIDs []string `json:"followingUserIds"`
+		}{}
+
+		err := internalapi.Send("POST", url, map[string]interface{}{"userIds": userIds[start:end]}, resp)
+
+		if err != nil {
+			return nil, err
+		}
+
+		followed = append(followed, resp.FollowingUserIDs...)
+	}
+
+	return followed, nil
+}
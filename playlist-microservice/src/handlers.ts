@@ -8,18 +8,26 @@ import axios from "axios";
 const prisma = new PrismaClient();
 
 /**
- * Fetches the musics for a given playlist from the music service.
+ * Fetches the musics for a given playlist from the music service. The music
+ * service leaves out the tracks the reader is not allowed to see.
  *
  * @param {Playlist} playlist - The playlist object containing the list of music IDs.
+ * @param {string} authorization - The authorization header of the reader.
  * @return {Promise<Playlist>} - The updated playlist object with the fetched musics.
  */
-const fetchPlaylistMusics = async (playlist: Playlist) => {
+const fetchPlaylistMusics = async (
+  playlist: Playlist,
+  authorization?: string
+) => {
   const musicServiceUrl = process.env.MUSIC_SERVICE!;
 
   const response = await axios.post<Music[]>(
-    musicServiceUrl + "/retrieveMusicsByIds",
+    musicServiceUrl + "/v1/musics/batch",
     {
       musicsIds: playlist.musics,
+    },
+    {
+      headers: { Authorization: authorization },
     }
   );
 
@@ -50,7 +58,10 @@ const getPlaylistById: RouteHandlerMethod = async (req, res) => {
     throw new Error("Playlist not found");
   }
 
-  const result = await fetchPlaylistMusics(playlist);
+  const result = await fetchPlaylistMusics(
+    playlist,
+    req.headers.authorization
+  );
 
   res.status(200).send(result);
 };
@@ -69,11 +80,43 @@ const getPlaylists: RouteHandlerMethod = async (req, res) => {
     throw new Error("Playlists not found or empty");
   }
 
-  const result = await Promise.all(playlists.map(fetchPlaylistMusics));
+  const result = await Promise.all(
+    playlists.map((playlist) =>
+      fetchPlaylistMusics(playlist, req.headers.authorization)
+    )
+  );
 
   res.status(200).send(result);
 };
 
+/**
+ * Retrieves the ids of the playlists a user owns and likes, for the data
+ * export of user-microservice.
+ *
+ * @param {Request} req - The request object.
+ * @param {Response} res - The response object.
+ * @return {Promise<void>} The promise that resolves when the function completes.
+ */
+const getUserPlaylists: RouteHandlerMethod = async (req, res) => {
+  const { userId } = req.params as { userId: string };
+
+  const [owned, liked] = await Promise.all([
+    prisma.playlist.findMany({
+      where: { author: userId },
+      select: { id: true },
+    }),
+    prisma.playlist.findMany({
+      where: { likes: { has: userId } },
+      select: { id: true },
+    }),
+  ]);
+
+  res.status(200).send({
+    owned: owned.map((playlist) => playlist.id),
+    liked: liked.map((playlist) => playlist.id),
+  });
+};
+
 /**
  * Like a playlist.
  *
@@ -302,6 +345,7 @@ const updatePlaylist: AuthRouteHandlerMethod = async (req, res, user) => {
 export {
   getPlaylistById,
   getPlaylists,
+  getUserPlaylists,
   likePlaylist,
   unlikePlaylist,
   uploadMusicToPlaylist,
@@ -1,5 +1,6 @@
 import { RouteHandlerMethod } from "fastify";
 import axios from "axios";
+import { timingSafeEqual } from "crypto";
 import { User, AuthRouteHandlerMethod } from "./types";
 import { PrismaClient } from "@prisma/client";
 
@@ -54,3 +55,28 @@ export const isOwner = (
     handler(req, res, user);
   };
 };
+
+/**
+ * Guards the routes that are only called by the other microservices, they send
+ * the shared INTERNAL_API_KEY in the X-Internal-Token header.
+ *
+ * @param {RouteHandlerMethod} handler - The handler function to execute for the internal callers.
+ * @return {RouteHandlerMethod} The route handler method that handles the request and response.
+ */
+export const internal = (handler: RouteHandlerMethod): RouteHandlerMethod => {
+  return async function (req, res) {
+    const apiKey = Buffer.from(process.env.INTERNAL_API_KEY ?? "");
+    const header = Buffer.from(String(req.headers["x-internal-token"] ?? ""));
+
+    if (
+      apiKey.length === 0 ||
+      apiKey.length !== header.length ||
+      !timingSafeEqual(apiKey, header)
+    ) {
+      res.status(401).send({ success: false, error: "you are not authorized" });
+      return;
+    }
+
+    return handler.call(this, req, res);
+  };
+};
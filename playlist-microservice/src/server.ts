@@ -3,6 +3,7 @@ import dotenv from "dotenv";
 import {
   getPlaylistById,
   getPlaylists,
+  getUserPlaylists,
   likePlaylist,
   removeMusicFromPlaylist,
   unlikePlaylist,
@@ -10,7 +11,7 @@ import {
   createPlaylist,
   updatePlaylist,
 } from "./handlers";
-import { auth, isOwner } from "./middlewares";
+import { auth, internal, isOwner } from "./middlewares";
 import fastifyMultipart from "@fastify/multipart";
 
 const onFile = async (part: any) => {
@@ -55,6 +56,7 @@ fastify.put(
 );
 fastify.post("/createPlaylist", auth(createPlaylist));
 fastify.put("/updatePlaylist/:id", auth(isOwner(updatePlaylist)));
+fastify.get("/internal/users/:userId/playlists", internal(getUserPlaylists));
 
 const port = process.env.PORT || 3000;
 
@@ -0,0 +1,104 @@
+// Package apperrors is the error model of the services. Handlers report typed
+// errors with c.Error and the middleware made by Handler turns them into RFC
+// 7807 problem responses with the matching status code
+package apperrors
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Error is an error the client is told about. Cause is kept for the logs and
+// is never sent
+type Error struct {
+	Status int
+	Detail string
+	Fields []FieldError
+	Cause  error
+}
+
+// FieldError tells which field of the request failed validation and why
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Problem is the application/problem+json body of a failed request
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return e.Detail + ": " + e.Cause.Error()
+	}
+
+	return e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+func (e *Error) Problem(instance string) Problem {
+	return Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(e.Status),
+		Status:   e.Status,
+		Detail:   e.Detail,
+		Instance: instance,
+		Errors:   e.Fields,
+	}
+}
+
+func NotFound(detail string) *Error {
+	return &Error{Status: http.StatusNotFound, Detail: detail}
+}
+
+func Validation(detail string, fields ...FieldError) *Error {
+	return &Error{Status: http.StatusBadRequest, Detail: detail, Fields: fields}
+}
+
+func Unauthorized(detail string) *Error {
+	return &Error{Status: http.StatusUnauthorized, Detail: detail}
+}
+
+func Forbidden(detail string) *Error {
+	return &Error{Status: http.StatusForbidden, Detail: detail}
+}
+
+func Conflict(detail string) *Error {
+	return &Error{Status: http.StatusConflict, Detail: detail}
+}
+
+func PreconditionFailed(detail string) *Error {
+	return &Error{Status: http.StatusPreconditionFailed, Detail: detail}
+}
+
+func PreconditionRequired(detail string) *Error {
+	return &Error{Status: http.StatusPreconditionRequired, Detail: detail}
+}
+
+func UnprocessableEntity(detail string) *Error {
+	return &Error{Status: http.StatusUnprocessableEntity, Detail: detail}
+}
+
+func TooManyRequests(detail string) *Error {
+	return &Error{Status: http.StatusTooManyRequests, Detail: detail}
+}
+
+// Internal hides cause from the client, it only shows up in the logs
+func Internal(cause error) *Error {
+	return &Error{Status: http.StatusInternalServerError, Detail: "something went wrong", Cause: cause}
+}
+
+// Field makes the field of a validation error, the name is given the way the
+// client sends it
+func Field(field string, format string, args ...interface{}) FieldError {
+	return FieldError{Field: field, Message: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,63 @@
+package apperrors
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Classifier gives the status of the errors of a library a service leans on,
+// like its database driver, and returns nil for the errors it doesn't know
+type Classifier func(err error) *Error
+
+// From classifies any error reported by a handler. The errors of the libraries
+// the handlers lean on get their matching status, anything else is internal
+func From(err error, classifiers ...Classifier) *Error {
+	var appErr *Error
+	var validationErrs validator.ValidationErrors
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &appErr):
+		return appErr
+	case errors.As(err, &validationErrs):
+		fields := []FieldError{}
+
+		for _, fieldErr := range validationErrs {
+			fields = append(fields, Field(fieldPath(fieldErr), "failed the %s validation", fieldErr.Tag()))
+		}
+
+		return Validation("the request is invalid", fields...)
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return Validation("the request body is not valid json")
+	case errors.As(err, &typeErr):
+		return Validation("the request body is invalid", Field(typeErr.Field, "must be a %s", typeErr.Type.String()))
+	case errors.Is(err, http.ErrMissingFile):
+		return Validation("a file is missing from the request")
+	}
+
+	for _, classify := range classifiers {
+		if classified := classify(err); classified != nil {
+			return classified
+		}
+	}
+
+	return Internal(err)
+}
+
+// The namespace starts with the name of the validated struct, the rest is
+// the path of the field with the names the validation package registered
+func fieldPath(fieldErr validator.FieldError) string {
+	_, path, found := strings.Cut(fieldErr.Namespace(), ".")
+
+	if !found {
+		return fieldErr.Field()
+	}
+
+	return path
+}
@@ -0,0 +1,34 @@
+package apperrors
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler makes the middleware answering a failed request with the problem of
+// its first error, later ones are usually a consequence of it. Internal errors
+// are logged and their details are never sent
+func Handler(classifiers ...Classifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		c.Next()
+
+		if len(c.Errors) == 0 {
+			return
+		}
+
+		appErr := From(c.Errors[0].Err, classifiers...)
+
+		if appErr.Status >= 500 {
+			log.Printf("%s %s: %v", c.Request.Method, c.Request.URL.Path, appErr)
+		}
+
+		if c.Writer.Written() {
+			return
+		}
+
+		c.Header("Content-Type", "application/problem+json")
+		c.JSON(appErr.Status, appErr.Problem(c.Request.URL.Path))
+	}
+}
@@ -0,0 +1,58 @@
+// Package broker is the message broker the outbox relays of the services
+// publish their events to
+package broker
+
+import (
+	"errors"
+	"sync"
+)
+
+type (
+	Broker interface {
+		Publish(topic string, payload []byte) error
+		Subscribe(topic string, handler func(payload []byte) error)
+	}
+
+	BrokerMessage struct {
+		Topic   string
+		Payload []byte
+	}
+
+	// InMemoryBroker delivers messages synchronously to the subscribers of the
+	// same process, which makes it suitable for single instance deployments.
+	// It keeps nothing once a message is delivered
+	InMemoryBroker struct {
+		mu          sync.RWMutex
+		subscribers map[string][]func(payload []byte) error
+	}
+)
+
+func NewInMemoryBroker() *InMemoryBroker {
+	return &InMemoryBroker{
+		subscribers: map[string][]func(payload []byte) error{},
+	}
+}
+
+func (b *InMemoryBroker) Publish(topic string, payload []byte) error {
+
+	b.mu.RLock()
+	handlers := append([]func(payload []byte) error{}, b.subscribers[topic]...)
+	b.mu.RUnlock()
+
+	var errs []error
+
+	for _, handler := range handlers {
+		if err := handler(payload); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (b *InMemoryBroker) Subscribe(topic string, handler func(payload []byte) error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subscribers[topic] = append(b.subscribers[topic], handler)
+}
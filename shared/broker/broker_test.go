@@ -0,0 +1,90 @@
+package broker
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInMemoryBrokerDeliversToEverySubscriber(t *testing.T) {
+	b := NewInMemoryBroker()
+	received := []string{}
+
+	b.Subscribe("UserFollowed", func(payload []byte) error {
+		received = append(received, "feed "+string(payload))
+		return nil
+	})
+
+	b.Subscribe("UserFollowed", func(payload []byte) error {
+		received = append(received, "notifications "+string(payload))
+		return nil
+	})
+
+	b.Subscribe("UserUnfollowed", func(payload []byte) error {
+		t.Fatal("a subscriber of another topic received the message")
+		return nil
+	})
+
+	if err := b.Publish("UserFollowed", []byte("{}")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(received) != 2 || received[0] != "feed {}" || received[1] != "notifications {}" {
+		t.Fatalf("received %v, want both subscribers in order", received)
+	}
+}
+
+// A failing subscriber doesn't keep the others from the message, its error is
+// returned so the relay retries the event
+func TestInMemoryBrokerReturnsTheErrorsOfTheSubscribers(t *testing.T) {
+	b := NewInMemoryBroker()
+	failure := errors.New("user-microservice is down")
+	delivered := false
+
+	b.Subscribe("MusicLiked", func(payload []byte) error {
+		return failure
+	})
+
+	b.Subscribe("MusicLiked", func(payload []byte) error {
+		delivered = true
+		return nil
+	})
+
+	err := b.Publish("MusicLiked", []byte("{}"))
+
+	if !errors.Is(err, failure) {
+		t.Fatalf("Publish returned %v, want the error of the subscriber", err)
+	}
+
+	if !delivered {
+		t.Fatal("the second subscriber did not receive the message")
+	}
+}
+
+func TestRecordingBrokerKeepsThePublishedMessages(t *testing.T) {
+	b := NewRecordingBroker(NewInMemoryBroker())
+	delivered := 0
+
+	b.Subscribe("UserFollowed", func(payload []byte) error {
+		delivered++
+		return nil
+	})
+
+	b.Publish("UserRegistered", []byte(`{"userId":"1"}`))
+	b.Publish("UserFollowed", []byte(`{"followerId":"1"}`))
+
+	published := b.Published()
+
+	if len(published) != 2 || published[0].Topic != "UserRegistered" || published[1].Topic != "UserFollowed" {
+		t.Fatalf("published %v, want both messages in order", published)
+	}
+
+	if delivered != 1 {
+		t.Fatalf("delivered %d messages, want the one of the subscribed topic", delivered)
+	}
+
+	b.Reset()
+
+	if len(b.Published()) != 0 {
+		t.Fatal("Reset kept the published messages")
+	}
+}
@@ -0,0 +1,38 @@
+package broker
+
+import "sync"
+
+// RecordingBroker wraps a broker and keeps a copy of every message published
+// through it. It is meant for tests, the copies are never trimmed
+type RecordingBroker struct {
+	Broker
+
+	mu        sync.RWMutex
+	published []BrokerMessage
+}
+
+func NewRecordingBroker(inner Broker) *RecordingBroker {
+	return &RecordingBroker{Broker: inner}
+}
+
+func (b *RecordingBroker) Publish(topic string, payload []byte) error {
+	b.mu.Lock()
+	b.published = append(b.published, BrokerMessage{Topic: topic, Payload: payload})
+	b.mu.Unlock()
+
+	return b.Broker.Publish(topic, payload)
+}
+
+func (b *RecordingBroker) Published() []BrokerMessage {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return append([]BrokerMessage{}, b.published...)
+}
+
+func (b *RecordingBroker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.published = nil
+}
@@ -0,0 +1,262 @@
+// Package idempotency replays the stored response of a mutating request
+// retried with the same Idempotency-Key header instead of running it again.
+// Each service keeps the records in its own database behind a Store
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"music-sharing/shared/apperrors"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// a key still running after the lease was left by a crashed request, the
+// retry takes it over
+const Lease = 5 * time.Minute
+
+// The outcome of a request sent with an Idempotency-Key. Status is 0 while
+// the first request is still running
+type Record struct {
+	Key         string
+	Scope       string
+	RequestHash string
+	Status      int
+	ContentType string
+	Body        []byte
+	// the response held credentials and was not stored, retries are refused
+	Withheld  bool
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+type Store interface {
+	// Claim stores the record unless a live one has its key and scope, which
+	// is returned instead. Expired records and the ones still running after
+	// the Lease don't count
+	Claim(record *Record) (*Record, error)
+	// Complete stores the response of a claimed record
+	Complete(record *Record) error
+	// Release deletes a claimed record so a retry runs again
+	Release(record *Record) error
+}
+
+type recordingWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+	return w.ResponseWriter.WriteString(data)
+}
+
+// Marks a route whose response issues credentials, like a session token. Its
+// response is never stored and a retry of the key gets a conflict, the caller
+// has to send a new request
+func Withhold(c *gin.Context) {
+	c.Set("idempotency_withhold", true)
+	c.Next()
+}
+
+// Keys are scoped to the Authorization header of the caller, or to its address
+// and user agent when it has none, and kept for IDEMPOTENCY_KEY_TTL, 24h by
+// default. Only the requests that succeed are stored, a failed one releases
+// its key so the retry runs again
+func Middleware(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		key := c.GetHeader("Idempotency-Key")
+
+		if len(key) == 0 || c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead || c.Request.Method == http.MethodOptions {
+			c.Next()
+			return
+		}
+
+		if len(key) > 255 {
+			c.Error(apperrors.Validation("the Idempotency-Key header is longer than 255 characters"))
+			c.Abort()
+			return
+		}
+
+		requestHash, err := hashRequest(c)
+
+		if err != nil {
+			c.Error(apperrors.Validation("the request body could not be read"))
+			c.Abort()
+			return
+		}
+
+		now := time.Now().UTC()
+
+		record := &Record{
+			Key:         key,
+			Scope:       scopeOf(c),
+			RequestHash: requestHash,
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(keyTTL()),
+		}
+
+		stored, err := store.Claim(record)
+
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		if stored != nil {
+			replay(c, record, stored)
+			return
+		}
+
+		writer := &recordingWriter{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = writer
+
+		c.Next()
+
+		if len(c.Errors) != 0 || writer.Status() >= 500 {
+			err = store.Release(record)
+		} else {
+			record.Status = writer.Status()
+
+			if c.GetBool("idempotency_withhold") {
+				record.Withheld = true
+			} else {
+				record.ContentType = writer.Header().Get("Content-Type")
+				record.Body = writer.body.Bytes()
+			}
+
+			err = store.Complete(record)
+		}
+
+		if err != nil {
+			log.Printf("idempotency key %s: %v", record.Key, err)
+		}
+	}
+}
+
+func replay(c *gin.Context, record *Record, stored *Record) {
+
+	defer c.Abort()
+
+	if stored.RequestHash != record.RequestHash {
+		c.Error(apperrors.UnprocessableEntity("the Idempotency-Key was already used for another request"))
+		return
+	}
+
+	if stored.Status == 0 {
+		c.Error(apperrors.Conflict("a request with this Idempotency-Key is still running"))
+		return
+	}
+
+	if stored.Withheld {
+		c.Error(apperrors.Conflict("the request with this Idempotency-Key succeeded and its response is not kept, send a new request"))
+		return
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(stored.Status, stored.ContentType, stored.Body)
+}
+
+// Anonymous callers share no header, their keys are told apart by the client
+// they come from
+func scopeOf(c *gin.Context) string {
+	caller := c.GetHeader("Authorization")
+
+	if len(caller) == 0 {
+		caller = "anonymous " + c.ClientIP() + " " + c.Request.UserAgent()
+	}
+
+	scope := sha256.Sum256([]byte(caller))
+
+	return hex.EncodeToString(scope[:])
+}
+
+// hashRequest fingerprints the method, the uri and the body. Multipart bodies
+// are hashed by their fields and files since a retry may pick a new boundary
+func hashRequest(c *gin.Context) (string, error) {
+
+	hash := sha256.New()
+	io.WriteString(hash, c.Request.Method+" "+c.Request.URL.RequestURI()+"\n")
+
+	if !strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		body, err := io.ReadAll(c.Request.Body)
+
+		if err != nil {
+			return "", err
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		hash.Write(body)
+
+		return hex.EncodeToString(hash.Sum(nil)), nil
+	}
+
+	// the parsed form is kept on the request, the handler reads it from there
+	if err := c.Request.ParseMultipartForm(32 << 20); err != nil {
+		return "", err
+	}
+
+	form := c.Request.MultipartForm
+
+	for _, name := range sortedKeys(form.Value) {
+		io.WriteString(hash, name+"="+strings.Join(form.Value[name], ",")+"\n")
+	}
+
+	for _, name := range sortedKeys(form.File) {
+		for _, header := range form.File[name] {
+			file, err := header.Open()
+
+			if err != nil {
+				return "", err
+			}
+
+			io.WriteString(hash, name+"="+header.Filename+"\n")
+			_, err = io.Copy(hash, file)
+			file.Close()
+
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := []string{}
+
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Read from IDEMPOTENCY_KEY_TTL (e.g. "12h"), defaults to 24 hours
+func keyTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_KEY_TTL"))
+
+	if err != nil || ttl <= 0 {
+		return 24 * time.Hour
+	}
+
+	return ttl
+}
@@ -0,0 +1,294 @@
+package idempotency
+
+import (
+	"music-sharing/shared/apperrors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// memoryStore keeps the records the way the database stores of the services
+// do, a record is claimed unless a live one has its key and scope
+type memoryStore struct {
+	mu      sync.Mutex
+	records map[string]*Record
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{records: map[string]*Record{}}
+}
+
+func (s *memoryStore) Claim(record *Record) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := record.Scope + " " + record.Key
+	now := time.Now().UTC()
+
+	if stored, ok := s.records[id]; ok && stored.ExpiresAt.After(now) && (stored.Status != 0 || stored.CreatedAt.Add(Lease).After(now)) {
+		copied := *stored
+		return &copied, nil
+	}
+
+	copied := *record
+	s.records[id] = &copied
+
+	return nil, nil
+}
+
+func (s *memoryStore) Complete(record *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *record
+	s.records[record.Scope+" "+record.Key] = &copied
+
+	return nil
+}
+
+func (s *memoryStore) Release(record *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, record.Scope+" "+record.Key)
+
+	return nil
+}
+
+func (s *memoryStore) only(t *testing.T) *Record {
+	t.Helper()
+
+	if len(s.records) != 1 {
+		t.Fatalf("the store has %d records, want 1", len(s.records))
+	}
+
+	for _, record := range s.records {
+		return record
+	}
+
+	return nil
+}
+
+// newRouter answers POST /orders with a new order number on every run, so a
+// replay is told apart from a second run
+func newRouter(store Store, calls *int) *gin.Engine {
+	router := gin.New()
+	router.Use(apperrors.Handler(), Middleware(store))
+
+	router.POST("/orders", func(c *gin.Context) {
+		*calls++
+		c.JSON(201, gin.H{"order": *calls})
+	})
+
+	router.POST("/sessions", Withhold, func(c *gin.Context) {
+		*calls++
+		c.JSON(200, gin.H{"token": "secret"})
+	})
+
+	router.POST("/failures", func(c *gin.Context) {
+		*calls++
+		c.Error(apperrors.Conflict("try again"))
+	})
+
+	return router
+}
+
+func send(router *gin.Engine, path string, key string, body string, authorization string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	if len(key) != 0 {
+		req.Header.Set("Idempotency-Key", key)
+	}
+
+	if len(authorization) != 0 {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	router.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestReplaysTheStoredResponse(t *testing.T) {
+	calls := 0
+	router := newRouter(newMemoryStore(), &calls)
+
+	first := send(router, "/orders", "key-1", `{"item":1}`, "Bearer a")
+	retry := send(router, "/orders", "key-1", `{"item":1}`, "Bearer a")
+
+	if calls != 1 {
+		t.Fatalf("the handler ran %d times, want 1", calls)
+	}
+
+	if retry.Code != first.Code || retry.Body.String() != first.Body.String() {
+		t.Fatalf("replay = %d %s, want %d %s", retry.Code, retry.Body, first.Code, first.Body)
+	}
+
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatal("the replay is missing the Idempotent-Replayed header")
+	}
+
+	if first.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatal("the first response has the Idempotent-Replayed header")
+	}
+}
+
+func TestRefusesAKeyReusedForAnotherRequest(t *testing.T) {
+	calls := 0
+	router := newRouter(newMemoryStore(), &calls)
+
+	send(router, "/orders", "key-1", `{"item":1}`, "Bearer a")
+	mismatch := send(router, "/orders", "key-1", `{"item":2}`, "Bearer a")
+
+	if mismatch.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d", mismatch.Code, http.StatusUnprocessableEntity)
+	}
+
+	if calls != 1 {
+		t.Fatalf("the handler ran %d times, want 1", calls)
+	}
+}
+
+func TestRefusesAKeyStillRunning(t *testing.T) {
+	calls := 0
+	store := newMemoryStore()
+	router := newRouter(store, &calls)
+
+	// claimed by a request that did not finish yet
+	send(router, "/orders", "key-1", `{"item":1}`, "Bearer a")
+	store.only(t).Status = 0
+
+	running := send(router, "/orders", "key-1", `{"item":1}`, "Bearer a")
+
+	if running.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d", running.Code, http.StatusConflict)
+	}
+
+	// a request still running after the lease crashed, the retry takes over
+	store.only(t).CreatedAt = time.Now().UTC().Add(-Lease - time.Second)
+
+	takenOver := send(router, "/orders", "key-1", `{"item":1}`, "Bearer a")
+
+	if takenOver.Code != http.StatusCreated || calls != 2 {
+		t.Fatalf("status = %d after %d runs, want %d after 2", takenOver.Code, calls, http.StatusCreated)
+	}
+}
+
+func TestKeysAreScopedToTheCaller(t *testing.T) {
+	calls := 0
+	router := newRouter(newMemoryStore(), &calls)
+
+	send(router, "/orders", "key-1", `{"item":1}`, "Bearer a")
+	other := send(router, "/orders", "key-1", `{"item":1}`, "Bearer b")
+
+	if other.Header().Get("Idempotent-Replayed") == "true" || calls != 2 {
+		t.Fatal("another caller got the response of the key")
+	}
+}
+
+func TestAnonymousKeysAreScopedToTheClient(t *testing.T) {
+	calls := 0
+	router := newRouter(newMemoryStore(), &calls)
+
+	for _, userAgent := range []string{"client-a", "client-b"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{}`))
+		req.Header.Set("Idempotency-Key", "key-1")
+		req.Header.Set("User-Agent", userAgent)
+
+		router.ServeHTTP(w, req)
+
+		if w.Header().Get("Idempotent-Replayed") == "true" {
+			t.Fatalf("%s got the response of another client", userAgent)
+		}
+	}
+
+	if calls != 2 {
+		t.Fatalf("the handler ran %d times, want 2", calls)
+	}
+}
+
+func TestWithheldResponsesAreNotStored(t *testing.T) {
+	calls := 0
+	store := newMemoryStore()
+	router := newRouter(store, &calls)
+
+	first := send(router, "/sessions", "key-1", `{}`, "")
+
+	if first.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", first.Code, http.StatusOK)
+	}
+
+	record := store.only(t)
+
+	if !record.Withheld || len(record.Body) != 0 || record.Status != http.StatusOK {
+		t.Fatalf("stored %+v, want only the status of a withheld response", record)
+	}
+
+	retry := send(router, "/sessions", "key-1", `{}`, "")
+
+	if retry.Code != http.StatusConflict || strings.Contains(retry.Body.String(), "secret") {
+		t.Fatalf("retry = %d %s, want a conflict without the token", retry.Code, retry.Body)
+	}
+
+	if calls != 1 {
+		t.Fatalf("the handler ran %d times, want 1", calls)
+	}
+}
+
+func TestFailedRequestsReleaseTheirKey(t *testing.T) {
+	calls := 0
+	store := newMemoryStore()
+	router := newRouter(store, &calls)
+
+	send(router, "/failures", "key-1", `{}`, "Bearer a")
+
+	if len(store.records) != 0 {
+		t.Fatal("the key of a failed request was kept")
+	}
+
+	retry := send(router, "/failures", "key-1", `{}`, "Bearer a")
+
+	if retry.Header().Get("Idempotent-Replayed") == "true" || calls != 2 {
+		t.Fatal("the retry of a failed request was not run again")
+	}
+}
+
+func TestRequestsWithoutKeyAlwaysRun(t *testing.T) {
+	calls := 0
+	store := newMemoryStore()
+	router := newRouter(store, &calls)
+
+	send(router, "/orders", "", `{"item":1}`, "Bearer a")
+	send(router, "/orders", "", `{"item":1}`, "Bearer a")
+
+	if calls != 2 || len(store.records) != 0 {
+		t.Fatalf("the handler ran %d times with %d records, want 2 and none", calls, len(store.records))
+	}
+}
+
+func TestRefusesTooLongKeys(t *testing.T) {
+	calls := 0
+	router := newRouter(newMemoryStore(), &calls)
+
+	w := send(router, "/orders", strings.Repeat("k", 256), `{}`, "Bearer a")
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	if calls != 0 {
+		t.Fatal("the handler ran with a key longer than 255 characters")
+	}
+}
@@ -0,0 +1,90 @@
+// Package internalapi calls the internal routes the services expose to each
+// other
+package internalapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+var internalClient = &http.Client{Timeout: 30 * time.Second}
+
+// StatusError is a response of another microservice outside of the 2xx range,
+// callers check its status to tell a missing resource from an outage
+type StatusError struct {
+	Method string
+	URL    string
+	Status int
+	Body   string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s failed with status %d: %s", e.Method, e.URL, e.Status, e.Body)
+}
+
+// Status of the response err was made from, 0 when the request didn't get one
+func Status(err error) int {
+	var statusErr *StatusError
+
+	if errors.As(err, &statusErr) {
+		return statusErr.Status
+	}
+
+	return 0
+}
+
+// Sends a request to an internal route of another microservice, authenticated
+// with the shared INTERNAL_API_KEY, and decodes the json response into out
+func Send(method string, url string, body interface{}, out interface{}) error {
+
+	var reqBody io.Reader
+
+	if body != nil {
+		data, err := json.Marshal(body)
+
+		if err != nil {
+			return err
+		}
+
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, url, reqBody)
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Internal-Token", os.Getenv("INTERNAL_API_KEY"))
+
+	res, err := internalClient.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return &StatusError{Method: method, URL: url, Status: res.StatusCode, Body: string(data)}
+	}
+
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(data, out)
+}
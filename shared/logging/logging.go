@@ -0,0 +1,54 @@
+// Package logging writes the access log of the services. Some routes take a
+// secret in their query, like the tickets of the streams and the tokens of the
+// share links, its value is left out of the log
+package logging
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// the query params that carry a secret in either service
+var secretParams = []string{"token", "ticket", "share"}
+
+// Logger is the access log of gin.Default with the secrets of the query
+// replaced
+func Logger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(params gin.LogFormatterParams) string {
+		return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+			params.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			params.StatusCode,
+			params.Latency,
+			params.ClientIP,
+			params.Method,
+			redact(params.Path),
+			params.ErrorMessage,
+		)
+	})
+}
+
+func redact(path string) string {
+	route, rawQuery, found := strings.Cut(path, "?")
+
+	if !found {
+		return path
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+
+	// a query that doesn't parse could hide a secret anywhere
+	if err != nil {
+		return route + "?REDACTED"
+	}
+
+	for _, name := range secretParams {
+		if query.Has(name) {
+			query.Set(name, "REDACTED")
+		}
+	}
+
+	return route + "?" + query.Encode()
+}
@@ -0,0 +1,267 @@
+// Package openapi documents the routes of the service while they are
+// registered and checks the requests against what is documented. The OpenAPI 3
+// document is generated from the Operation of every route and served by
+// Spec.Serve, so it can't drift from the router
+package openapi
+
+import (
+	"fmt"
+	"music-sharing/shared/apperrors"
+	"music-sharing/shared/rest"
+	"path"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type object = map[string]interface{}
+
+var maxIdempotencyKeyLength = 255.0
+
+// Operation is a route of the v1 api
+type Operation struct {
+	Method string
+	// Path has gin params and is relative to the v1 group of the router
+	Path    string
+	Summary string
+	Tag     string
+	// Auth tells the route needs a bearer token
+	Auth bool
+	// Body is a value of the type of the json body. Its schema is built from
+	// the json and validate tags and the requests are checked against it
+	Body interface{}
+	// Form is a value of the type of a multipart/form-data body, it is only
+	// documented since files can't be checked before the handler reads them
+	Form  interface{}
+	Query []string
+	// Versioned tells the route updates a versioned resource and needs the
+	// If-Match header with the ETag of the last read. It is optional on the
+	// legacy route
+	Versioned bool
+	// Params renames the params of Path to the names the handlers read
+	Params map[string]string
+	// Legacy is the route that did the same before v1, as "METHOD /path". It
+	// keeps working and is documented as deprecated
+	Legacy string
+}
+
+type Spec struct {
+	title      string
+	version    string
+	paths      map[string]object
+	components map[string]*Schema
+}
+
+func NewSpec(title string, version string) *Spec {
+	return &Spec{
+		title:      title,
+		version:    version,
+		paths:      map[string]object{},
+		components: map[string]*Schema{},
+	}
+}
+
+func (s *Spec) Document() object {
+	return object{
+		"openapi": "3.0.3",
+		"info": object{
+			"title":   s.title,
+			"version": s.version,
+		},
+		"paths": s.paths,
+		"components": object{
+			"schemas": s.components,
+			"securitySchemes": object{
+				"bearerAuth": object{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}
+}
+
+func (s *Spec) Serve(c *gin.Context) {
+	c.JSON(200, s.Document())
+}
+
+// Router registers the operations on the v1 group and their legacy routes on
+// the legacy group
+type Router struct {
+	spec   *Spec
+	v1     *gin.RouterGroup
+	legacy *gin.RouterGroup
+}
+
+func (s *Spec) Router(v1 *gin.RouterGroup, legacy *gin.RouterGroup) *Router {
+	return &Router{spec: s, v1: v1, legacy: legacy}
+}
+
+// Handle registers the operation, the last handler is the one answering the
+// request and the ones before it are its middlewares
+func (r *Router) Handle(op Operation, handlers ...gin.HandlerFunc) {
+	successor := path.Join(r.v1.BasePath(), op.Path)
+
+	v1 := []gin.HandlerFunc{renameParams(op.Params)}
+
+	// the legacy routes predate the versions and keep working without If-Match
+	if op.Versioned {
+		v1 = append(v1, rest.RequireIfMatch)
+	}
+
+	r.spec.document(successor, op, false)
+	r.v1.Handle(op.Method, op.Path, r.chain(op, v1, handlers)...)
+
+	if len(op.Legacy) == 0 {
+		return
+	}
+
+	method, legacyPath, _ := strings.Cut(op.Legacy, " ")
+	legacy := op
+	legacy.Method = method
+
+	r.spec.document(path.Join(r.legacy.BasePath(), legacyPath), legacy, true)
+	r.legacy.Handle(method, legacyPath, r.chain(op, []gin.HandlerFunc{deprecated(successor, op.Params)}, handlers)...)
+}
+
+// The body is checked after the middlewares so an unauthenticated request
+// is told so before it is told its body is wrong
+func (r *Router) chain(op Operation, first []gin.HandlerFunc, handlers []gin.HandlerFunc) []gin.HandlerFunc {
+	chain := append([]gin.HandlerFunc{}, first...)
+	chain = append(chain, handlers[:len(handlers)-1]...)
+
+	if op.Body != nil {
+		chain = append(chain, r.spec.validateBody(r.spec.schemaOf(reflect.TypeOf(op.Body))))
+	}
+
+	return append(chain, handlers[len(handlers)-1])
+}
+
+func renameParams(params map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for param, name := range params {
+			c.Params = append(c.Params, gin.Param{Key: name, Value: c.Param(param)})
+		}
+
+		c.Next()
+	}
+}
+
+// Marks the answers of a legacy route with the Deprecation header and links
+// the v1 route of the same resource
+func deprecated(successor string, params map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		segments := strings.Split(successor, "/")
+
+		for i, segment := range segments {
+			if !strings.HasPrefix(segment, ":") {
+				continue
+			}
+
+			name := segment[1:]
+
+			if renamed, ok := params[name]; ok {
+				name = renamed
+			}
+
+			if value := c.Param(name); len(value) != 0 {
+				segments[i] = value
+			}
+		}
+
+		c.Header("Deprecation", "true")
+		c.Header("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", strings.Join(segments, "/")))
+		c.Next()
+	}
+}
+
+func (s *Spec) document(route string, op Operation, deprecated bool) {
+	segments := strings.Split(route, "/")
+	parameters := []object{}
+
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+			parameters = append(parameters, object{
+				"name":     segment[1:],
+				"in":       "path",
+				"required": true,
+				"schema":   &Schema{Type: "string"},
+			})
+		}
+	}
+
+	// the mutating routes replay the response of a retry with the same key
+	if op.Method != "GET" {
+		parameters = append(parameters, object{
+			"name":   "Idempotency-Key",
+			"in":     "header",
+			"schema": &Schema{Type: "string", MaxLength: &maxIdempotencyKeyLength},
+		})
+	}
+
+	if op.Versioned {
+		parameters = append(parameters, object{
+			"name":     "If-Match",
+			"in":       "header",
+			"required": !deprecated,
+			"schema":   &Schema{Type: "string"},
+		})
+	}
+
+	for _, name := range op.Query {
+		parameters = append(parameters, object{
+			"name":   name,
+			"in":     "query",
+			"schema": &Schema{Type: "string"},
+		})
+	}
+
+	operation := object{
+		"summary":    op.Summary,
+		"tags":       []string{op.Tag},
+		"parameters": parameters,
+		"responses": object{
+			"200": object{"description": "OK"},
+			"default": object{
+				"description": "the problem that failed the request",
+				"content": object{
+					"application/problem+json": object{"schema": s.schemaOf(reflect.TypeOf(apperrors.Problem{}))},
+				},
+			},
+		},
+	}
+
+	if deprecated {
+		operation["deprecated"] = true
+	}
+
+	if op.Auth {
+		operation["security"] = []object{{"bearerAuth": []string{}}}
+	}
+
+	// the PATCH routes take JSON Merge Patches
+	if op.Body != nil {
+		mediaType := "application/json"
+
+		if op.Method == "PATCH" {
+			mediaType = "application/merge-patch+json"
+		}
+
+		operation["requestBody"] = object{
+			"content": object{mediaType: object{"schema": s.schemaOf(reflect.TypeOf(op.Body))}},
+		}
+	}
+
+	if op.Form != nil {
+		operation["requestBody"] = object{
+			"content": object{"multipart/form-data": object{"schema": s.schemaOf(reflect.TypeOf(op.Form))}},
+		}
+	}
+
+	route = strings.Join(segments, "/")
+
+	if _, ok := s.paths[route]; !ok {
+		s.paths[route] = object{}
+	}
+
+	s.paths[route][strings.ToLower(op.Method)] = operation
+}
@@ -0,0 +1,57 @@
+package openapi
+
+import (
+	"music-sharing/shared/apperrors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// The v1 route of a versioned operation needs If-Match, its legacy route
+// predates the versions and works without it
+func TestVersionedRoutesRequireIfMatch(t *testing.T) {
+	router := gin.New()
+	router.Use(apperrors.Handler())
+
+	spec := NewSpec("test", "1.0.0")
+	routes := spec.Router(router.Group("/v1"), router.Group("/"))
+
+	routes.Handle(Operation{Method: "PATCH", Path: "/tracks/:id", Summary: "Update a track", Tag: "tracks", Versioned: true, Legacy: "PUT /updateTrack/:id"}, func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	cases := []struct {
+		name    string
+		method  string
+		path    string
+		ifMatch string
+		status  int
+	}{
+		{name: "v1 without If-Match", method: "PATCH", path: "/v1/tracks/1", status: http.StatusPreconditionRequired},
+		{name: "v1 with If-Match", method: "PATCH", path: "/v1/tracks/1", ifMatch: `"1"`, status: http.StatusNoContent},
+		{name: "legacy without If-Match", method: "PUT", path: "/updateTrack/1", status: http.StatusNoContent},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+
+			if len(tc.ifMatch) != 0 {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.status {
+				t.Fatalf("status = %d, want %d", w.Code, tc.status)
+			}
+		})
+	}
+}
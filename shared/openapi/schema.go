@@ -0,0 +1,230 @@
+package openapi
+
+import (
+	"encoding"
+	"mime/multipart"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is the part of the OpenAPI schema object the service uses
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	MinLength            *float64           `json:"minLength,omitempty"`
+	MaxLength            *float64           `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinItems             *float64           `json:"minItems,omitempty"`
+	MaxItems             *float64           `json:"maxItems,omitempty"`
+
+	// omitEmpty mirrors the omitempty of the validate tag, the zero value of
+	// the field skips its constraints
+	omitEmpty bool
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	fileHeaderType    = reflect.TypeOf(multipart.FileHeader{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schemaOf builds the schema of a go type the way encoding/json encodes it.
+// Named structs become components and are referenced
+func (s *Spec) schemaOf(t reflect.Type) *Schema {
+	nullable := false
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		nullable = true
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time", Nullable: nullable}
+	case t == fileHeaderType:
+		return &Schema{Type: "string", Format: "binary"}
+	case t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		if t.Name() == "UUID" {
+			return &Schema{Type: "string", Format: "uuid", Nullable: nullable}
+		}
+
+		return &Schema{Type: "string", Nullable: nullable}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean", Nullable: nullable}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer", Nullable: nullable}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0.0
+		return &Schema{Type: "integer", Minimum: &zero, Nullable: nullable}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number", Nullable: nullable}
+	case reflect.String:
+		return &Schema{Type: "string", Nullable: nullable}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte", Nullable: nullable}
+		}
+
+		return &Schema{Type: "array", Items: s.schemaOf(t.Elem()), Nullable: nullable}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schemaOf(t.Elem()), Nullable: nullable}
+	case reflect.Struct:
+		if len(t.Name()) == 0 {
+			return s.structSchema(t)
+		}
+
+		// registered before its fields are walked so a recursive type
+		// references itself instead of looping
+		if _, ok := s.components[t.Name()]; !ok {
+			s.components[t.Name()] = &Schema{}
+			*s.components[t.Name()] = *s.structSchema(t)
+		}
+
+		return &Schema{Ref: "#/components/schemas/" + t.Name()}
+	}
+
+	return &Schema{}
+}
+
+func (s *Spec) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+
+		// the fields of an embedded struct are encoded as fields of the parent
+		if field.Anonymous && len(name) == 0 {
+			embedded := s.resolve(s.schemaOf(field.Type))
+
+			for key, property := range embedded.Properties {
+				schema.Properties[key] = property
+			}
+
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+
+		if len(name) == 0 {
+			name = field.Name
+		}
+
+		property := s.schemaOf(field.Type)
+
+		if applyValidateTag(property, field.Tag.Get("validate")) {
+			schema.Required = append(schema.Required, name)
+		}
+
+		schema.Properties[name] = property
+	}
+
+	return schema
+}
+
+// applyValidateTag turns the rules of a validate tag into constraints of the
+// schema and tells whether the field is required. Rules after dive are about
+// the elements and are left to the handler, like the rules with no OpenAPI
+// equivalent
+func applyValidateTag(schema *Schema, tag string) bool {
+	required := false
+
+	if len(schema.Ref) != 0 || len(tag) == 0 {
+		return strings.HasPrefix(tag, "required,") || tag == "required"
+	}
+
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		number, numberErr := strconv.ParseFloat(param, 64)
+
+		switch {
+		case name == "dive":
+			return required
+		case name == "required":
+			required = true
+		case name == "omitempty":
+			schema.omitEmpty = true
+		case name == "email":
+			schema.Format = "email"
+		case name == "url":
+			schema.Format = "uri"
+		case name == "uuid":
+			schema.Format = "uuid"
+		case name == "oneof":
+			for _, value := range strings.Fields(param) {
+				schema.Enum = append(schema.Enum, enumValue(schema, value))
+			}
+		case name == "eq":
+			schema.Enum = []interface{}{enumValue(schema, param)}
+		case numberErr != nil:
+			continue
+		case name == "min" || name == "gte":
+			schema.setMin(number)
+		case name == "max" || name == "lte":
+			schema.setMax(number)
+		case name == "len":
+			schema.setMin(number)
+			schema.setMax(number)
+		}
+	}
+
+	return required
+}
+
+func enumValue(schema *Schema, value string) interface{} {
+	switch schema.Type {
+	case "boolean":
+		return value == "true"
+	case "integer", "number":
+		number, _ := strconv.ParseFloat(value, 64)
+		return number
+	}
+
+	return value
+}
+
+func (schema *Schema) setMin(n float64) {
+	switch schema.Type {
+	case "string":
+		schema.MinLength = &n
+	case "array":
+		schema.MinItems = &n
+	case "integer", "number":
+		schema.Minimum = &n
+	}
+}
+
+func (schema *Schema) setMax(n float64) {
+	switch schema.Type {
+	case "string":
+		schema.MaxLength = &n
+	case "array":
+		schema.MaxItems = &n
+	case "integer", "number":
+		schema.Maximum = &n
+	}
+}
+
+func (s *Spec) resolve(schema *Schema) *Schema {
+	if len(schema.Ref) == 0 {
+		return schema
+	}
+
+	return s.components[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+}
@@ -0,0 +1,208 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"music-sharing/shared/apperrors"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validateBody rejects the requests whose json body doesn't match the schema
+// and puts the body back for the handler to bind
+func (s *Spec) validateBody(schema *Schema) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw, err := io.ReadAll(c.Request.Body)
+
+		if err != nil {
+			c.Error(apperrors.Validation("the request body could not be read"))
+			c.Abort()
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(raw))
+
+		var value interface{}
+
+		if len(bytes.TrimSpace(raw)) != 0 {
+			if err := json.Unmarshal(raw, &value); err != nil {
+				c.Error(apperrors.Validation("the request body is not valid json"))
+				c.Abort()
+				return
+			}
+		}
+
+		if value == nil {
+			value = map[string]interface{}{}
+		}
+
+		if fields := s.validate(schema, value, ""); len(fields) != 0 {
+			c.Error(apperrors.Validation("the request body does not match the schema", fields...))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func (s *Spec) validate(schema *Schema, value interface{}, at string) []apperrors.FieldError {
+	schema = s.resolve(schema)
+
+	if value == nil {
+		return nil
+	}
+
+	field := at
+
+	if len(field) == 0 {
+		field = "body"
+	}
+
+	if schema.omitEmpty && isZero(value) {
+		return nil
+	}
+
+	switch schema.Type {
+	case "object":
+		properties, ok := value.(map[string]interface{})
+
+		if !ok {
+			return []apperrors.FieldError{apperrors.Field(field, "must be an object")}
+		}
+
+		return s.validateObject(schema, properties, at)
+	case "array":
+		items, ok := value.([]interface{})
+
+		if !ok {
+			return []apperrors.FieldError{apperrors.Field(field, "must be an array")}
+		}
+
+		fields := checkBounds(field, float64(len(items)), schema.MinItems, schema.MaxItems, "must have at least %v items", "must have at most %v items")
+
+		for i, item := range items {
+			fields = append(fields, s.validate(schema.Items, item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+
+		return fields
+	case "string":
+		str, ok := value.(string)
+
+		if !ok {
+			return []apperrors.FieldError{apperrors.Field(field, "must be a string")}
+		}
+
+		if fields := checkEnum(field, schema, str); len(fields) != 0 {
+			return fields
+		}
+
+		return checkBounds(field, float64(utf8.RuneCountInString(str)), schema.MinLength, schema.MaxLength, "must be at least %v characters long", "must be at most %v characters long")
+	case "integer", "number":
+		number, ok := value.(float64)
+
+		if !ok {
+			return []apperrors.FieldError{apperrors.Field(field, "must be a number")}
+		}
+
+		if schema.Type == "integer" && number != math.Trunc(number) {
+			return []apperrors.FieldError{apperrors.Field(field, "must be an integer")}
+		}
+
+		if fields := checkEnum(field, schema, number); len(fields) != 0 {
+			return fields
+		}
+
+		return checkBounds(field, number, schema.Minimum, schema.Maximum, "must be at least %v", "must be at most %v")
+	case "boolean":
+		boolean, ok := value.(bool)
+
+		if !ok {
+			return []apperrors.FieldError{apperrors.Field(field, "must be a boolean")}
+		}
+
+		return checkEnum(field, schema, boolean)
+	}
+
+	return nil
+}
+
+func (s *Spec) validateObject(schema *Schema, properties map[string]interface{}, at string) []apperrors.FieldError {
+	fields := []apperrors.FieldError{}
+
+	for _, name := range schema.Required {
+		if value, ok := properties[name]; !ok || value == nil || isZero(value) {
+			fields = append(fields, apperrors.Field(join(at, name), "is required"))
+		}
+	}
+
+	names := []string{}
+
+	for name := range properties {
+		names = append(names, name)
+	}
+
+	// sorted so the same body always gets the same errors
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := properties[name]
+
+		if property, ok := schema.Properties[name]; ok {
+			fields = append(fields, s.validate(property, value, join(at, name))...)
+		} else if schema.AdditionalProperties != nil {
+			fields = append(fields, s.validate(schema.AdditionalProperties, value, join(at, name))...)
+		}
+	}
+
+	return fields
+}
+
+func checkEnum(field string, schema *Schema, value interface{}) []apperrors.FieldError {
+	if len(schema.Enum) == 0 {
+		return nil
+	}
+
+	allowed := []string{}
+
+	for _, option := range schema.Enum {
+		if option == value {
+			return nil
+		}
+
+		allowed = append(allowed, fmt.Sprint(option))
+	}
+
+	return []apperrors.FieldError{apperrors.Field(field, "must be one of %s", strings.Join(allowed, ", "))}
+}
+
+func checkBounds(field string, n float64, min *float64, max *float64, tooSmall string, tooBig string) []apperrors.FieldError {
+	if min != nil && n < *min {
+		return []apperrors.FieldError{apperrors.Field(field, tooSmall, *min)}
+	}
+
+	if max != nil && n > *max {
+		return []apperrors.FieldError{apperrors.Field(field, tooBig, *max)}
+	}
+
+	return nil
+}
+
+// the zero values are the ones the validate tags treat as empty
+func isZero(value interface{}) bool {
+	return value == "" || value == 0.0 || value == false
+}
+
+func join(at string, name string) string {
+	if len(at) == 0 {
+		return name
+	}
+
+	return at + "." + name
+}
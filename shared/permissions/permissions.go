@@ -0,0 +1,39 @@
+// Package permissions is the one table of the roles and of what they may do.
+// user-microservice stores the role of every user, music-microservice asks it
+// for the role of the caller and checks it against the same table
+package permissions
+
+const (
+	RoleUser      = "user"
+	RoleArtist    = "artist"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+
+	UploadTracks   = "tracks:upload"
+	ModerateTracks = "tracks:moderate"
+	ManageUsers    = "users:manage"
+	ManageRoles    = "users:roles"
+)
+
+var rolePermissions = map[string][]string{
+	RoleUser:      {UploadTracks},
+	RoleArtist:    {UploadTracks},
+	RoleModerator: {UploadTracks, ModerateTracks},
+	RoleAdmin:     {UploadTracks, ModerateTracks, ManageUsers, ManageRoles},
+}
+
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+
+	return ok
+}
+
+func HasPermission(role string, permission string) bool {
+	for _, granted := range rolePermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,65 @@
+// Package rest holds the http conventions the handlers of both services share
+package rest
+
+import (
+	"music-sharing/shared/apperrors"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Version is the type of the version counter of a resource
+type Version interface {
+	~int | ~uint
+}
+
+// ETag of a versioned resource, it is strong so it can be sent back in If-Match
+func ETag[V Version](version V) string {
+	return `"` + strconv.FormatUint(uint64(version), 10) + `"`
+}
+
+// IfMatchVersion reads the versions the client accepts from the If-Match
+// header, ok is false when the request has none or sends *, which matches any
+// version. Updates match them so a client working on a stale copy can't
+// overwrite a newer edit
+func IfMatchVersion[V Version](c *gin.Context) (versions []V, ok bool, err error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+
+	if len(header) == 0 || header == "*" {
+		return nil, false, nil
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+
+		// If-Match compares strongly, a weak tag never matches
+		if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+
+		parsed, err := strconv.ParseUint(strings.Trim(tag, `"`), 10, 64)
+
+		if err == nil {
+			versions = append(versions, V(parsed))
+		}
+	}
+
+	if len(versions) == 0 {
+		return nil, false, apperrors.PreconditionFailed("the If-Match header does not match the current version")
+	}
+
+	return versions, true, nil
+}
+
+// Refuses the updates of a versioned resource sent without If-Match, only the
+// routes that always required it use it
+func RequireIfMatch(c *gin.Context) {
+	if len(c.GetHeader("If-Match")) == 0 {
+		c.Error(apperrors.PreconditionRequired("the If-Match header is required, send the ETag of the last read"))
+		c.Abort()
+		return
+	}
+
+	c.Next()
+}
@@ -0,0 +1,118 @@
+package rest
+
+import (
+	"music-sharing/shared/apperrors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func contextWithIfMatch(ifMatch string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPatch, "/", nil)
+
+	if len(ifMatch) != 0 {
+		c.Request.Header.Set("If-Match", ifMatch)
+	}
+
+	return c
+}
+
+func TestETag(t *testing.T) {
+	if etag := ETag(uint(7)); etag != `"7"` {
+		t.Fatalf("ETag(7) = %s, want \"7\"", etag)
+	}
+
+	if etag := ETag(0); etag != `"0"` {
+		t.Fatalf("ETag(0) = %s, want \"0\"", etag)
+	}
+}
+
+func TestIfMatchVersion(t *testing.T) {
+	cases := []struct {
+		name     string
+		ifMatch  string
+		versions []uint
+		ok       bool
+		status   int
+	}{
+		{name: "missing", ifMatch: "", ok: false},
+		{name: "any", ifMatch: "*", ok: false},
+		{name: "etag", ifMatch: `"12"`, versions: []uint{12}, ok: true},
+		{name: "unquoted", ifMatch: "12", versions: []uint{12}, ok: true},
+		{name: "padded", ifMatch: ` "3" `, versions: []uint{3}, ok: true},
+		{name: "list", ifMatch: `"3", "4","5"`, versions: []uint{3, 4, 5}, ok: true},
+		{name: "list with weak", ifMatch: `W/"3", "4"`, versions: []uint{4}, ok: true},
+		{name: "list with garbage", ifMatch: `"abc", "4"`, versions: []uint{4}, ok: true},
+		{name: "weak", ifMatch: `W/"12"`, status: http.StatusPreconditionFailed},
+		{name: "garbage", ifMatch: `"abc"`, status: http.StatusPreconditionFailed},
+		{name: "negative", ifMatch: `"-1"`, status: http.StatusPreconditionFailed},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			versions, ok, err := IfMatchVersion[uint](contextWithIfMatch(tc.ifMatch))
+
+			if tc.status != 0 {
+				if err == nil {
+					t.Fatalf("expected a %d error, got versions %v", tc.status, versions)
+				}
+
+				if status := apperrors.From(err).Status; status != tc.status {
+					t.Fatalf("status = %d, want %d", status, tc.status)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(versions, tc.versions) || ok != tc.ok {
+				t.Fatalf("got (%v, %v), want (%v, %v)", versions, ok, tc.versions, tc.ok)
+			}
+		})
+	}
+}
+
+func TestRequireIfMatch(t *testing.T) {
+	router := gin.New()
+	router.Use(apperrors.Handler())
+	router.PATCH("/tracks", RequireIfMatch, func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	cases := []struct {
+		name    string
+		ifMatch string
+		status  int
+	}{
+		{name: "missing", ifMatch: "", status: http.StatusPreconditionRequired},
+		{name: "present", ifMatch: `"1"`, status: http.StatusNoContent},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPatch, "/tracks", nil)
+
+			if len(tc.ifMatch) != 0 {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.status {
+				t.Fatalf("status = %d, want %d", w.Code, tc.status)
+			}
+		})
+	}
+}
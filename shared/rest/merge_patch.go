@@ -0,0 +1,49 @@
+package rest
+
+import (
+	"encoding/json"
+	"io"
+	"music-sharing/shared/validation"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BindMergePatch reads a JSON Merge Patch (RFC 7386) into body, a struct of
+// pointers where nil means the member was left out and the field must not
+// change. A null member asks for the field to be removed, the names of those
+// members are returned for the caller to apply or refuse
+func BindMergePatch(body interface{}, c *gin.Context) ([]string, error) {
+
+	raw, err := io.ReadAll(c.Request.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	members := map[string]json.RawMessage{}
+
+	if err := json.Unmarshal(raw, &members); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(raw, body); err != nil {
+		return nil, err
+	}
+
+	if err := validation.Struct(body); err != nil {
+		return nil, err
+	}
+
+	removed := []string{}
+
+	for name, value := range members {
+		if string(value) == "null" {
+			removed = append(removed, name)
+		}
+	}
+
+	sort.Strings(removed)
+
+	return removed, nil
+}
@@ -0,0 +1,22 @@
+package rest
+
+import (
+	"os"
+	"strings"
+)
+
+// TrustedProxies reads the comma separated addresses or CIDRs of the proxies
+// in front of the service from TRUSTED_PROXIES. The client ip of the rate
+// limits and of the login attempts is only taken from the forwarding headers
+// they set, without any the address of the connection is used
+func TrustedProxies() []string {
+	proxies := []string{}
+
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); len(proxy) != 0 {
+			proxies = append(proxies, proxy)
+		}
+	}
+
+	return proxies
+}
@@ -0,0 +1,40 @@
+// Package validation checks the validate tags of the request bodies with a
+// single validator, so its cache of struct rules is shared by every handler
+package validation
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var validate = newValidator()
+
+// The fields are named after their json key, or their form key for the
+// multipart forms, so the errors tell the names the client sent
+func newValidator() *validator.Validate {
+	v := validator.New()
+
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "form"} {
+			name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+
+			if name == "-" {
+				return ""
+			}
+
+			if len(name) != 0 {
+				return name
+			}
+		}
+
+		return field.Name
+	})
+
+	return v
+}
+
+func Struct(value interface{}) error {
+	return validate.Struct(value)
+}
@@ -1,11 +1,21 @@
 package main
 
 import (
+	"context"
+	"log"
+	"music-sharing/shared/idempotency"
+	"music-sharing/shared/logging"
+	"music-sharing/shared/openapi"
+	"music-sharing/shared/permissions"
+	"music-sharing/shared/rest"
 	"music-sharing/user-microservice/internal/app"
 	"music-sharing/user-microservice/internal/app/middlewares"
+	"music-sharing/user-microservice/internal/app/subscribers"
+	"music-sharing/user-microservice/internal/app/workers"
 	config "music-sharing/user-microservice/pkg"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -21,12 +31,25 @@ func main() {
 		panic(err)
 	}
 
-	router := gin.Default()
+	subscribers.Register(config.Container.Broker)
+	config.Container.OutboxRelay.Start(context.Background())
+	workers.NewAccountDeletionWorker(time.Minute).Start(context.Background())
+	workers.NewDataExportWorker(10 * time.Second).Start(context.Background())
+	workers.NewIdempotencyKeyWorker(time.Hour).Start(context.Background())
+
+	// the access log of gin.Default would write the secrets of the queries
+	router := gin.New()
+	router.Use(logging.Logger(), gin.Recovery())
+
+	if err := router.SetTrustedProxies(rest.TrustedProxies()); err != nil {
+		log.Fatal(err)
+	}
 	userController := &app.UserController{}
 
 	router.Static("/profiles", "./internal/static/profiles/")
 
 	router.Use(middlewares.ErrorHandlerMiddleware)
+	router.Use(middlewares.IdempotencyMiddleware)
 
 	router.GET("/", func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -34,14 +57,86 @@ func main() {
 		})
 	})
 
-	router.POST("/login", userController.Login)
-	router.POST("/register", userController.Register)
-	router.GET("/myProfile", middlewares.AuthMiddleware, userController.MyProfile)
-	router.GET("/viewProfile/:userId", userController.ViewProfile)
-	router.GET("/followUser/:userId", middlewares.AuthMiddleware, userController.FollowUser)
-	router.GET("/unfollowUser/:userId", middlewares.AuthMiddleware, userController.UnfollowUser)
-	router.GET("/updateMyAccount", middlewares.AuthMiddleware, userController.UpdateMyAccount)
-	router.POST("/uploadProfile", middlewares.AuthMiddleware, userController.UploadProfile)
+	spec := openapi.NewSpec("user-microservice", "1.0.0")
+	router.GET("/openapi.json", spec.Serve)
+
+	// the routes before v1 keep working as deprecated aliases of the v1 ones
+	v1 := router.Group("/v1")
+	routes := spec.Router(v1, &router.RouterGroup)
+	userId := map[string]string{"id": "userId"}
+
+	routes.Handle(openapi.Operation{Method: "POST", Path: "/sessions", Summary: "Log in", Tag: "sessions", Body: app.LoginBody{}, Legacy: "POST /login"}, idempotency.Withhold, userController.Login)
+	routes.Handle(openapi.Operation{Method: "POST", Path: "/sessions/twoFactor", Summary: "Finish a login with the second factor", Tag: "sessions", Body: app.VerifyTwoFactorBody{}, Legacy: "POST /verifyTwoFactor"}, idempotency.Withhold, userController.VerifyTwoFactor)
+	routes.Handle(openapi.Operation{Method: "GET", Path: "/oauth/:provider/login", Summary: "Log in with an identity provider", Tag: "sessions", Legacy: "GET /oauth/:provider/login"}, userController.OIDCLogin)
+	routes.Handle(openapi.Operation{Method: "GET", Path: "/oauth/:provider/callback", Summary: "Callback of an identity provider", Tag: "sessions", Query: []string{"state", "code", "error"}, Legacy: "GET /oauth/:provider/callback"}, userController.OIDCCallback)
+	routes.Handle(openapi.Operation{Method: "POST", Path: "/users", Summary: "Register", Tag: "account", Body: app.RegisterBody{}, Legacy: "POST /register"}, idempotency.Withhold, userController.Register)
+	routes.Handle(openapi.Operation{Method: "POST", Path: "/emailVerifications", Summary: "Verify the email of an account", Tag: "account", Body: app.VerifyEmailBody{}, Legacy: "POST /verifyEmail"}, userController.VerifyEmail)
+	routes.Handle(openapi.Operation{Method: "GET", Path: "/emailVerifications", Summary: "Page of the link of the verification email", Tag: "account", Query: []string{"token"}, Legacy: "GET /verifyEmail"}, userController.VerifyEmailPage)
+	routes.Handle(openapi.Operation{Method: "POST", Path: "/me/emailVerifications", Summary: "Send the verification email again", Tag: "account", Auth: true, Legacy: "POST /resendVerificationEmail"}, middlewares.AuthMiddleware, userController.ResendVerificationEmail)
+	routes.Handle(openapi.Operation{Method: "POST", Path: "/passwordResets", Summary: "Send a password reset email", Tag: "account", Body: app.ForgotPasswordBody{}, Legacy: "POST /forgotPassword"}, userController.ForgotPassword)
+	routes.Handle(openapi.Operation{Method: "POST", Path: "/passwordResets/confirmation", Summary: "Reset a password", Tag: "account", Body: app.ResetPasswordBody{}, Legacy: "POST /resetPassword"}, userController.ResetPassword)
+	routes.Handle(openapi.Operation{Method: "GET", Path: "/passwordResets/confirmation", Summary: "Page of the link of the password reset email", Tag: "account", Query: []string{"token"}, Legacy: "GET /resetPassword"}, userController.ResetPasswordPage)
+	routes.Handle(openapi.Operation{Method: "GET", Path: "/me", Summary: "Profile of the caller", Tag: "account", Auth: true, Legacy: "GET /myProfile"}, middlewares.AuthMiddleware, userController.MyProfile)
+	routes.Handle(openapi.Operation{Method: "PATCH", Path: "/me", Summary: "Update the account of the caller", Tag: "account", Auth: true, Body: app.UpdateAccountBody{}, Versioned: true, Legacy: "GET /updateMyAccount"}, middlewares.AuthMiddleware, userController.UpdateMyAccount)
+	routes.Handle(openapi.Operation{Method: "PUT", Path: "/me/avatar", Summary: "Upload the profile picture", Tag: "account", Auth: true, Form: app.UploadProfileForm{}, Legacy: "POST /uploadProfile"}, middlewares.AuthMiddleware, userController.UploadProfile)
+	routes.Handle(openapi.Operation{Method: "PUT", Path: "/me/password", Summary: "Change the password", Tag: "account", Auth: true, Body: app.ChangePasswordBody{}, Legacy: "POST /changePassword"}, middlewares.AuthMiddleware, idempotency.Withhold, userController.ChangePassword)
+	routes.Handle(openapi.Operation{Method: "POST", Path: "/passwordChanges/confirmation", Summary: "Confirm the first password of a passwordless account", Tag: "account", Body: app.ConfirmPasswordChangeBody{}}, userController.ConfirmPasswordChange)
+	routes.Handle(openapi.Operation{Method: "GET", Path: "/passwordChanges/confirmation", Summary: "Page of the link of the password confirmation email", Tag: "account", Query: []string{"token"}}, userController.ConfirmPasswordChangePage)
+	routes.Handle(openapi.Operation{Method: "POST", Path: "/me/emailChanges", Summary: "Ask to change the email", Tag: "account", Auth: true, Body: app.ChangeEmailBody{}, Legacy: "POST /changeEmail"}, middlewares.AuthMiddleware, userController.ChangeEmail)
+	routes.Handle(openapi.Operation{Method: "POST", Path: "/emailChanges/confirmation", Summary: "Confirm an email change", Tag: "account", Body: app.ConfirmEmailChangeBody{}, Legacy: "POST /confirmEmailChange"}, userController.ConfirmEmailChange)
+	routes.Handle(openapi.Operation{Method: "GET", Path: "/emailChanges/confirmation", Summary: "Page of the link of the email change confirmation", Tag: "account", Query: []string{"token"}, Legacy: "GET /confirmEmailChange"}, userController.ConfirmEmailChangePage)
+	routes.Handle(openapi.Operation{Method: "PUT", Path: "/me/deletion", Summary: "Schedule the deletion of the account", Tag: "account", Auth: true, Body: app.DeleteAccountBody{}, Legacy: "POST /deleteMyAccount"}, middlewares.AuthMiddleware, userController.DeleteMyAccount)
+	routes.Handle(openapi.Operation{Method: "POST", Path: "/accountDeletions/confirmation", Summary: "Confirm the deletion of a passwordless account", Tag: "account", Body: app.ConfirmAccountDeletionBody{}}, userController.ConfirmAccountDeletion)
+	routes.Handle(openapi.Operation{Method: "GET", Path: "/accountDeletions/confirmation", Summary: "Page of the link of the account deletion confirmation email", Tag: "account", Query: []string{"token"}}, userController.ConfirmAccountDeletionPage)
+	routes.Handle(openapi.Operation{Method: "DELETE", Path: "/me/deletion", Summary: "Cancel the deletion of the account", Tag: "account", Auth: true, Legacy: "POST /cancelAccountDeletion"}, middlewares.AuthMiddleware, userController.CancelAccountDeletion)
+	routes.Handle(openapi.Operation{Method: "GET", Path: "/me/deletion", Summary: "Scheduled deletion of the account", Tag: "account", Auth: true, Legacy: "GET /myAccountDeletion"}, middlewares.AuthMiddleware, userController.MyAccountDeletion)
+	routes.Handle(openapi.Operation{Method: "POST", Path: "/me/dataExports", Summary: "Request an export of the data of the account", Tag: "account", Auth: true, Legacy: "POST /requestDataExport"}, middlewares.AuthMiddleware, userController.RequestDataExport)
+	routes.Handle(openapi.Operation{Method: "GET", Path: "/me/dataExports", Summary: "Data exports of the caller", Tag: "account", Auth: true, Legacy: "GET /myDataExports"}, middlewares.AuthMiddleware, userController.MyDataExports)
+	routes.Handle(openapi.Operation{Method: "GET", Path: "/me/dataExports/:id/download", Summary: "Download a data export", Tag: "account", Auth: true, Params: map[string]string{"id": "exportId"}, Legacy: "GET /myDataExports/:exportId/download"}, middlewares.AuthMiddleware, userController.DownloadDataExport)
+	routes.Handle(openapi.Operation{Method: "POST", Path: "/me/twoFactor", Summary: "Start the enrollment of two factor authentication", Tag: "twoFactor", Auth: true, Legacy: "POST /enrollTwoFactor"}, middlewares.AuthMiddleware, idempotency.Withhold, userController.EnrollTwoFactor)
+	routes.Handle(openapi.Operation{Method: "POST", Path: "/me/twoFactor/confirmation", Summary: "Confirm the enrollment of two factor authentication", Tag: "twoFactor", Auth: true, Body: app.ConfirmTwoFactorBody{}, Legacy: "POST /confirmTwoFactor"}, middlewares.AuthMiddleware, idempotency.Withhold, userController.ConfirmTwoFactor)
+	routes.Handle(openapi.Operation{Method: "DELETE", Path: "/me/twoFactor", Summary: "Disable two factor authentication", Tag: "twoFactor", Auth: true, Body: app.DisableTwoFactorBody{}, Legacy: "POST /disableTwoFactor"}, middlewares.AuthMiddleware, userController.DisableTwoFactor)
+	routes.Handle(openapi.Operation{Method: "GET", Path: "/me/identities", Summary: "Identities linked to the account", Tag: "identities", Auth: true, Legacy: "GET /myIdentities"}, middlewares.AuthMiddleware, userController.MyIdentities)
+	routes.Handle(openapi.Operation{Method: "PUT", Path: "/me/identities/:provider", Summary: "Link an identity provider, redirects to it", Tag: "identities", Auth: true, Legacy: "POST /oauth/:provider/link"}, middlewares.AuthMiddleware, idempotency.Withhold, userController.OIDCLink)
+	routes.Handle(openapi.Operation{Method: "DELETE", Path: "/me/identities/:provider", Summary: "Unlink an identity provider", Tag: "identities", Auth: true, Legacy: "POST /oauth/:provider/unlink"}, middlewares.AuthMiddleware, userController.OIDCUnlink)
+	routes.Handle(openapi.Operation{Method: "GET", Path: "/users/:id", Summary: "Profile of a user", Tag: "users", Params: userId, Legacy: "GET /viewProfile/:userId"}, middlewares.OptionalAuthMiddleware, userController.ViewProfile)
+	routes.Handle(openapi.Operation{Method: "POST", Path: "/users/:id/followers", Summary: "Follow a user", Tag: "users", Auth: true, Params: userId, Legacy: "GET /followUser/:userId"}, middlewares.AuthMiddleware, userController.FollowUser)
+	routes.Handle(openapi.Operation{Method: "DELETE", Path: "/users/:id/followers", Summary: "Unfollow a user", Tag: "users", Auth: true, Params: userId, Legacy: "GET /unfollowUser/:userId"}, middlewares.AuthMiddleware, userController.UnfollowUser)
+	routes.Handle(openapi.Operation{Method: "GET", Path: "/me/followRequests", Summary: "Pending requests to follow the caller", Tag: "users", Auth: true}, middlewares.AuthMiddleware, userController.MyFollowRequests)
+	routes.Handle(openapi.Operation{Method: "POST", Path: "/me/followRequests/:id/approval", Summary: "Approve a request to follow the caller", Tag: "users", Auth: true, Params: userId}, middlewares.AuthMiddleware, userController.ApproveFollowRequest)
+	routes.Handle(openapi.Operation{Method: "DELETE", Path: "/me/followRequests/:id", Summary: "Deny a request to follow the caller", Tag: "users", Auth: true, Params: userId}, middlewares.AuthMiddleware, userController.DenyFollowRequest)
+	routes.Handle(openapi.Operation{Method: "GET", Path: "/me/blocks", Summary: "Users blocked and muted by the caller", Tag: "users", Auth: true, Legacy: "GET /myBlocks"}, middlewares.AuthMiddleware, userController.MyBlocks)
+	routes.Handle(openapi.Operation{Method: "PUT", Path: "/me/blocks/:id", Summary: "Block a user", Tag: "users", Auth: true, Params: userId, Legacy: "POST /blockUser/:userId"}, middlewares.AuthMiddleware, userController.BlockUser)
+	routes.Handle(openapi.Operation{Method: "DELETE", Path: "/me/blocks/:id", Summary: "Unblock a user", Tag: "users", Auth: true, Params: userId, Legacy: "POST /unblockUser/:userId"}, middlewares.AuthMiddleware, userController.UnblockUser)
+	routes.Handle(openapi.Operation{Method: "PUT", Path: "/me/mutes/:id", Summary: "Mute a user", Tag: "users", Auth: true, Params: userId, Legacy: "POST /muteUser/:userId"}, middlewares.AuthMiddleware, userController.MuteUser)
+	routes.Handle(openapi.Operation{Method: "DELETE", Path: "/me/mutes/:id", Summary: "Unmute a user", Tag: "users", Auth: true, Params: userId, Legacy: "POST /unmuteUser/:userId"}, middlewares.AuthMiddleware, userController.UnmuteUser)
+	routes.Handle(openapi.Operation{Method: "GET", Path: "/me/feed", Summary: "Activity feed of the caller", Tag: "feed", Auth: true, Query: []string{"cursor", "limit"}, Legacy: "GET /myFeed"}, middlewares.AuthMiddleware, userController.MyFeed)
+	routes.Handle(openapi.Operation{Method: "PUT", Path: "/me/feed/seen", Summary: "Mark the feed as seen", Tag: "feed", Auth: true, Body: app.MarkFeedSeenBody{}, Legacy: "POST /myFeed/seen"}, middlewares.AuthMiddleware, userController.MarkFeedSeen)
+	routes.Handle(openapi.Operation{Method: "GET", Path: "/me/notifications", Summary: "Notifications of the caller", Tag: "notifications", Auth: true, Query: []string{"cursor", "limit", "unread"}, Legacy: "GET /notifications"}, middlewares.AuthMiddleware, userController.MyNotifications)
+	routes.Handle(openapi.Operation{Method: "POST", Path: "/me/notifications/read", Summary: "Mark notifications as read", Tag: "notifications", Auth: true, Body: app.MarkNotificationsReadBody{}, Legacy: "POST /notifications/read"}, middlewares.AuthMiddleware, userController.MarkNotificationsRead)
+	routes.Handle(openapi.Operation{Method: "POST", Path: "/me/notifications/streamTickets", Summary: "Ticket to open the notification stream with", Tag: "notifications", Auth: true}, middlewares.AuthMiddleware, idempotency.Withhold, userController.CreateStreamTicket)
+	routes.Handle(openapi.Operation{Method: "GET", Path: "/me/notifications/stream", Summary: "Server-sent events of the new notifications", Tag: "notifications", Auth: true, Query: []string{"ticket", "token"}, Legacy: "GET /notifications/stream"}, middlewares.StreamAuthMiddleware, userController.StreamNotifications)
+	routes.Handle(openapi.Operation{Method: "GET", Path: "/me/notificationPreferences", Summary: "Notification preferences of the caller", Tag: "notifications", Auth: true, Legacy: "GET /notificationPreferences"}, middlewares.AuthMiddleware, userController.MyNotificationPreferences)
+	routes.Handle(openapi.Operation{Method: "PUT", Path: "/me/notificationPreferences", Summary: "Update the notification preferences", Tag: "notifications", Auth: true, Body: map[string]bool{}, Legacy: "POST /notificationPreferences"}, middlewares.AuthMiddleware, userController.UpdateNotificationPreferences)
+
+	admin := spec.Router(v1.Group("/admin", middlewares.AuthMiddleware), router.Group("/admin", middlewares.AuthMiddleware))
+	admin.Handle(openapi.Operation{Method: "GET", Path: "/users", Summary: "Search the users", Tag: "admin", Auth: true, Query: []string{"q", "role", "page", "pageSize"}, Legacy: "GET /users"}, middlewares.RequirePermission(permissions.ManageUsers), userController.ListUsers)
+	admin.Handle(openapi.Operation{Method: "PUT", Path: "/users/:id/role", Summary: "Change the role of a user", Tag: "admin", Auth: true, Body: app.ChangeUserRoleBody{}, Params: userId, Legacy: "POST /users/:userId/role"}, middlewares.RequirePermission(permissions.ManageRoles), userController.ChangeUserRole)
+	admin.Handle(openapi.Operation{Method: "DELETE", Path: "/users/:id/lock", Summary: "Unlock an account locked by failed logins", Tag: "admin", Auth: true, Params: userId, Legacy: "POST /users/:userId/unlock"}, middlewares.RequirePermission(permissions.ManageUsers), userController.UnlockAccount)
+	admin.Handle(openapi.Operation{Method: "PUT", Path: "/users/:id/suspension", Summary: "Suspend a user", Tag: "admin", Auth: true, Body: app.SuspendUserBody{}, Params: userId, Legacy: "POST /users/:userId/suspend"}, middlewares.RequirePermission(permissions.ManageUsers), userController.SuspendUser)
+	admin.Handle(openapi.Operation{Method: "DELETE", Path: "/users/:id/suspension", Summary: "Lift the suspension of a user", Tag: "admin", Auth: true, Params: userId, Legacy: "POST /users/:userId/unsuspend"}, middlewares.RequirePermission(permissions.ManageUsers), userController.UnsuspendUser)
+	admin.Handle(openapi.Operation{Method: "DELETE", Path: "/users/:id/sessions", Summary: "Log a user out of every session", Tag: "admin", Auth: true, Params: userId, Legacy: "POST /users/:userId/forceLogout"}, middlewares.RequirePermission(permissions.ManageUsers), userController.ForceLogoutUser)
+	admin.Handle(openapi.Operation{Method: "DELETE", Path: "/users/:id/twoFactor", Summary: "Reset the two factor authentication of a user", Tag: "admin", Auth: true, Params: userId, Legacy: "POST /users/:userId/resetTwoFactor"}, middlewares.RequirePermission(permissions.ManageUsers), userController.ResetTwoFactor)
+	admin.Handle(openapi.Operation{Method: "GET", Path: "/auditLog", Summary: "Audit log of the admin actions", Tag: "admin", Auth: true, Query: []string{"action", "actorId", "targetId", "page", "pageSize"}, Legacy: "GET /auditLog"}, middlewares.RequirePermission(permissions.ManageUsers), userController.GetAuditLog)
+
+	internal := router.Group("/internal", middlewares.InternalMiddleware)
+	internal.POST("/users/:userId/unlock", userController.UnlockAccount)
+	internal.POST("/users/:userId/role", userController.ChangeUserRole)
+	internal.POST("/users/:userId/suspend", userController.SuspendUser)
+	internal.POST("/users/:userId/notify", userController.NotifyUser)
+	internal.GET("/users/:userId/viewerContext", userController.GetViewerContext)
+	internal.POST("/users/:userId/followings/lookup", userController.LookupFollowings)
+	internal.POST("/events", userController.ReceiveEvent)
 
 	router.Run()
 }
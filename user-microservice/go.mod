@@ -5,13 +5,14 @@ go 1.21.0
 require (
 	github.com/cloudinary/cloudinary-go/v2 v2.4.0
 	github.com/gin-gonic/gin v1.9.1
-	github.com/go-playground/validator/v10 v10.14.0
+	github.com/go-playground/validator/v10 v10.15.4
 	github.com/golang-jwt/jwt/v5 v5.0.0
 	github.com/google/uuid v1.2.0
 	github.com/joho/godotenv v1.5.1
 	golang.org/x/crypto v0.9.0
 	gorm.io/driver/mysql v1.5.1
 	gorm.io/gorm v1.25.4
+	music-sharing/shared v0.0.0
 )
 
 require (
@@ -43,3 +44,5 @@ require (
 	google.golang.org/protobuf v1.30.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace music-sharing/shared => ../shared
@@ -0,0 +1,202 @@
+package commands
+
+import (
+	"music-sharing/shared/apperrors"
+	"music-sharing/user-microservice/internal/app/events"
+	"music-sharing/user-microservice/internal/app/models"
+	config "music-sharing/user-microservice/pkg"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type (
+	// Suspends the account and revokes all its sessions
+	SuspendUserCommand struct {
+		UserID uuid.UUID
+		Reason string
+		Actor  *models.User
+	}
+
+	UnsuspendUserCommand struct {
+		UserID uuid.UUID
+		Actor  *models.User
+	}
+
+	ForceLogoutUserCommand struct {
+		UserID uuid.UUID
+		Actor  *models.User
+	}
+
+	// Turns two factor authentication off for a user who lost their device
+	// and their recovery codes
+	ResetTwoFactorCommand struct {
+		UserID uuid.UUID
+		Actor  *models.User
+	}
+)
+
+func findTargetUser(db *gorm.DB, userId uuid.UUID) (*models.User, error) {
+
+	user := &models.User{}
+
+	res := db.Find(user, "id = ?", userId)
+
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	if user.ID == uuid.Nil {
+		return nil, apperrors.NotFound("user doesnt exist")
+	}
+
+	return user, nil
+}
+
+func (cmd *SuspendUserCommand) Handle() error {
+
+	db := config.Container.Database
+
+	if cmd.Actor != nil && cmd.Actor.ID == cmd.UserID {
+		return apperrors.Forbidden("you cant suspend yourself")
+	}
+
+	user, err := findTargetUser(db, cmd.UserID)
+
+	if err != nil {
+		return err
+	}
+
+	// suspensions requested by the other microservices come from their
+	// outbox and may be delivered more than once
+	if user.SuspendedAt != nil && cmd.Actor == nil {
+		return nil
+	}
+
+	if user.SuspendedAt != nil {
+		return apperrors.Conflict("user is already suspended")
+	}
+
+	now := time.Now().UTC()
+	diff := auditDiff(
+		map[string]interface{}{"suspended": false, "suspendReason": user.SuspendReason},
+		map[string]interface{}{"suspended": true, "suspendReason": cmd.Reason},
+	)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+
+		res := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"suspended_at":   now,
+			"suspend_reason": cmd.Reason,
+			"token_version":  gorm.Expr("token_version + 1"),
+		})
+
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if err := recordAudit(tx, cmd.Actor, AuditUserSuspended, user.ID, diff); err != nil {
+			return err
+		}
+
+		return events.Record(tx, &events.UserSuspended{
+			UserID: user.ID,
+			Reason: cmd.Reason,
+		})
+	})
+}
+
+func (cmd *UnsuspendUserCommand) Handle() error {
+
+	db := config.Container.Database
+
+	user, err := findTargetUser(db, cmd.UserID)
+
+	if err != nil {
+		return err
+	}
+
+	if user.SuspendedAt == nil {
+		return apperrors.Conflict("user is not suspended")
+	}
+
+	diff := auditDiff(
+		map[string]interface{}{"suspended": true, "suspendReason": user.SuspendReason},
+		map[string]interface{}{"suspended": false, "suspendReason": ""},
+	)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+
+		res := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"suspended_at":   nil,
+			"suspend_reason": "",
+		})
+
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if err := recordAudit(tx, cmd.Actor, AuditUserUnsuspended, user.ID, diff); err != nil {
+			return err
+		}
+
+		return events.Record(tx, &events.UserUnsuspended{
+			UserID: user.ID,
+		})
+	})
+}
+
+func (cmd *ForceLogoutUserCommand) Handle() error {
+
+	db := config.Container.Database
+
+	user, err := findTargetUser(db, cmd.UserID)
+
+	if err != nil {
+		return err
+	}
+
+	diff := auditDiff(
+		map[string]interface{}{"tokenVersion": user.TokenVersion},
+		map[string]interface{}{"tokenVersion": user.TokenVersion + 1},
+	)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+
+		if res := tx.Model(&models.User{}).Where("id = ?", user.ID).Update("token_version", gorm.Expr("token_version + 1")); res.Error != nil {
+			return res.Error
+		}
+
+		return recordAudit(tx, cmd.Actor, AuditUserLoggedOut, user.ID, diff)
+	})
+}
+
+func (cmd *ResetTwoFactorCommand) Handle() error {
+
+	db := config.Container.Database
+
+	user, err := findTargetUser(db, cmd.UserID)
+
+	if err != nil {
+		return err
+	}
+
+	if !user.TOTPEnabled && len(user.TOTPSecret) == 0 {
+		return apperrors.Conflict("two factor authentication is not enabled")
+	}
+
+	diff := auditDiff(
+		map[string]interface{}{"totpEnabled": user.TOTPEnabled},
+		map[string]interface{}{"totpEnabled": false},
+	)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+
+		if err := disableTwoFactor(tx, user); err != nil {
+			return err
+		}
+
+		return recordAudit(tx, cmd.Actor, AuditTwoFactorReset, user.ID, diff)
+	})
+}
@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"encoding/json"
+	"music-sharing/user-microservice/internal/app/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	AuditUserSuspended   = "user.suspended"
+	AuditUserUnsuspended = "user.unsuspended"
+	AuditUserLoggedOut   = "user.force_logout"
+	AuditTwoFactorReset  = "user.two_factor_reset"
+	AuditRoleChanged     = "user.role_changed"
+	AuditAccountUnlocked = "user.unlocked"
+)
+
+type auditChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// Diff of the fields that changed between before and after
+func auditDiff(before map[string]interface{}, after map[string]interface{}) map[string]auditChange {
+	diff := map[string]auditChange{}
+
+	for field, to := range after {
+		if from := before[field]; from != to {
+			diff[field] = auditChange{From: from, To: to}
+		}
+	}
+
+	return diff
+}
+
+// Must be called with the transaction of the change so the entry is only
+// kept if the change is committed
+func recordAudit(tx *gorm.DB, actor *models.User, action string, targetId uuid.UUID, diff interface{}) error {
+
+	data, err := json.Marshal(diff)
+
+	if err != nil {
+		return err
+	}
+
+	entry := &models.AdminAuditLog{
+		ID:        uuid.New(),
+		Action:    action,
+		TargetID:  targetId,
+		Diff:      string(data),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if actor != nil {
+		entry.ActorID = &actor.ID
+	}
+
+	return tx.Create(entry).Error
+}
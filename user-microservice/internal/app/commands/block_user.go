@@ -0,0 +1,133 @@
+package commands
+
+import (
+	"music-sharing/shared/apperrors"
+	"music-sharing/user-microservice/internal/app/events"
+	"music-sharing/user-microservice/internal/app/models"
+	config "music-sharing/user-microservice/pkg"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type (
+	// Kind is models.BlockKindBlock or models.BlockKindMute
+	BlockUserCommand struct {
+		Kind        string
+		UserId      uuid.UUID
+		CurrentUser *models.User
+	}
+
+	UnblockUserCommand struct {
+		Kind        string
+		UserId      uuid.UUID
+		CurrentUser *models.User
+	}
+)
+
+func (cmd *BlockUserCommand) Handle() error {
+
+	db := config.Container.Database
+
+	if cmd.Kind != models.BlockKindBlock && cmd.Kind != models.BlockKindMute {
+		return apperrors.Validation("invalid block kind")
+	}
+
+	if cmd.UserId == cmd.CurrentUser.ID {
+		return apperrors.Validation("you cant " + cmd.Kind + " yourself")
+	}
+
+	user, err := findTargetUser(db, cmd.UserId)
+
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+
+		existing := &models.Block{}
+
+		if res := tx.Find(existing, "blocker_id = ? AND blocked_id = ?", cmd.CurrentUser.ID, user.ID); res.Error != nil {
+			return res.Error
+		}
+
+		// a mute never downgrades a block
+		if existing.Kind == models.BlockKindBlock {
+			return apperrors.Conflict("you already blocked this user")
+		}
+
+		if existing.Kind == models.BlockKindMute && cmd.Kind == models.BlockKindMute {
+			return apperrors.Conflict("you already muted this user")
+		}
+
+		block := &models.Block{BlockerID: cmd.CurrentUser.ID, BlockedID: user.ID, Kind: cmd.Kind}
+
+		if res := tx.Save(block); res.Error != nil {
+			return res.Error
+		}
+
+		if cmd.Kind == models.BlockKindBlock {
+			if _, err := removeFollowEdge(tx, &models.Follow{FollowerID: cmd.CurrentUser.ID, FolloweeID: user.ID}); err != nil {
+				return err
+			}
+
+			if _, err := removeFollowEdge(tx, &models.Follow{FollowerID: user.ID, FolloweeID: cmd.CurrentUser.ID}); err != nil {
+				return err
+			}
+
+			res := tx.Where("(requester_id = ? AND target_id = ?) OR (requester_id = ? AND target_id = ?)", cmd.CurrentUser.ID, user.ID, user.ID, cmd.CurrentUser.ID).
+				Delete(&models.FollowRequest{})
+
+			if res.Error != nil {
+				return res.Error
+			}
+		}
+
+		return events.Record(tx, &events.UserBlocked{
+			BlockerID: cmd.CurrentUser.ID,
+			BlockedID: user.ID,
+			Kind:      cmd.Kind,
+		})
+	})
+}
+
+func (cmd *UnblockUserCommand) Handle() error {
+
+	db := config.Container.Database
+
+	return db.Transaction(func(tx *gorm.DB) error {
+
+		res := tx.Where("blocker_id = ? AND blocked_id = ? AND kind = ?", cmd.CurrentUser.ID, cmd.UserId, cmd.Kind).Delete(&models.Block{})
+
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if res.RowsAffected == 0 {
+			return apperrors.Conflict("you didnt " + cmd.Kind + " this user")
+		}
+
+		return events.Record(tx, &events.UserUnblocked{
+			BlockerID: cmd.CurrentUser.ID,
+			BlockedID: cmd.UserId,
+			Kind:      cmd.Kind,
+		})
+	})
+}
+
+// True when one of the two users blocked the other
+func isBlockedBetween(db *gorm.DB, a uuid.UUID, b uuid.UUID) (bool, error) {
+
+	var count int64
+
+	res := db.Model(&models.Block{}).
+		Where("kind = ?", models.BlockKindBlock).
+		Where("(blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)", a, b, b, a).
+		Count(&count)
+
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	return count != 0, nil
+}
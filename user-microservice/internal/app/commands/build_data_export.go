@@ -0,0 +1,308 @@
+package commands
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"music-sharing/shared/apperrors"
+	"music-sharing/shared/internalapi"
+	"music-sharing/user-microservice/internal/app/models"
+	config "music-sharing/user-microservice/pkg"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type (
+	// Assembles the personal data of a user into a zip of json files
+	BuildDataExportCommand struct {
+		ExportID uuid.UUID
+	}
+
+	// Removes the archives of the exports that are no longer downloadable
+	ExpireDataExportsCommand struct{}
+
+	exportedProfile struct {
+		ID            uuid.UUID `json:"id"`
+		FullName      string    `json:"fullName"`
+		Gender        string    `json:"gender"`
+		Email         string    `json:"email"`
+		ProfileURL    string    `json:"profileUrl"`
+		IsPrivate     bool      `json:"isPrivate"`
+		Followers     uint      `json:"followers"`
+		Followings    uint      `json:"followings"`
+		CreatedAt     time.Time `json:"createdAt"`
+		LastUpdatedAt time.Time `json:"lastUpdatedAt"`
+	}
+
+	exportedFollows struct {
+		Followers  []models.Follow `json:"followers"`
+		Followings []models.Follow `json:"followings"`
+	}
+
+	exportedMusicData struct {
+		Likes            json.RawMessage `json:"likes"`
+		Tracks           json.RawMessage `json:"tracks"`
+		ListeningHistory json.RawMessage `json:"listeningHistory"`
+		Comments         json.RawMessage `json:"comments"`
+		Reposts          json.RawMessage `json:"reposts"`
+	}
+
+	exportedPlaylists struct {
+		Owned []string `json:"owned"`
+		Liked []string `json:"liked"`
+	}
+
+	exportFile struct {
+		name    string
+		content interface{}
+	}
+)
+
+// Read from DATA_EXPORT_TTL (e.g. "24h"), defaults to 48 hours
+func dataExportTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("DATA_EXPORT_TTL"))
+
+	if err != nil {
+		return 48 * time.Hour
+	}
+
+	return ttl
+}
+
+// A build failing for another reason than a client error is retried until
+// it has failed maxDataExportAttempts times
+const maxDataExportAttempts = 5
+
+// Client errors, such as the user being gone, fail the export on the spot
+func isFinalDataExportError(err error) bool {
+	var appErr *apperrors.Error
+
+	return errors.As(err, &appErr) && appErr.Status < http.StatusInternalServerError
+}
+
+// The client is told the detail of a client error, the causes of the other
+// failures only show up in the log of the worker
+func dataExportFailure(err error) string {
+	var appErr *apperrors.Error
+
+	if isFinalDataExportError(err) {
+		errors.As(err, &appErr)
+		return appErr.Detail
+	}
+
+	return "the export could not be built, please request a new one"
+}
+
+func dataExportsDir() string {
+	if dir := os.Getenv("DATA_EXPORTS_DIR"); len(dir) != 0 {
+		return dir
+	}
+
+	return "./internal/exports/"
+}
+
+func (cmd *BuildDataExportCommand) Handle() error {
+
+	db := config.Container.Database
+	export := &models.DataExport{}
+
+	res := db.Find(export, "id = ?", cmd.ExportID)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if export.ID == uuid.Nil {
+		return apperrors.NotFound("data export doesnt exist")
+	}
+
+	if export.Status != models.DataExportPending && export.Status != models.DataExportProcessing {
+		return nil
+	}
+
+	export.Status = models.DataExportProcessing
+
+	if res := db.Save(export); res.Error != nil {
+		return res.Error
+	}
+
+	filePath, err := buildDataExportArchive(export)
+
+	if err != nil {
+		export.Attempts++
+
+		// the worker picks a processing export up again on its next run
+		if isFinalDataExportError(err) || export.Attempts >= maxDataExportAttempts {
+			export.Status = models.DataExportFailed
+			export.Error = dataExportFailure(err)
+		}
+
+		db.Save(export)
+
+		return err
+	}
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(dataExportTTL())
+
+	export.Status = models.DataExportReady
+	export.FilePath = filePath
+	export.CompletedAt = &now
+	export.ExpiresAt = &expiresAt
+	export.Error = ""
+
+	return db.Save(export).Error
+}
+
+func buildDataExportArchive(export *models.DataExport) (string, error) {
+
+	db := config.Container.Database
+	user := &models.User{}
+
+	res := db.Find(user, "id = ?", export.UserID)
+
+	if res.Error != nil {
+		return "", res.Error
+	}
+
+	if user.ID == uuid.Nil {
+		return "", apperrors.NotFound("user doesnt exist")
+	}
+
+	follows := &exportedFollows{}
+
+	if res := db.Find(&follows.Followers, "followee_id = ?", user.ID); res.Error != nil {
+		return "", res.Error
+	}
+
+	if res := db.Find(&follows.Followings, "follower_id = ?", user.ID); res.Error != nil {
+		return "", res.Error
+	}
+
+	musicData := &exportedMusicData{}
+	url := os.Getenv("MUSIC_SERVICE") + "/internal/users/" + user.ID.String() + "/export"
+
+	if err := internalapi.Send("GET", url, nil, musicData); err != nil {
+		return "", err
+	}
+
+	playlists, err := userPlaylists(user.ID.String())
+
+	if err != nil {
+		return "", err
+	}
+
+	files := []exportFile{
+		{"profile.json", &exportedProfile{
+			ID:            user.ID,
+			FullName:      user.FullName,
+			Gender:        user.Gender,
+			Email:         user.Email,
+			ProfileURL:    user.ProfileURL,
+			IsPrivate:     user.IsPrivate,
+			Followers:     user.Followers,
+			Followings:    user.Followings,
+			CreatedAt:     user.CreatedAt,
+			LastUpdatedAt: user.UpdatedAt,
+		}},
+		{"follows.json", follows},
+		{"likes.json", musicData.Likes},
+		{"tracks.json", musicData.Tracks},
+		{"listening_history.json", musicData.ListeningHistory},
+		{"comments.json", musicData.Comments},
+		{"reposts.json", musicData.Reposts},
+		{"playlists.json", playlists},
+	}
+
+	if err := os.MkdirAll(dataExportsDir(), 0o700); err != nil {
+		return "", err
+	}
+
+	filePath := filepath.Join(dataExportsDir(), export.ID.String()+".zip")
+
+	if err := writeExportArchive(filePath, files); err != nil {
+		os.Remove(filePath)
+
+		return "", err
+	}
+
+	return filePath, nil
+}
+
+// Playlists live in playlist-microservice, only their ids are exported
+func userPlaylists(userId string) (*exportedPlaylists, error) {
+
+	playlists := &exportedPlaylists{Owned: []string{}, Liked: []string{}}
+	baseUrl := os.Getenv("PLAYLIST_SERVICE")
+
+	if len(baseUrl) == 0 {
+		return playlists, nil
+	}
+
+	if err := internalapi.Send("GET", baseUrl+"/internal/users/"+userId+"/playlists", nil, playlists); err != nil {
+		return nil, err
+	}
+
+	return playlists, nil
+}
+
+func writeExportArchive(filePath string, files []exportFile) error {
+
+	out, err := os.OpenFile(filePath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+
+	if err != nil {
+		return err
+	}
+
+	defer out.Close()
+
+	archive := zip.NewWriter(out)
+
+	for _, file := range files {
+		writer, err := archive.Create(file.name)
+
+		if err != nil {
+			return err
+		}
+
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(file.content); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+func (cmd *ExpireDataExportsCommand) Handle() error {
+
+	db := config.Container.Database
+	expired := []models.DataExport{}
+
+	res := db.Where("status = ? AND expires_at <= ?", models.DataExportReady, time.Now().UTC()).Find(&expired)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	for _, export := range expired {
+		if err := os.Remove(export.FilePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		export.Status = models.DataExportExpired
+		export.FilePath = ""
+
+		if res := db.Save(&export); res.Error != nil {
+			return res.Error
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"errors"
+	"music-sharing/shared/apperrors"
+	"music-sharing/shared/internalapi"
+	"strings"
+	"testing"
+)
+
+func TestDataExportFailure(t *testing.T) {
+	cases := []struct {
+		name    string
+		err     error
+		final   bool
+		message string
+	}{
+		{"user gone", apperrors.NotFound("user doesnt exist"), true, "user doesnt exist"},
+		{"driver error", errors.New("Error 1040: Too many connections"), false, "the export could not be built, please request a new one"},
+		{"music-microservice down", &internalapi.StatusError{Method: "GET", URL: "http://music/internal", Status: 502, Body: "bad gateway"}, false, "the export could not be built, please request a new one"},
+		{"internal error", apperrors.Internal(errors.New("disk full")), false, "the export could not be built, please request a new one"},
+	}
+
+	for _, tc := range cases {
+		if final := isFinalDataExportError(tc.err); final != tc.final {
+			t.Fatalf("%s: isFinalDataExportError = %v, want %v", tc.name, final, tc.final)
+		}
+
+		message := dataExportFailure(tc.err)
+
+		if message != tc.message {
+			t.Fatalf("%s: dataExportFailure = %q, want %q", tc.name, message, tc.message)
+		}
+
+		if strings.Contains(message, "1040") || strings.Contains(message, "disk") || strings.Contains(message, "http://") {
+			t.Fatalf("%s: dataExportFailure leaks the cause: %q", tc.name, message)
+		}
+	}
+}
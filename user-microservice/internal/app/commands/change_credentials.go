@@ -0,0 +1,342 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"music-sharing/shared/apperrors"
+	"music-sharing/user-microservice/internal/app/events"
+	"music-sharing/user-microservice/internal/app/models"
+	"music-sharing/user-microservice/internal/lib"
+	config "music-sharing/user-microservice/pkg"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type (
+	// Changes the password after checking the current one. Every session of the
+	// user is revoked and a fresh token is returned for the current one.
+	// Passwordless accounts have no current password, their first one is only
+	// set once the link sent to their email is opened, see
+	// ConfirmPasswordChangeCommand
+	ChangePasswordCommand struct {
+		CurrentPassword string
+		NewPassword     string
+		CurrentUser     *models.User
+		// Set by Handle to the new session token
+		Token string
+		// Set by Handle when the password waits for the emailed confirmation
+		ConfirmationSent bool
+	}
+
+	ConfirmPasswordChangeCommand struct {
+		Token string
+	}
+
+	// Starts an email change, the address is only replaced once the link sent
+	// to the new address is opened, see ConfirmEmailChangeCommand. The link of
+	// a passwordless account goes to its current address instead and the new
+	// one is verified after the change
+	ChangeEmailCommand struct {
+		Password    string
+		NewEmail    string
+		CurrentUser *models.User
+	}
+
+	ConfirmEmailChangeCommand struct {
+		Token string
+	}
+)
+
+const (
+	emailChangeTTL    = 24 * time.Hour
+	passwordChangeTTL = time.Hour
+)
+
+func (cmd *ChangePasswordCommand) Handle() error {
+
+	db := config.Container.Database
+	mailer := config.Container.Mailer
+	user := cmd.CurrentUser
+	passwordless := len(user.HashedPassword) == 0
+
+	if !passwordless {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(cmd.CurrentPassword)); err != nil {
+			return apperrors.Forbidden("invalid password")
+		}
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(cmd.NewPassword), bcrypt.DefaultCost)
+
+	if err != nil {
+		return err
+	}
+
+	if passwordless {
+		cmd.ConfirmationSent = true
+
+		return sendPasswordChangeConfirmation(user, string(hashedPassword))
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+
+		res := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"hashed_password": string(hashedPassword),
+			"token_version":   gorm.Expr("token_version + 1"),
+		})
+
+		if res.Error != nil {
+			return res.Error
+		}
+
+		// the new session is minted with the token version just written
+		if res := tx.First(user, "id = ?", user.ID); res.Error != nil {
+			return res.Error
+		}
+
+		return events.Record(tx, &events.PasswordChanged{
+			UserID: user.ID,
+		})
+	})
+
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf(
+		"Hi %s,\n\nThe password of your account was changed and you were signed out of your other sessions.\nIf it wasn't you, reset your password right away.\n",
+		user.FullName,
+	)
+
+	if err := mailer.Send(user.Email, "Your password was changed", body); err != nil {
+		log.Printf("password changed notification: %v", err)
+	}
+
+	cmd.Token, err = lib.CreateUserToken(user)
+
+	return err
+}
+
+func sendPasswordChangeConfirmation(user *models.User, hashedPassword string) error {
+
+	db := config.Container.Database
+
+	if res := db.Model(&models.User{}).Where("id = ?", user.ID).Update("pending_hashed_password", hashedPassword); res.Error != nil {
+		return res.Error
+	}
+
+	// only the latest requested password can be confirmed
+	token, err := lib.CreateSignedToken(lib.PasswordChangePurpose, map[string]interface{}{
+		"userId":   user.ID.String(),
+		"password": lib.Fingerprint(hashedPassword),
+	}, passwordChangeTTL)
+
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf(
+		"Hi %s,\n\nSomeone asked to set a password on your account. If it was you, confirm it by opening the link below within an hour:\n\n%s/v1/passwordChanges/confirmation?token=%s\n\nOtherwise you can ignore this email.\n",
+		user.FullName, appURL(), token,
+	)
+
+	return config.Container.Mailer.Send(user.Email, "Confirm the password of your account", body)
+}
+
+func (cmd *ConfirmPasswordChangeCommand) Handle() error {
+
+	db := config.Container.Database
+
+	claims, err := lib.ParseSignedToken(lib.PasswordChangePurpose, cmd.Token)
+
+	if err != nil {
+		return err
+	}
+
+	user := &models.User{}
+
+	res := db.Find(user, "id = ?", claims["userId"])
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	// a password set since then, e.g. by a reset, wins over the pending one
+	if user.ID == uuid.Nil || len(user.HashedPassword) != 0 || len(user.PendingHashedPassword) == 0 ||
+		lib.Fingerprint(user.PendingHashedPassword) != claims["password"] {
+		return apperrors.Validation("invalid or expired token")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+
+		res := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"hashed_password":         user.PendingHashedPassword,
+			"pending_hashed_password": "",
+			"token_version":           gorm.Expr("token_version + 1"),
+		})
+
+		if res.Error != nil {
+			return res.Error
+		}
+
+		return events.Record(tx, &events.PasswordChanged{
+			UserID: user.ID,
+		})
+	})
+}
+
+func (cmd *ChangeEmailCommand) Handle() error {
+
+	db := config.Container.Database
+	mailer := config.Container.Mailer
+	user := cmd.CurrentUser
+	passwordless := len(user.HashedPassword) == 0
+
+	if !passwordless {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(cmd.Password)); err != nil {
+			return apperrors.Forbidden("invalid password")
+		}
+	}
+
+	if cmd.NewEmail == user.Email {
+		return apperrors.Validation("the new email is the same as the current one")
+	}
+
+	var count int64
+
+	if res := db.Model(&models.User{}).Where("email = ?", cmd.NewEmail).Count(&count); res.Error != nil {
+		return res.Error
+	}
+
+	if count != 0 {
+		return apperrors.Conflict("email is already in use")
+	}
+
+	if res := db.Model(&models.User{}).Where("id = ?", user.ID).Update("pending_email", cmd.NewEmail); res.Error != nil {
+		return res.Error
+	}
+
+	token, err := lib.CreateSignedToken(lib.EmailChangePurpose, map[string]interface{}{
+		"userId":         user.ID.String(),
+		"email":          cmd.NewEmail,
+		"oldEmail":       user.Email,
+		"verifyNewEmail": passwordless,
+	}, emailChangeTTL)
+
+	if err != nil {
+		return err
+	}
+
+	// without a password only the owner of the current address can approve
+	// the change
+	if passwordless {
+		approval := fmt.Sprintf(
+			"Hi %s,\n\nSomeone asked to change the email address of your account to %s. If it was you, confirm it by opening the link below within 24 hours:\n\n%s/v1/emailChanges/confirmation?token=%s\n\nOtherwise you can ignore this email.\n",
+			user.FullName, cmd.NewEmail, appURL(), token,
+		)
+
+		return mailer.Send(user.Email, "Confirm the change of your email address", approval)
+	}
+
+	confirmation := fmt.Sprintf(
+		"Hi %s,\n\nConfirm that this is the new email address of your account by opening the link below within 24 hours:\n\n%s/v1/emailChanges/confirmation?token=%s\n",
+		user.FullName, appURL(), token,
+	)
+
+	if err := mailer.Send(cmd.NewEmail, "Confirm your new email address", confirmation); err != nil {
+		return err
+	}
+
+	notification := fmt.Sprintf(
+		"Hi %s,\n\nA request was made to change the email address of your account to %s.\nIf it wasn't you, change your password right away.\n",
+		user.FullName, cmd.NewEmail,
+	)
+
+	if err := mailer.Send(user.Email, "Your email address is being changed", notification); err != nil {
+		log.Printf("email change notification: %v", err)
+	}
+
+	return nil
+}
+
+func (cmd *ConfirmEmailChangeCommand) Handle() error {
+
+	db := config.Container.Database
+
+	claims, err := lib.ParseSignedToken(lib.EmailChangePurpose, cmd.Token)
+
+	if err != nil {
+		return err
+	}
+
+	user := &models.User{}
+
+	res := db.Find(user, "id = ?", claims["userId"])
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	// only the latest requested change can be confirmed, and only once
+	if user.ID == uuid.Nil || user.PendingEmail != claims["email"] || user.Email != claims["oldEmail"] {
+		return apperrors.Validation("invalid or expired token")
+	}
+
+	var count int64
+
+	if res := db.Model(&models.User{}).Where("email = ?", user.PendingEmail).Count(&count); res.Error != nil {
+		return res.Error
+	}
+
+	if count != 0 {
+		return apperrors.Conflict("email is already in use")
+	}
+
+	oldEmail := user.Email
+	newEmail := user.PendingEmail
+
+	// the link of a passwordless account went to the old address, the new one
+	// is still unverified
+	verifyNewEmail, _ := claims["verifyNewEmail"].(bool)
+
+	var verifiedAt *time.Time
+
+	if !verifyNewEmail {
+		now := time.Now().UTC()
+		verifiedAt = &now
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+
+		res := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"email":             newEmail,
+			"pending_email":     "",
+			"email_verified":    !verifyNewEmail,
+			"email_verified_at": verifiedAt,
+		})
+
+		if res.Error != nil {
+			return res.Error
+		}
+
+		return events.Record(tx, &events.EmailChanged{
+			UserID:   user.ID,
+			OldEmail: oldEmail,
+			NewEmail: newEmail,
+		})
+	})
+
+	if err != nil || !verifyNewEmail {
+		return err
+	}
+
+	sendVerification := &SendEmailVerificationCommand{UserID: user.ID}
+
+	if err := sendVerification.Handle(); err != nil {
+		log.Printf("email change verification: %v", err)
+	}
+
+	return nil
+}
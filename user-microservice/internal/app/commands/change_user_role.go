@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"music-sharing/shared/apperrors"
+	"music-sharing/shared/permissions"
+	"music-sharing/user-microservice/internal/app/events"
+	"music-sharing/user-microservice/internal/app/models"
+	config "music-sharing/user-microservice/pkg"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Changes the role of a user. Actor is nil when the change is made through
+// the internal api, e.g. to promote the first admin
+type ChangeUserRoleCommand struct {
+	UserID uuid.UUID
+	Role   string
+	Actor  *models.User
+}
+
+func (cmd *ChangeUserRoleCommand) Handle() error {
+
+	db := config.Container.Database
+
+	if !permissions.IsValidRole(cmd.Role) {
+		return apperrors.Validation("invalid role")
+	}
+
+	if cmd.Actor != nil && cmd.Actor.ID == cmd.UserID {
+		return apperrors.Forbidden("you cant change your own role")
+	}
+
+	user := &models.User{}
+
+	res := db.Find(user, "id = ?", cmd.UserID)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if user.ID == uuid.Nil {
+		return apperrors.NotFound("user doesnt exist")
+	}
+
+	if user.Role == cmd.Role {
+		return nil
+	}
+
+	event := &events.UserRoleChanged{
+		UserID:  user.ID,
+		OldRole: user.Role,
+		NewRole: cmd.Role,
+	}
+
+	if cmd.Actor != nil {
+		event.ActorID = &cmd.Actor.ID
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+
+		// the sessions carry the old role in their claims, bumping the token
+		// version revokes them
+		res := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"role":          cmd.Role,
+			"token_version": gorm.Expr("token_version + 1"),
+		})
+
+		if res.Error != nil {
+			return res.Error
+		}
+
+		diff := auditDiff(
+			map[string]interface{}{"role": event.OldRole},
+			map[string]interface{}{"role": event.NewRole},
+		)
+
+		if err := recordAudit(tx, cmd.Actor, AuditRoleChanged, user.ID, diff); err != nil {
+			return err
+		}
+
+		return events.Record(tx, event)
+	})
+}
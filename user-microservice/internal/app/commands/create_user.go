@@ -1,11 +1,13 @@
 package commands
 
 import (
+	"music-sharing/user-microservice/internal/app/events"
 	"music-sharing/user-microservice/internal/app/models"
 	config "music-sharing/user-microservice/pkg"
 
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type (
@@ -38,14 +40,23 @@ func (cmd *CreateUserCommand) Handle() error {
 		Followings:     0,
 		ProfileURL:     "",
 		IsPrivate:      cmd.IsPrivate,
+		Role:           models.RoleUser,
 	}
 
-	res := db.Create(user)
+	return db.Transaction(func(tx *gorm.DB) error {
 
-	if res.Error != nil {
-		return res.Error
-	}
+		res := tx.Create(user)
+
+		if res.Error != nil {
+			return res.Error
+		}
 
-	return nil
+		return events.Record(tx, &events.UserRegistered{
+			UserID:    user.ID,
+			FullName:  user.FullName,
+			Email:     user.Email,
+			IsPrivate: user.IsPrivate,
+		})
+	})
 
 }
@@ -0,0 +1,193 @@
+package commands
+
+import (
+	"fmt"
+	"music-sharing/shared/apperrors"
+	"music-sharing/user-microservice/internal/app/events"
+	"music-sharing/user-microservice/internal/app/models"
+	"music-sharing/user-microservice/internal/lib"
+	config "music-sharing/user-microservice/pkg"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type (
+	// An account without a password, created by a social login, has nothing
+	// to re-authenticate with. Its deletion is confirmed from a link sent to
+	// its email instead and ConfirmationSent is set
+	DeleteAccountCommand struct {
+		Password         string
+		CurrentUser      *models.User
+		ConfirmationSent bool
+	}
+
+	ConfirmAccountDeletionCommand struct {
+		Token string
+	}
+
+	CancelAccountDeletionCommand struct {
+		CurrentUser *models.User
+	}
+)
+
+const accountDeletionConfirmationTTL = time.Hour
+
+// Read from ACCOUNT_DELETION_GRACE_PERIOD (e.g. "72h"), defaults to 7 days
+func accountDeletionGracePeriod() time.Duration {
+	gracePeriod, err := time.ParseDuration(os.Getenv("ACCOUNT_DELETION_GRACE_PERIOD"))
+
+	if err != nil {
+		return 7 * 24 * time.Hour
+	}
+
+	return gracePeriod
+}
+
+func (cmd *DeleteAccountCommand) Handle() error {
+
+	if len(cmd.CurrentUser.HashedPassword) == 0 {
+		cmd.ConfirmationSent = true
+
+		return sendAccountDeletionConfirmation(cmd.CurrentUser)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(cmd.CurrentUser.HashedPassword), []byte(cmd.Password)); err != nil {
+		return apperrors.Forbidden("invalid password")
+	}
+
+	return scheduleAccountDeletion(cmd.CurrentUser)
+}
+
+func (cmd *ConfirmAccountDeletionCommand) Handle() error {
+
+	db := config.Container.Database
+
+	claims, err := lib.ParseSignedToken(lib.AccountDeletionPurpose, cmd.Token)
+
+	if err != nil {
+		return err
+	}
+
+	user := &models.User{}
+
+	res := db.Find(user, "id = ?", claims["userId"])
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	// the token dies with the sessions of the account, e.g. after a forced
+	// logout
+	tokenVersion, _ := claims["tokenVersion"].(float64)
+
+	if user.ID == uuid.Nil || uint(tokenVersion) != user.TokenVersion {
+		return apperrors.Validation("invalid or expired token")
+	}
+
+	return scheduleAccountDeletion(user)
+}
+
+func sendAccountDeletionConfirmation(user *models.User) error {
+
+	token, err := lib.CreateSignedToken(lib.AccountDeletionPurpose, map[string]interface{}{
+		"userId":       user.ID.String(),
+		"tokenVersion": user.TokenVersion,
+	}, accountDeletionConfirmationTTL)
+
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf(
+		"Hi %s,\n\nSomeone asked to delete your account. If it was you, confirm it by opening the link below within an hour:\n\n%s/v1/accountDeletions/confirmation?token=%s\n\nThe account is deleted after a grace period, you can cancel it until then. Otherwise you can ignore this email.\n",
+		user.FullName, appURL(), token,
+	)
+
+	return config.Container.Mailer.Send(user.Email, "Confirm the deletion of your account", body)
+}
+
+func scheduleAccountDeletion(user *models.User) error {
+
+	db := config.Container.Database
+
+	deletion := &models.AccountDeletion{}
+
+	res := db.Find(deletion, "user_id = ?", user.ID)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if deletion.ID != uuid.Nil && deletion.Status != models.AccountDeletionCancelled {
+		return apperrors.Conflict("account deletion already requested")
+	}
+
+	now := time.Now().UTC()
+	previousID := deletion.ID
+
+	*deletion = models.AccountDeletion{
+		ID:           previousID,
+		UserID:       user.ID,
+		Status:       models.AccountDeletionPending,
+		RequestedAt:  now,
+		ScheduledFor: now.Add(accountDeletionGracePeriod()),
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+
+		if previousID == uuid.Nil {
+			deletion.ID = uuid.New()
+
+			if res := tx.Create(deletion); res.Error != nil {
+				return res.Error
+			}
+		} else {
+			// a cancelled deletion is requested again, only while it is still cancelled
+			res := tx.Model(&models.AccountDeletion{}).
+				Where("id = ? AND status = ?", previousID, models.AccountDeletionCancelled).
+				Select("*").
+				Updates(deletion)
+
+			if res.Error != nil {
+				return res.Error
+			}
+
+			if res.RowsAffected == 0 {
+				return apperrors.Conflict("account deletion already requested")
+			}
+		}
+
+		return events.Record(tx, &events.AccountDeletionRequested{
+			UserID:       deletion.UserID,
+			ScheduledFor: deletion.ScheduledFor,
+		})
+	})
+}
+
+func (cmd *CancelAccountDeletionCommand) Handle() error {
+
+	db := config.Container.Database
+
+	return db.Transaction(func(tx *gorm.DB) error {
+
+		res := tx.Model(&models.AccountDeletion{}).
+			Where("user_id = ? AND status = ?", cmd.CurrentUser.ID, models.AccountDeletionPending).
+			Update("status", models.AccountDeletionCancelled)
+
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if res.RowsAffected == 0 {
+			return apperrors.Conflict("no pending account deletion to cancel")
+		}
+
+		return events.Record(tx, &events.AccountDeletionCancelled{
+			UserID: cmd.CurrentUser.ID,
+		})
+	})
+}
@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"fmt"
+	"music-sharing/shared/apperrors"
+	"music-sharing/user-microservice/internal/app/events"
+	"music-sharing/user-microservice/internal/app/models"
+	"music-sharing/user-microservice/internal/lib"
+	config "music-sharing/user-microservice/pkg"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type (
+	SendEmailVerificationCommand struct {
+		UserID uuid.UUID
+	}
+
+	VerifyEmailCommand struct {
+		Token string
+	}
+)
+
+const emailVerificationTTL = 24 * time.Hour
+
+// Base url of the links sent by email, read from APP_URL. The links open the
+// pages of the service, e.g. /v1/emailVerifications
+func appURL() string {
+	if url := os.Getenv("APP_URL"); len(url) != 0 {
+		return url
+	}
+
+	return "http://localhost:8080"
+}
+
+func (cmd *SendEmailVerificationCommand) Handle() error {
+
+	db := config.Container.Database
+	mailer := config.Container.Mailer
+
+	user := &models.User{}
+
+	res := db.Find(user, "id = ?", cmd.UserID)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if user.ID == uuid.Nil {
+		return apperrors.NotFound("user doesnt exist")
+	}
+
+	if user.EmailVerified {
+		return nil
+	}
+
+	token, err := lib.CreateSignedToken(lib.EmailVerificationPurpose, map[string]interface{}{
+		"userId": user.ID.String(),
+		"email":  user.Email,
+	}, emailVerificationTTL)
+
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf(
+		"Hi %s,\n\nPlease confirm your email address by opening the link below, it is valid for 24 hours:\n\n%s/v1/emailVerifications?token=%s\n",
+		user.FullName, appURL(), token,
+	)
+
+	return mailer.Send(user.Email, "Verify your email address", body)
+}
+
+func (cmd *VerifyEmailCommand) Handle() error {
+
+	db := config.Container.Database
+
+	claims, err := lib.ParseSignedToken(lib.EmailVerificationPurpose, cmd.Token)
+
+	if err != nil {
+		return err
+	}
+
+	user := &models.User{}
+
+	res := db.Find(user, "id = ?", claims["userId"])
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	// the token is bound to the address it was sent to
+	if user.ID == uuid.Nil || user.Email != claims["email"] {
+		return apperrors.Validation("invalid or expired token")
+	}
+
+	if user.EmailVerified {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+
+		res := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"email_verified":    true,
+			"email_verified_at": time.Now().UTC(),
+		})
+
+		if res.Error != nil {
+			return res.Error
+		}
+
+		return events.Record(tx, &events.EmailVerified{
+			UserID: user.ID,
+			Email:  user.Email,
+		})
+	})
+}
@@ -0,0 +1,221 @@
+package commands
+
+import (
+	"music-sharing/user-microservice/internal/app/models"
+	config "music-sharing/user-microservice/pkg"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	// Records the activity of a domain event and fans it out to the feeds of
+	// the actor's followers. Events can be delivered more than once so it is
+	// idempotent on EventID
+	RecordActivityCommand struct {
+		EventID  string
+		ActorID  uuid.UUID
+		Verb     string
+		ObjectID string
+		Title    string
+		Caption  string
+	}
+
+	// Removes the activities about an object that no longer exists, or only
+	// those of ActorID with Verb when they are set
+	RemoveActivitiesCommand struct {
+		ObjectID string
+		ActorID  uuid.UUID
+		Verb     string
+	}
+
+	// Removes the activities of ActorID from the feed of OwnerID after an
+	// unfollow
+	PruneFeedCommand struct {
+		OwnerID uuid.UUID
+		ActorID uuid.UUID
+	}
+
+	// Moves the seen watermark of the feed forward, ActivityID 0 marks the
+	// whole feed as seen
+	MarkFeedSeenCommand struct {
+		ActivityID  uint
+		CurrentUser *models.User
+	}
+)
+
+const fanOutBatchSize = 500
+
+// Users with at least FEED_FANOUT_THRESHOLD followers, 10000 by default, have
+// their activities pulled by their followers instead of pushed to them
+func fanOutThreshold() uint {
+	if threshold, err := strconv.ParseUint(os.Getenv("FEED_FANOUT_THRESHOLD"), 10, 32); err == nil && threshold > 0 {
+		return uint(threshold)
+	}
+
+	return 10000
+}
+
+func (cmd *RecordActivityCommand) Handle() error {
+
+	db := config.Container.Database
+
+	actor := &models.User{}
+
+	if res := db.Find(actor, "id = ?", cmd.ActorID); res.Error != nil {
+		return res.Error
+	}
+
+	// the actor was deleted before the event was delivered
+	if actor.ID == uuid.Nil {
+		return nil
+	}
+
+	activity := &models.Activity{
+		EventID:   cmd.EventID,
+		ActorID:   actor.ID,
+		Verb:      cmd.Verb,
+		ObjectID:  cmd.ObjectID,
+		Title:     cmd.Title,
+		Caption:   cmd.Caption,
+		FannedOut: actor.Followers < fanOutThreshold(),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(activity)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	// redelivered event, the fan out is resumed from the stored activity
+	if res.RowsAffected == 0 {
+		activity = &models.Activity{}
+
+		if res := db.Find(activity, "event_id = ?", cmd.EventID); res.Error != nil {
+			return res.Error
+		}
+	}
+
+	if !activity.FannedOut {
+		return nil
+	}
+
+	return fanOut(db, activity)
+}
+
+func fanOut(db *gorm.DB, activity *models.Activity) error {
+
+	lastFollowerId := uuid.Nil
+
+	for {
+		followerIds := []uuid.UUID{}
+
+		res := db.Model(&models.Follow{}).
+			Where("followee_id = ? AND follower_id > ?", activity.ActorID, lastFollowerId).
+			Order("follower_id").
+			Limit(fanOutBatchSize).
+			Pluck("follower_id", &followerIds)
+
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if len(followerIds) == 0 {
+			return nil
+		}
+
+		items := make([]models.FeedItem, 0, len(followerIds))
+
+		for _, followerId := range followerIds {
+			items = append(items, models.FeedItem{OwnerID: followerId, ActivityID: activity.ID})
+		}
+
+		if res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&items); res.Error != nil {
+			return res.Error
+		}
+
+		lastFollowerId = followerIds[len(followerIds)-1]
+	}
+}
+
+func (cmd *RemoveActivitiesCommand) Handle() error {
+
+	db := config.Container.Database
+
+	return db.Transaction(func(tx *gorm.DB) error {
+
+		query := tx.Model(&models.Activity{}).Where("object_id = ?", cmd.ObjectID)
+
+		if cmd.ActorID != uuid.Nil {
+			query = query.Where("actor_id = ?", cmd.ActorID)
+		}
+
+		if len(cmd.Verb) != 0 {
+			query = query.Where("verb = ?", cmd.Verb)
+		}
+
+		return removeActivities(tx, query)
+	})
+}
+
+// Deletes the activities matched by query together with their feed items
+func removeActivities(tx *gorm.DB, query *gorm.DB) error {
+
+	activityIds := []uint{}
+
+	if res := query.Pluck("id", &activityIds); res.Error != nil {
+		return res.Error
+	}
+
+	if len(activityIds) == 0 {
+		return nil
+	}
+
+	if res := tx.Where("activity_id IN ?", activityIds).Delete(&models.FeedItem{}); res.Error != nil {
+		return res.Error
+	}
+
+	return tx.Where("id IN ?", activityIds).Delete(&models.Activity{}).Error
+}
+
+func (cmd *PruneFeedCommand) Handle() error {
+
+	db := config.Container.Database
+
+	actorActivities := db.Model(&models.Activity{}).Select("id").Where("actor_id = ?", cmd.ActorID)
+
+	return db.Where("owner_id = ? AND activity_id IN (?)", cmd.OwnerID, actorActivities).Delete(&models.FeedItem{}).Error
+}
+
+func (cmd *MarkFeedSeenCommand) Handle() error {
+
+	db := config.Container.Database
+
+	activityId := cmd.ActivityID
+
+	if activityId == 0 {
+		if res := db.Model(&models.Activity{}).Select("COALESCE(MAX(id), 0)").Scan(&activityId); res.Error != nil {
+			return res.Error
+		}
+	}
+
+	state := &models.FeedState{
+		UserID:         cmd.CurrentUser.ID,
+		SeenActivityID: activityId,
+		SeenAt:         time.Now().UTC(),
+	}
+
+	// the watermark never moves backwards
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"seen_activity_id": gorm.Expr("GREATEST(seen_activity_id, ?)", activityId),
+			"seen_at":          state.SeenAt,
+		}),
+	}).Create(state).Error
+}
@@ -1,18 +1,32 @@
 package commands
 
 import (
-	"errors"
+	"music-sharing/shared/apperrors"
+	"music-sharing/user-microservice/internal/app/events"
 	"music-sharing/user-microservice/internal/app/models"
 	config "music-sharing/user-microservice/pkg"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-type FollowOrUnfollowUserCommand struct {
-	Follow      bool
-	UserId      uuid.UUID
-	CurrentUser *models.User
-}
+type (
+	// A follow of a private account is a request its owner approves, Requested
+	// tells the follow is pending. An unfollow withdraws a pending request
+	FollowOrUnfollowUserCommand struct {
+		Follow      bool
+		UserId      uuid.UUID
+		CurrentUser *models.User
+		Requested   bool
+	}
+
+	// Approves or denies the follow request of RequesterID to the user
+	RespondToFollowRequestCommand struct {
+		RequesterID uuid.UUID
+		Approve     bool
+		CurrentUser *models.User
+	}
+)
 
 func (cmd *FollowOrUnfollowUserCommand) Handle() error {
 
@@ -20,6 +34,10 @@ func (cmd *FollowOrUnfollowUserCommand) Handle() error {
 
 	user := &models.User{}
 
+	if cmd.UserId == cmd.CurrentUser.ID {
+		return apperrors.Validation("you cant follow yourself")
+	}
+
 	res := db.Find(user, "ID = ?", cmd.UserId)
 
 	if res.Error != nil {
@@ -27,19 +45,156 @@ func (cmd *FollowOrUnfollowUserCommand) Handle() error {
 	}
 
 	if user.ID == uuid.Nil {
-		return errors.New("user doesnt exist")
+		return apperrors.NotFound("user doesnt exist")
+	}
+
+	edge := &models.Follow{FollowerID: cmd.CurrentUser.ID, FolloweeID: user.ID}
+	request := &models.FollowRequest{RequesterID: cmd.CurrentUser.ID, TargetID: user.ID}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+
+		if !cmd.Follow {
+			return removeFollow(tx, edge, request)
+		}
+
+		blocked, err := isBlockedBetween(tx, cmd.CurrentUser.ID, user.ID)
+
+		if err != nil {
+			return err
+		}
+
+		if blocked {
+			return apperrors.Forbidden("you cant follow this user")
+		}
+
+		var count int64
+
+		if res := tx.Model(&models.Follow{}).Where(edge).Count(&count); res.Error != nil {
+			return res.Error
+		}
+
+		if count != 0 {
+			return apperrors.Conflict("you already follow this user")
+		}
+
+		if !user.IsPrivate {
+			return addFollowEdge(tx, edge)
+		}
+
+		if res := tx.Model(&models.FollowRequest{}).Where(request).Count(&count); res.Error != nil {
+			return res.Error
+		}
+
+		if count != 0 {
+			return apperrors.Conflict("you already asked to follow this user")
+		}
+
+		if res := tx.Create(request); res.Error != nil {
+			return res.Error
+		}
+
+		cmd.Requested = true
+
+		return events.Record(tx, &events.FollowRequested{RequesterID: cmd.CurrentUser.ID, TargetID: user.ID})
+	})
+}
+
+func (cmd *RespondToFollowRequestCommand) Handle() error {
+
+	db := config.Container.Database
+
+	request := &models.FollowRequest{RequesterID: cmd.RequesterID, TargetID: cmd.CurrentUser.ID}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+
+		res := tx.Where(request).Delete(&models.FollowRequest{})
+
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if res.RowsAffected == 0 {
+			return apperrors.NotFound("follow request doesnt exist")
+		}
+
+		if !cmd.Approve {
+			return nil
+		}
+
+		return addFollowEdge(tx, &models.Follow{FollowerID: cmd.RequesterID, FolloweeID: cmd.CurrentUser.ID})
+	})
+}
+
+// Creates the follow edge and bumps the counters of both users. Only the
+// counters are written, saving the whole rows would undo an edit of either
+// profile made since they were read
+func addFollowEdge(tx *gorm.DB, edge *models.Follow) error {
+
+	if res := tx.Create(edge); res.Error != nil {
+		return res.Error
+	}
+
+	res := tx.Model(&models.User{}).Where("id = ?", edge.FolloweeID).Update("followers", gorm.Expr("followers + 1"))
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	res = tx.Model(&models.User{}).Where("id = ?", edge.FollowerID).Update("followings", gorm.Expr("followings + 1"))
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	return events.Record(tx, &events.UserFollowed{FollowerID: edge.FollowerID, FolloweeID: edge.FolloweeID})
+}
+
+// Deletes the follow edge, or the pending request when there is no edge yet
+func removeFollow(tx *gorm.DB, edge *models.Follow, request *models.FollowRequest) error {
+
+	removed, err := removeFollowEdge(tx, edge)
+
+	if err != nil || removed {
+		return err
 	}
 
-	if cmd.Follow {
-		user.Followers++
-		cmd.CurrentUser.Followings++
-	} else {
-		user.Followers--
-		cmd.CurrentUser.Followings--
+	res := tx.Where(request).Delete(&models.FollowRequest{})
+
+	if res.Error != nil {
+		return res.Error
 	}
 
-	db.Save(user)
-	db.Save(cmd.CurrentUser)
+	if res.RowsAffected == 0 {
+		return apperrors.Conflict("you dont follow this user")
+	}
 
 	return nil
 }
+
+// Deletes the follow edge if there is one and decrements the counters of both
+// users in place. The counters never go below zero, the follows older than
+// the follows table are counted without an edge
+func removeFollowEdge(tx *gorm.DB, edge *models.Follow) (bool, error) {
+
+	res := tx.Where(&models.Follow{FollowerID: edge.FollowerID, FolloweeID: edge.FolloweeID}).Delete(&models.Follow{})
+
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false, res.Error
+	}
+
+	res = tx.Model(&models.User{}).Where("id = ? AND followers > 0", edge.FolloweeID).
+		UpdateColumn("followers", gorm.Expr("followers - 1"))
+
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	res = tx.Model(&models.User{}).Where("id = ? AND followings > 0", edge.FollowerID).
+		UpdateColumn("followings", gorm.Expr("followings - 1"))
+
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	return true, events.Record(tx, &events.UserUnfollowed{FollowerID: edge.FollowerID, FolloweeID: edge.FolloweeID})
+}
@@ -0,0 +1,214 @@
+package commands
+
+import (
+	"fmt"
+	"math"
+	"music-sharing/shared/apperrors"
+	"music-sharing/user-microservice/internal/app/models"
+	"music-sharing/user-microservice/internal/lib"
+	config "music-sharing/user-microservice/pkg"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type LoginCommand struct {
+	Email    string
+	Password string
+	IP       string
+	// Set by Handle to the session token, or to a short lived MFA token
+	// when the account has two factor authentication enabled
+	Token       string
+	MFARequired bool
+	MFAToken    string
+}
+
+// The same error is returned for an unknown email and a wrong password so the
+// login can't be used to find out which emails have an account
+var ErrInvalidCredentials = apperrors.Unauthorized("invalid email or password")
+
+const (
+	accountFailuresBeforeLockout = 5
+	accountBaseLockout           = time.Minute
+	accountMaxLockout            = time.Hour
+	accountFailuresWindow        = 24 * time.Hour
+
+	ipFailuresBeforeBackoff = 20
+	ipBaseBackoff           = time.Second
+	ipMaxBackoff            = 15 * time.Minute
+	ipFailuresWindow        = 15 * time.Minute
+)
+
+// compared against when the email is unknown, so both cases take as long
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+func (cmd *LoginCommand) Handle() error {
+
+	db := config.Container.Database
+	now := time.Now().UTC()
+
+	if err := checkLoginThrottle(db, cmd.Email, cmd.IP, now); err != nil {
+		recordLoginAttempt(db, cmd.Email, nil, cmd.IP, true, models.LoginAttemptLockedOut)
+
+		return err
+	}
+
+	user := &models.User{}
+
+	res := db.Find(user, "email = ?", cmd.Email)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if user.ID == uuid.Nil {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(cmd.Password))
+		recordLoginAttempt(db, cmd.Email, nil, cmd.IP, true, models.LoginAttemptUnknownEmail)
+
+		return ErrInvalidCredentials
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(cmd.Password)); err != nil {
+		recordLoginAttempt(db, cmd.Email, &user.ID, cmd.IP, true, models.LoginAttemptWrongPassword)
+
+		return ErrInvalidCredentials
+	}
+
+	if err := ensureCanLogin(user); err != nil {
+		return err
+	}
+
+	// the failures are only reset once the second factor is verified too
+	if user.TOTPEnabled {
+		mfaToken, err := createMFAToken(user)
+
+		if err != nil {
+			return err
+		}
+
+		cmd.MFARequired = true
+		cmd.MFAToken = mfaToken
+
+		return nil
+	}
+
+	tokenString, err := lib.CreateUserToken(user)
+
+	if err != nil {
+		return err
+	}
+
+	if res := recordLoginAttempt(db, cmd.Email, &user.ID, cmd.IP, false, models.LoginAttemptSucceeded); res.Error != nil {
+		return res.Error
+	}
+
+	cmd.Token = tokenString
+
+	return nil
+}
+
+// Checks the account policies that apply to every way of logging in
+func ensureCanLogin(user *models.User) error {
+
+	if user.SuspendedAt != nil {
+		return apperrors.Forbidden("this account is suspended")
+	}
+
+	if os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true" && !user.EmailVerified {
+		return apperrors.Forbidden("email is not verified")
+	}
+
+	return nil
+}
+
+func recordLoginAttempt(db *gorm.DB, email string, userId *uuid.UUID, ip string, failed bool, reason string) *gorm.DB {
+	return db.Create(&models.LoginAttempt{
+		ID:        uuid.New(),
+		Email:     email,
+		UserID:    userId,
+		IP:        ip,
+		Failed:    failed,
+		Reason:    reason,
+		CreatedAt: time.Now().UTC(),
+	})
+}
+
+// Every failure past the threshold doubles the wait, up to the max duration
+func backoff(failures int64, threshold int64, base time.Duration, max time.Duration) time.Duration {
+	if failures < threshold {
+		return 0
+	}
+
+	wait := time.Duration(float64(base) * math.Pow(2, float64(failures-threshold)))
+
+	if wait <= 0 || wait > max {
+		return max
+	}
+
+	return wait
+}
+
+func checkLoginThrottle(db *gorm.DB, email string, ip string, now time.Time) error {
+
+	// the failures of an account are counted since its last successful login
+	// or admin unlock, lockouts themselves are not counted as failures
+	since := now.Add(-accountFailuresWindow)
+	lastReset := &models.LoginAttempt{}
+
+	res := db.Where("email = ? AND failed = ? AND created_at > ?", email, false, since).
+		Order("created_at DESC").
+		Limit(1).
+		Find(lastReset)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if lastReset.ID != uuid.Nil {
+		since = lastReset.CreatedAt
+	}
+
+	failures, lastFailure, err := countLoginFailures(db.Where("email = ?", email), since)
+
+	if err != nil {
+		return err
+	}
+
+	if lockedUntil := lastFailure.Add(backoff(failures, accountFailuresBeforeLockout, accountBaseLockout, accountMaxLockout)); now.Before(lockedUntil) {
+		return apperrors.TooManyRequests(fmt.Sprintf("too many failed login attempts, try again in %s", lockedUntil.Sub(now).Round(time.Second)))
+	}
+
+	failures, lastFailure, err = countLoginFailures(db.Where("ip = ?", ip), now.Add(-ipFailuresWindow))
+
+	if err != nil {
+		return err
+	}
+
+	if lockedUntil := lastFailure.Add(backoff(failures, ipFailuresBeforeBackoff, ipBaseBackoff, ipMaxBackoff)); now.Before(lockedUntil) {
+		return apperrors.TooManyRequests(fmt.Sprintf("too many failed login attempts, try again in %s", lockedUntil.Sub(now).Round(time.Second)))
+	}
+
+	return nil
+}
+
+func countLoginFailures(scope *gorm.DB, since time.Time) (int64, time.Time, error) {
+
+	result := struct {
+		Failures    int64
+		LastFailure *time.Time
+	}{}
+
+	res := scope.Model(&models.LoginAttempt{}).
+		Select("COUNT(*) AS failures, MAX(created_at) AS last_failure").
+		Where("failed = ? AND reason <> ? AND created_at > ?", true, models.LoginAttemptLockedOut, since).
+		Scan(&result)
+
+	if res.Error != nil || result.LastFailure == nil {
+		return 0, time.Time{}, res.Error
+	}
+
+	return result.Failures, *result.LastFailure, nil
+}
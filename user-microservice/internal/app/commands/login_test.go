@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAccountLockoutBackoff(t *testing.T) {
+	cases := []struct {
+		failures int64
+		wait     time.Duration
+	}{
+		{0, 0},
+		{accountFailuresBeforeLockout - 1, 0},
+		{accountFailuresBeforeLockout, time.Minute},
+		{accountFailuresBeforeLockout + 1, 2 * time.Minute},
+		{accountFailuresBeforeLockout + 2, 4 * time.Minute},
+		{accountFailuresBeforeLockout + 5, 32 * time.Minute},
+		{accountFailuresBeforeLockout + 6, time.Hour},
+		{accountFailuresBeforeLockout + 100, time.Hour},
+	}
+
+	for _, tc := range cases {
+		wait := backoff(tc.failures, accountFailuresBeforeLockout, accountBaseLockout, accountMaxLockout)
+
+		if wait != tc.wait {
+			t.Fatalf("backoff after %d failures = %s, want %s", tc.failures, wait, tc.wait)
+		}
+	}
+}
+
+func TestAddressBackoff(t *testing.T) {
+	cases := []struct {
+		failures int64
+		wait     time.Duration
+	}{
+		{ipFailuresBeforeBackoff - 1, 0},
+		{ipFailuresBeforeBackoff, time.Second},
+		{ipFailuresBeforeBackoff + 3, 8 * time.Second},
+		{ipFailuresBeforeBackoff + 9, 512 * time.Second},
+		{ipFailuresBeforeBackoff + 10, 15 * time.Minute},
+	}
+
+	for _, tc := range cases {
+		wait := backoff(tc.failures, ipFailuresBeforeBackoff, ipBaseBackoff, ipMaxBackoff)
+
+		if wait != tc.wait {
+			t.Fatalf("backoff after %d failures = %s, want %s", tc.failures, wait, tc.wait)
+		}
+	}
+}
+
+// The doubling overflows a duration long before the failures stop growing,
+// the wait must stay at the max instead of wrapping around
+func TestBackoffNeverOverflows(t *testing.T) {
+	for _, failures := range []int64{64, 1000, 1 << 40} {
+		if wait := backoff(failures, 5, time.Minute, time.Hour); wait != time.Hour {
+			t.Fatalf("backoff after %d failures = %s, want 1h", failures, wait)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package commands
+
+import (
+	"encoding/json"
+	"music-sharing/shared/apperrors"
+	"music-sharing/user-microservice/internal/app/models"
+	config "music-sharing/user-microservice/pkg"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	// Creates the notification unless the user turned its type off, and
+	// pushes it to the live connections of the user. Message is prefixed
+	// with the name of the actor when there is one
+	CreateNotificationCommand struct {
+		EventID  string
+		UserID   uuid.UUID
+		Type     string
+		ActorID  uuid.UUID
+		ObjectID string
+		Message  string
+	}
+
+	// Marks the given notifications as read, or all of them when All is set
+	MarkNotificationsReadCommand struct {
+		IDs         []uint
+		All         bool
+		CurrentUser *models.User
+	}
+
+	UpdateNotificationPreferencesCommand struct {
+		Preferences map[string]bool
+		CurrentUser *models.User
+	}
+)
+
+func isNotificationType(notificationType string) bool {
+	for _, t := range models.NotificationTypes {
+		if t == notificationType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (cmd *CreateNotificationCommand) Handle() error {
+
+	db := config.Container.Database
+	hub := config.Container.Hub
+
+	// the tracks of deleted accounts are anonymised to the nil id, there is
+	// nobody left to notify
+	if cmd.UserID == uuid.Nil || cmd.ActorID == cmd.UserID {
+		return nil
+	}
+
+	preference := &models.NotificationPreference{}
+
+	if res := db.Find(preference, "user_id = ? AND type = ?", cmd.UserID, cmd.Type); res.Error != nil {
+		return res.Error
+	}
+
+	if preference.UserID != uuid.Nil && !preference.Enabled {
+		return nil
+	}
+
+	notification := &models.Notification{
+		UserID:    cmd.UserID,
+		EventID:   cmd.EventID,
+		Type:      cmd.Type,
+		ObjectID:  cmd.ObjectID,
+		Message:   cmd.Message,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if cmd.ActorID != uuid.Nil {
+		blocked, err := isBlockedBetween(db, cmd.UserID, cmd.ActorID)
+
+		if err != nil {
+			return err
+		}
+
+		if blocked {
+			return nil
+		}
+
+		actor := &models.User{}
+
+		if res := db.Find(actor, "id = ?", cmd.ActorID); res.Error != nil {
+			return res.Error
+		}
+
+		if actor.ID == uuid.Nil {
+			return nil
+		}
+
+		notification.ActorID = &actor.ID
+		notification.Message = actor.FullName + " " + cmd.Message
+	}
+
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(notification)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	// already delivered
+	if res.RowsAffected == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(notification)
+
+	if err != nil {
+		return err
+	}
+
+	hub.Publish(cmd.UserID, payload)
+
+	return nil
+}
+
+func (cmd *MarkNotificationsReadCommand) Handle() error {
+
+	db := config.Container.Database
+
+	if !cmd.All && len(cmd.IDs) == 0 {
+		return apperrors.Validation("no notifications to mark as read")
+	}
+
+	query := db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", cmd.CurrentUser.ID)
+
+	if !cmd.All {
+		query = query.Where("id IN ?", cmd.IDs)
+	}
+
+	return query.Update("read_at", time.Now().UTC()).Error
+}
+
+func (cmd *UpdateNotificationPreferencesCommand) Handle() error {
+
+	db := config.Container.Database
+
+	for notificationType := range cmd.Preferences {
+		if !isNotificationType(notificationType) {
+			return apperrors.Validation("unknown notification type " + notificationType)
+		}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+
+		for notificationType, enabled := range cmd.Preferences {
+			preference := &models.NotificationPreference{
+				UserID:  cmd.CurrentUser.ID,
+				Type:    notificationType,
+				Enabled: enabled,
+			}
+
+			if res := tx.Save(preference); res.Error != nil {
+				return res.Error
+			}
+		}
+
+		return nil
+	})
+}
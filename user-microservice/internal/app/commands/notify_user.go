@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"fmt"
+	"music-sharing/user-microservice/internal/app/models"
+	config "music-sharing/user-microservice/pkg"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// Sends a notice to a user on behalf of another microservice. A notice sent
+// with the EventID of one already sent is skipped, the outbox of the sender
+// may deliver its events more than once
+type NotifyUserCommand struct {
+	UserID  uuid.UUID
+	EventID string
+	Subject string
+	Message string
+}
+
+func (cmd *NotifyUserCommand) Handle() error {
+
+	db := config.Container.Database
+	mailer := config.Container.Mailer
+
+	user, err := findTargetUser(db, cmd.UserID)
+
+	if err != nil {
+		return err
+	}
+
+	sent := &models.SentNotice{UserID: user.ID, EventID: cmd.EventID, CreatedAt: time.Now().UTC()}
+
+	if len(cmd.EventID) != 0 {
+		res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(sent)
+
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if res.RowsAffected == 0 {
+			return nil
+		}
+	}
+
+	body := fmt.Sprintf("Hi %s,\n\n%s\n", user.FullName, cmd.Message)
+
+	err = mailer.Send(user.Email, cmd.Subject, body)
+
+	// the notice is released so the retry of the event sends it
+	if err != nil && len(cmd.EventID) != 0 {
+		db.Delete(&models.SentNotice{}, "user_id = ? AND event_id = ?", user.ID, cmd.EventID)
+	}
+
+	return err
+}
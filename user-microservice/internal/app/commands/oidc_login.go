@@ -0,0 +1,368 @@
+package commands
+
+import (
+	"crypto/subtle"
+	"music-sharing/shared/apperrors"
+	"music-sharing/user-microservice/internal/app/events"
+	"music-sharing/user-microservice/internal/app/models"
+	"music-sharing/user-microservice/internal/lib"
+	config "music-sharing/user-microservice/pkg"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type (
+	// Starts an authorization code flow with PKCE. When LinkUser is set the
+	// provider identity is linked to that user instead of logging in
+	StartOIDCLoginCommand struct {
+		Provider string
+		LinkUser *models.User
+		// Set by Handle to the url the user has to be redirected to and to the
+		// binding of its state the browser has to keep in a cookie
+		AuthURL        string
+		StateBinding   string
+		StateExpiresAt time.Time
+		SecureCookie   bool
+	}
+
+	// StateBinding is the cookie set by the start of the flow, a state sent by
+	// another browser is rejected
+	CompleteOIDCLoginCommand struct {
+		Provider     string
+		State        string
+		StateBinding string
+		Code         string
+		IP           string
+		// Set by Handle, Linked is set instead of a token for link flows
+		Token       string
+		MFARequired bool
+		MFAToken    string
+		Linked      bool
+	}
+
+	UnlinkIdentityCommand struct {
+		Provider    string
+		CurrentUser *models.User
+	}
+)
+
+const oauthStateTTL = 10 * time.Minute
+
+func (cmd *StartOIDCLoginCommand) Handle() error {
+
+	db := config.Container.Database
+
+	provider, err := lib.GetOIDCProvider(cmd.Provider)
+
+	if err != nil {
+		return err
+	}
+
+	state, err := lib.RandomURLSafeString(32)
+
+	if err != nil {
+		return err
+	}
+
+	nonce, err := lib.RandomURLSafeString(32)
+
+	if err != nil {
+		return err
+	}
+
+	codeVerifier, err := lib.RandomURLSafeString(64)
+
+	if err != nil {
+		return err
+	}
+
+	oauthState := &models.OAuthState{
+		State:        state,
+		Provider:     provider.Name,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		ExpiresAt:    time.Now().UTC().Add(oauthStateTTL),
+	}
+
+	if cmd.LinkUser != nil {
+		oauthState.LinkUserID = &cmd.LinkUser.ID
+	}
+
+	authURL, err := provider.AuthCodeURL(state, nonce, codeVerifier)
+
+	if err != nil {
+		return err
+	}
+
+	if res := db.Create(oauthState); res.Error != nil {
+		return res.Error
+	}
+
+	db.Where("expires_at < ?", time.Now().UTC()).Delete(&models.OAuthState{})
+
+	cmd.AuthURL = authURL
+	cmd.StateBinding = lib.StateBinding(state)
+	cmd.StateExpiresAt = oauthState.ExpiresAt
+	cmd.SecureCookie = strings.HasPrefix(provider.RedirectURL, "https://")
+
+	return nil
+}
+
+func (cmd *CompleteOIDCLoginCommand) Handle() error {
+
+	db := config.Container.Database
+
+	provider, err := lib.GetOIDCProvider(cmd.Provider)
+
+	if err != nil {
+		return err
+	}
+
+	oauthState := &models.OAuthState{}
+
+	res := db.Find(oauthState, "state = ?", cmd.State)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	// the state is single use, whatever the outcome of the callback
+	deleted := db.Delete(&models.OAuthState{}, "state = ?", cmd.State)
+
+	if len(oauthState.State) == 0 || deleted.RowsAffected == 0 || oauthState.Provider != provider.Name || oauthState.ExpiresAt.Before(time.Now().UTC()) {
+		return apperrors.Validation("invalid or expired login state")
+	}
+
+	// a link or login started by someone else and handed to this browser
+	if subtle.ConstantTimeCompare([]byte(lib.StateBinding(cmd.State)), []byte(cmd.StateBinding)) != 1 {
+		return apperrors.Forbidden("the login was started from another browser")
+	}
+
+	claims, err := provider.Exchange(cmd.Code, oauthState.CodeVerifier, oauthState.Nonce)
+
+	if err != nil {
+		return err
+	}
+
+	if oauthState.LinkUserID != nil {
+		if err := linkIdentity(db, *oauthState.LinkUserID, provider.Name, claims); err != nil {
+			return err
+		}
+
+		cmd.Linked = true
+
+		return nil
+	}
+
+	user, err := findOrCreateOIDCUser(db, provider.Name, claims)
+
+	if err != nil {
+		return err
+	}
+
+	if err := ensureCanLogin(user); err != nil {
+		return err
+	}
+
+	if user.TOTPEnabled {
+		cmd.MFARequired = true
+		cmd.MFAToken, err = createMFAToken(user)
+
+		return err
+	}
+
+	cmd.Token, err = lib.CreateUserToken(user)
+
+	if err != nil {
+		return err
+	}
+
+	return recordLoginAttempt(db, user.Email, &user.ID, cmd.IP, false, models.LoginAttemptSucceeded).Error
+}
+
+func linkIdentity(db *gorm.DB, userId uuid.UUID, provider string, claims *lib.OIDCClaims) error {
+
+	existing := &models.UserIdentity{}
+
+	res := db.Find(existing, "provider = ? AND subject = ?", provider, claims.Subject)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if existing.ID != uuid.Nil {
+		if existing.UserID == userId {
+			return nil
+		}
+
+		return apperrors.Conflict("this identity is already linked to another account")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+
+		res := tx.Create(&models.UserIdentity{
+			ID:       uuid.New(),
+			UserID:   userId,
+			Provider: provider,
+			Subject:  claims.Subject,
+			Email:    claims.Email,
+		})
+
+		if res.Error != nil {
+			return res.Error
+		}
+
+		return events.Record(tx, &events.IdentityLinked{
+			UserID:   userId,
+			Provider: provider,
+		})
+	})
+}
+
+// Finds the user of a provider identity. Unknown identities are linked to the
+// account with the same email, but only when the provider verified that email
+// and the account verified it too, otherwise anyone could take over an
+// account by claiming its address or register an address to hijack the later
+// social login of its owner. The other accounts link the provider themselves
+// once logged in
+func findOrCreateOIDCUser(db *gorm.DB, provider string, claims *lib.OIDCClaims) (*models.User, error) {
+
+	identity := &models.UserIdentity{}
+	user := &models.User{}
+
+	res := db.Find(identity, "provider = ? AND subject = ?", provider, claims.Subject)
+
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	if identity.ID != uuid.Nil {
+		res := db.Find(user, "id = ?", identity.UserID)
+
+		if res.Error != nil {
+			return nil, res.Error
+		}
+
+		if user.ID == uuid.Nil {
+			return nil, apperrors.NotFound("user doesnt exist")
+		}
+
+		return user, nil
+	}
+
+	if len(claims.Email) == 0 || !claims.EmailVerified {
+		return nil, apperrors.Forbidden("the identity provider didnt verify the email of this account")
+	}
+
+	res = db.Find(user, "email = ?", claims.Email)
+
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	if user.ID != uuid.Nil && !user.EmailVerified {
+		return nil, apperrors.Conflict("an account with this email exists, log in with its password and link the identity provider from the account")
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+
+		if user.ID == uuid.Nil {
+			now := time.Now().UTC()
+			fullName := claims.Name
+
+			if len(fullName) == 0 {
+				fullName = claims.Email
+			}
+
+			*user = models.User{
+				ID:              uuid.New(),
+				FullName:        fullName,
+				Email:           claims.Email,
+				Role:            models.RoleUser,
+				EmailVerified:   true,
+				EmailVerifiedAt: &now,
+			}
+
+			if res := tx.Create(user); res.Error != nil {
+				return res.Error
+			}
+
+			err := events.Record(tx, &events.UserRegistered{
+				UserID:   user.ID,
+				FullName: user.FullName,
+				Email:    user.Email,
+			})
+
+			if err != nil {
+				return err
+			}
+		}
+
+		res := tx.Create(&models.UserIdentity{
+			ID:       uuid.New(),
+			UserID:   user.ID,
+			Provider: provider,
+			Subject:  claims.Subject,
+			Email:    claims.Email,
+		})
+
+		if res.Error != nil {
+			return res.Error
+		}
+
+		return events.Record(tx, &events.IdentityLinked{
+			UserID:   user.ID,
+			Provider: provider,
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (cmd *UnlinkIdentityCommand) Handle() error {
+
+	db := config.Container.Database
+	user := cmd.CurrentUser
+
+	identities := []models.UserIdentity{}
+
+	if res := db.Find(&identities, "user_id = ?", user.ID); res.Error != nil {
+		return res.Error
+	}
+
+	var identity *models.UserIdentity
+
+	for i := range identities {
+		if identities[i].Provider == cmd.Provider {
+			identity = &identities[i]
+		}
+	}
+
+	if identity == nil {
+		return apperrors.Conflict("this provider is not linked to your account")
+	}
+
+	// the user must keep at least one way to login
+	if len(user.HashedPassword) == 0 && len(identities) == 1 {
+		return apperrors.Conflict("set a password before unlinking your last identity provider")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+
+		if res := tx.Delete(identity); res.Error != nil {
+			return res.Error
+		}
+
+		return events.Record(tx, &events.IdentityUnlinked{
+			UserID:   user.ID,
+			Provider: identity.Provider,
+		})
+	})
+}
@@ -0,0 +1,110 @@
+package commands
+
+import (
+	"fmt"
+	"music-sharing/shared/apperrors"
+	"music-sharing/user-microservice/internal/app/events"
+	"music-sharing/user-microservice/internal/app/models"
+	"music-sharing/user-microservice/internal/lib"
+	config "music-sharing/user-microservice/pkg"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type (
+	// Sends a reset link if the email belongs to an account. It never reports
+	// whether the account exists so it can't be used to enumerate emails
+	ForgotPasswordCommand struct {
+		Email string
+	}
+
+	ResetPasswordCommand struct {
+		Token    string
+		Password string
+	}
+)
+
+const passwordResetTTL = time.Hour
+
+func (cmd *ForgotPasswordCommand) Handle() error {
+
+	db := config.Container.Database
+	mailer := config.Container.Mailer
+
+	user := &models.User{}
+
+	res := db.Find(user, "email = ?", cmd.Email)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if user.ID == uuid.Nil {
+		return nil
+	}
+
+	// binding the token to the current hash makes it single use
+	token, err := lib.CreateSignedToken(lib.PasswordResetPurpose, map[string]interface{}{
+		"userId":   user.ID.String(),
+		"password": lib.Fingerprint(user.HashedPassword),
+	}, passwordResetTTL)
+
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf(
+		"Hi %s,\n\nSomeone asked to reset the password of your account. If it was you, open the link below within an hour:\n\n%s/v1/passwordResets/confirmation?token=%s\n\nOtherwise you can ignore this email.\n",
+		user.FullName, appURL(), token,
+	)
+
+	return mailer.Send(user.Email, "Reset your password", body)
+}
+
+func (cmd *ResetPasswordCommand) Handle() error {
+
+	db := config.Container.Database
+
+	claims, err := lib.ParseSignedToken(lib.PasswordResetPurpose, cmd.Token)
+
+	if err != nil {
+		return err
+	}
+
+	user := &models.User{}
+
+	res := db.Find(user, "id = ?", claims["userId"])
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if user.ID == uuid.Nil || lib.Fingerprint(user.HashedPassword) != claims["password"] {
+		return apperrors.Validation("invalid or expired token")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(cmd.Password), bcrypt.DefaultCost)
+
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+
+		res := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"hashed_password": string(hashedPassword),
+			"token_version":   gorm.Expr("token_version + 1"),
+		})
+
+		if res.Error != nil {
+			return res.Error
+		}
+
+		return events.Record(tx, &events.PasswordReset{
+			UserID: user.ID,
+		})
+	})
+}
@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"music-sharing/shared/apperrors"
+	"music-sharing/user-microservice/internal/app/models"
+	config "music-sharing/user-microservice/pkg"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RequestDataExportCommand struct {
+	CurrentUser *models.User
+	// Set by Handle to the export that was queued
+	Export *models.DataExport
+}
+
+func (cmd *RequestDataExportCommand) Handle() error {
+
+	db := config.Container.Database
+
+	var count int64
+
+	res := db.Model(&models.DataExport{}).
+		Where("user_id = ? AND status IN ?", cmd.CurrentUser.ID, []string{models.DataExportPending, models.DataExportProcessing}).
+		Count(&count)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if count != 0 {
+		return apperrors.Conflict("a data export is already in progress")
+	}
+
+	cmd.Export = &models.DataExport{
+		ID:          uuid.New(),
+		UserID:      cmd.CurrentUser.ID,
+		Status:      models.DataExportPending,
+		RequestedAt: time.Now().UTC(),
+	}
+
+	return db.Create(cmd.Export).Error
+}
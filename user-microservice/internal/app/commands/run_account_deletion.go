@@ -0,0 +1,281 @@
+package commands
+
+import (
+	"music-sharing/shared/apperrors"
+	"music-sharing/shared/internalapi"
+	"music-sharing/user-microservice/internal/app/events"
+	"music-sharing/user-microservice/internal/app/models"
+	config "music-sharing/user-microservice/pkg"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type (
+	// Runs the remaining steps of a due account deletion. Every step is
+	// idempotent and recorded once done, so a failed cascade is resumed from
+	// the step that failed the next time the command is sent
+	RunAccountDeletionCommand struct {
+		DeletionID uuid.UUID
+	}
+
+	purgeUserDataResponse struct {
+		LikesRemoved     int64 `json:"likesRemoved"`
+		ListensRemoved   int64 `json:"listensRemoved"`
+		TracksRemoved    int64 `json:"tracksRemoved"`
+		TracksAnonymised int64 `json:"tracksAnonymised"`
+	}
+)
+
+func (cmd *RunAccountDeletionCommand) Handle() error {
+
+	db := config.Container.Database
+	deletion := &models.AccountDeletion{}
+
+	res := db.Find(deletion, "id = ?", cmd.DeletionID)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if deletion.ID == uuid.Nil {
+		return apperrors.NotFound("account deletion doesnt exist")
+	}
+
+	if deletion.Status != models.AccountDeletionPending && deletion.Status != models.AccountDeletionInProgress {
+		return nil
+	}
+
+	if deletion.ScheduledFor.After(time.Now().UTC()) {
+		return apperrors.Conflict("account deletion grace period has not ended yet")
+	}
+
+	if deletion.StartedAt == nil {
+		now := time.Now().UTC()
+		deletion.StartedAt = &now
+	}
+
+	claim := map[string]interface{}{
+		"status":     models.AccountDeletionInProgress,
+		"started_at": deletion.StartedAt,
+		"attempts":   gorm.Expr("attempts + 1"),
+	}
+
+	// a cancel that committed since the deletion was read wins
+	if err := updateDeletion(db, deletion, []string{models.AccountDeletionPending, models.AccountDeletionInProgress}, claim); err != nil {
+		return err
+	}
+
+	deletion.Status = models.AccountDeletionInProgress
+	deletion.Attempts++
+
+	steps := map[string]func(*gorm.DB, *models.AccountDeletion) error{
+		models.AccountDeletionStepMusic:   purgeMusicData,
+		models.AccountDeletionStepFollows: removeFollowEdges,
+		models.AccountDeletionStepAccount: removeAccount,
+	}
+
+	inProgress := []string{models.AccountDeletionInProgress}
+
+	for _, step := range models.AccountDeletionSteps {
+		if deletion.HasCompletedStep(step) {
+			continue
+		}
+
+		if err := steps[step](db, deletion); err != nil {
+			deletion.LastError = err.Error()
+			updateDeletion(db, deletion, inProgress, deletionProgress(deletion))
+
+			return err
+		}
+
+		deletion.CompleteStep(step)
+		deletion.LastError = ""
+
+		if err := updateDeletion(db, deletion, inProgress, deletionProgress(deletion)); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().UTC()
+
+	return updateDeletion(db, deletion, inProgress, map[string]interface{}{
+		"status":       models.AccountDeletionCompleted,
+		"completed_at": now,
+	})
+}
+
+// Writes the columns of the deletion only while it is in one of the from
+// statuses. Saving the whole row would undo a cancel committed in between
+func updateDeletion(db *gorm.DB, deletion *models.AccountDeletion, from []string, columns map[string]interface{}) error {
+
+	res := db.Model(&models.AccountDeletion{}).
+		Where("id = ? AND status IN ?", deletion.ID, from).
+		Updates(columns)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return apperrors.Conflict("account deletion was cancelled or has already completed")
+	}
+
+	return nil
+}
+
+func deletionProgress(deletion *models.AccountDeletion) map[string]interface{} {
+	return map[string]interface{}{
+		"completed_steps":   deletion.CompletedSteps,
+		"progress":          deletion.Progress,
+		"follows_removed":   deletion.FollowsRemoved,
+		"likes_removed":     deletion.LikesRemoved,
+		"listens_removed":   deletion.ListensRemoved,
+		"tracks_removed":    deletion.TracksRemoved,
+		"tracks_anonymised": deletion.TracksAnonymised,
+		"last_error":        deletion.LastError,
+	}
+}
+
+func purgeMusicData(db *gorm.DB, deletion *models.AccountDeletion) error {
+
+	resp := &purgeUserDataResponse{}
+	url := os.Getenv("MUSIC_SERVICE") + "/internal/users/" + deletion.UserID.String() + "/purge"
+
+	if err := internalapi.Send("POST", url, nil, resp); err != nil {
+		return err
+	}
+
+	deletion.LikesRemoved += resp.LikesRemoved
+	deletion.ListensRemoved += resp.ListensRemoved
+	deletion.TracksRemoved += resp.TracksRemoved
+	deletion.TracksAnonymised += resp.TracksAnonymised
+
+	return nil
+}
+
+func removeFollowEdges(db *gorm.DB, deletion *models.AccountDeletion) error {
+
+	res := db.Where("requester_id = ? OR target_id = ?", deletion.UserID, deletion.UserID).Delete(&models.FollowRequest{})
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	for {
+		edges := []models.Follow{}
+
+		res := db.Where("follower_id = ? OR followee_id = ?", deletion.UserID, deletion.UserID).Limit(100).Find(&edges)
+
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if len(edges) == 0 {
+			return nil
+		}
+
+		for _, edge := range edges {
+			err := db.Transaction(func(tx *gorm.DB) error {
+
+				removed, err := removeFollowEdge(tx, &edge)
+
+				if err != nil {
+					return err
+				}
+
+				if removed {
+					deletion.FollowsRemoved++
+				}
+
+				return nil
+			})
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func removeAccount(db *gorm.DB, deletion *models.AccountDeletion) error {
+
+	user := &models.User{}
+
+	res := db.Unscoped().Find(user, "id = ?", deletion.UserID)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if user.ID == uuid.Nil {
+		return nil
+	}
+
+	exports := []models.DataExport{}
+
+	if res := db.Find(&exports, "user_id = ?", user.ID); res.Error != nil {
+		return res.Error
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+
+		for _, model := range []interface{}{&models.DataExport{}, &models.RecoveryCode{}, &models.UserIdentity{}} {
+			if res := tx.Where("user_id = ?", user.ID).Delete(model); res.Error != nil {
+				return res.Error
+			}
+		}
+
+		if res := tx.Where("blocker_id = ? OR blocked_id = ?", user.ID, user.ID).Delete(&models.Block{}); res.Error != nil {
+			return res.Error
+		}
+
+		if err := removeActivities(tx, tx.Model(&models.Activity{}).Where("actor_id = ? OR object_id = ?", user.ID, user.ID.String())); err != nil {
+			return err
+		}
+
+		if res := tx.Where("owner_id = ?", user.ID).Delete(&models.FeedItem{}); res.Error != nil {
+			return res.Error
+		}
+
+		if res := tx.Where("user_id = ?", user.ID).Delete(&models.FeedState{}); res.Error != nil {
+			return res.Error
+		}
+
+		if res := tx.Where("user_id = ? OR actor_id = ?", user.ID, user.ID).Delete(&models.Notification{}); res.Error != nil {
+			return res.Error
+		}
+
+		if res := tx.Where("user_id = ?", user.ID).Delete(&models.NotificationPreference{}); res.Error != nil {
+			return res.Error
+		}
+
+		if res := tx.Unscoped().Delete(user); res.Error != nil {
+			return res.Error
+		}
+
+		return events.Record(tx, &events.UserDeleted{
+			UserID: user.ID,
+		})
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, export := range exports {
+		if len(export.FilePath) != 0 {
+			os.Remove(export.FilePath)
+		}
+	}
+
+	if strings.HasPrefix(user.ProfileURL, "/profiles/") {
+		os.Remove(filepath.Join("./internal/static/profiles/", filepath.Base(user.ProfileURL)))
+	}
+
+	return nil
+}
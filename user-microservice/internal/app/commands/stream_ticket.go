@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"music-sharing/user-microservice/internal/app/models"
+	"music-sharing/user-microservice/internal/lib"
+	"time"
+)
+
+const streamTicketTTL = time.Minute
+
+// Mints the ticket the notification stream is opened with. An EventSource
+// can't send headers and a session token in the url would end up in logs and
+// browser histories, the ticket only opens the stream and expires quickly
+type CreateStreamTicketCommand struct {
+	CurrentUser *models.User
+	Ticket      string
+	ExpiresAt   time.Time
+}
+
+func (cmd *CreateStreamTicketCommand) Handle() error {
+
+	ticket, err := lib.CreateSignedToken(lib.StreamTicketPurpose, map[string]interface{}{
+		"userId":       cmd.CurrentUser.ID.String(),
+		"tokenVersion": cmd.CurrentUser.TokenVersion,
+	}, streamTicketTTL)
+
+	if err != nil {
+		return err
+	}
+
+	cmd.Ticket = ticket
+	cmd.ExpiresAt = time.Now().Add(streamTicketTTL).UTC()
+
+	return nil
+}
@@ -0,0 +1,330 @@
+package commands
+
+import (
+	"music-sharing/shared/apperrors"
+	"music-sharing/user-microservice/internal/app/events"
+	"music-sharing/user-microservice/internal/app/models"
+	"music-sharing/user-microservice/internal/lib"
+	config "music-sharing/user-microservice/pkg"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type (
+	// Generates a new secret for the user, 2FA is only turned on once a code
+	// generated from it is sent to ConfirmTwoFactorCommand
+	EnrollTwoFactorCommand struct {
+		CurrentUser *models.User
+		// Set by Handle
+		Secret          string
+		ProvisioningURI string
+	}
+
+	ConfirmTwoFactorCommand struct {
+		Code        string
+		CurrentUser *models.User
+		// Set by Handle, the codes are only stored hashed and shown once
+		RecoveryCodes []string
+	}
+
+	// Accounts without a password, made by a social login, re-authenticate
+	// with a current TOTP or recovery code instead
+	DisableTwoFactorCommand struct {
+		Password     string
+		Code         string
+		RecoveryCode string
+		CurrentUser  *models.User
+	}
+
+	// Exchanges the token returned by the login of a 2FA account and a TOTP or
+	// recovery code for a session token
+	VerifyTwoFactorCommand struct {
+		MFAToken     string
+		Code         string
+		RecoveryCode string
+		IP           string
+		// Set by Handle to the session token
+		Token string
+	}
+)
+
+const (
+	mfaTokenTTL       = 5 * time.Minute
+	recoveryCodeCount = 10
+)
+
+var ErrInvalidTwoFactorCode = apperrors.Unauthorized("invalid two factor code")
+
+func totpIssuer() string {
+	if issuer := os.Getenv("TOTP_ISSUER"); len(issuer) != 0 {
+		return issuer
+	}
+
+	return "Music Sharing"
+}
+
+func (cmd *EnrollTwoFactorCommand) Handle() error {
+
+	db := config.Container.Database
+	user := cmd.CurrentUser
+
+	if user.TOTPEnabled {
+		return apperrors.Conflict("two factor authentication is already enabled")
+	}
+
+	secret, err := lib.GenerateTOTPSecret()
+
+	if err != nil {
+		return err
+	}
+
+	if res := db.Model(&models.User{}).Where("id = ?", user.ID).Update("totp_secret", secret); res.Error != nil {
+		return res.Error
+	}
+
+	cmd.Secret = secret
+	cmd.ProvisioningURI = lib.TOTPProvisioningURI(totpIssuer(), user.Email, secret)
+
+	return nil
+}
+
+func (cmd *ConfirmTwoFactorCommand) Handle() error {
+
+	db := config.Container.Database
+	user := cmd.CurrentUser
+
+	if user.TOTPEnabled {
+		return apperrors.Conflict("two factor authentication is already enabled")
+	}
+
+	if len(user.TOTPSecret) == 0 {
+		return apperrors.Conflict("two factor enrollment was not started")
+	}
+
+	step, valid := lib.ValidateTOTP(user.TOTPSecret, cmd.Code, time.Now())
+
+	if !valid {
+		return ErrInvalidTwoFactorCode
+	}
+
+	codes, err := lib.GenerateRecoveryCodes(recoveryCodeCount)
+
+	if err != nil {
+		return err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+
+		res := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"totp_enabled":   true,
+			"totp_last_step": step,
+		})
+
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if err := replaceRecoveryCodes(tx, user.ID, codes); err != nil {
+			return err
+		}
+
+		return events.Record(tx, &events.TwoFactorEnabled{
+			UserID: user.ID,
+		})
+	})
+
+	if err != nil {
+		return err
+	}
+
+	cmd.RecoveryCodes = codes
+
+	return nil
+}
+
+func (cmd *DisableTwoFactorCommand) Handle() error {
+
+	db := config.Container.Database
+	user := cmd.CurrentUser
+
+	if len(user.HashedPassword) != 0 {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(cmd.Password)); err != nil {
+			return apperrors.Forbidden("invalid password")
+		}
+	}
+
+	if !user.TOTPEnabled {
+		return apperrors.Conflict("two factor authentication is not enabled")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+
+		if len(user.HashedPassword) == 0 {
+			if err := useSecondFactor(tx, user, cmd.Code, cmd.RecoveryCode, time.Now().UTC()); err != nil {
+				if err == ErrInvalidTwoFactorCode {
+					return apperrors.Forbidden("invalid two factor code")
+				}
+
+				return err
+			}
+		}
+
+		return disableTwoFactor(tx, user)
+	})
+}
+
+func (cmd *VerifyTwoFactorCommand) Handle() error {
+
+	db := config.Container.Database
+	now := time.Now().UTC()
+
+	claims, err := lib.ParseSignedToken(lib.MFAPurpose, cmd.MFAToken)
+
+	if err != nil {
+		return err
+	}
+
+	user := &models.User{}
+
+	res := db.Find(user, "id = ?", claims["userId"])
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	tokenVersion, _ := claims["tokenVersion"].(float64)
+
+	if user.ID == uuid.Nil || !user.TOTPEnabled || uint(tokenVersion) != user.TokenVersion {
+		return apperrors.Validation("invalid or expired token")
+	}
+
+	if err := ensureCanLogin(user); err != nil {
+		return err
+	}
+
+	if err := checkLoginThrottle(db, user.Email, cmd.IP, now); err != nil {
+		recordLoginAttempt(db, user.Email, &user.ID, cmd.IP, true, models.LoginAttemptLockedOut)
+
+		return err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return useSecondFactor(tx, user, cmd.Code, cmd.RecoveryCode, now)
+	})
+
+	if err == ErrInvalidTwoFactorCode {
+		recordLoginAttempt(db, user.Email, &user.ID, cmd.IP, true, models.LoginAttemptWrongMFACode)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	tokenString, err := lib.CreateUserToken(user)
+
+	if err != nil {
+		return err
+	}
+
+	if res := recordLoginAttempt(db, user.Email, &user.ID, cmd.IP, false, models.LoginAttemptSucceeded); res.Error != nil {
+		return res.Error
+	}
+
+	cmd.Token = tokenString
+
+	return nil
+}
+
+func createMFAToken(user *models.User) (string, error) {
+	return lib.CreateSignedToken(lib.MFAPurpose, map[string]interface{}{
+		"userId":       user.ID.String(),
+		"tokenVersion": user.TokenVersion,
+	}, mfaTokenTTL)
+}
+
+func disableTwoFactor(tx *gorm.DB, user *models.User) error {
+
+	res := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"totp_enabled":   false,
+		"totp_secret":    "",
+		"totp_last_step": 0,
+	})
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}); res.Error != nil {
+		return res.Error
+	}
+
+	return events.Record(tx, &events.TwoFactorDisabled{
+		UserID: user.ID,
+	})
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userId uuid.UUID, codes []string) error {
+
+	if res := tx.Where("user_id = ?", userId).Delete(&models.RecoveryCode{}); res.Error != nil {
+		return res.Error
+	}
+
+	for _, code := range codes {
+		res := tx.Create(&models.RecoveryCode{
+			ID:       uuid.New(),
+			UserID:   userId,
+			CodeHash: lib.HashRecoveryCode(code),
+		})
+
+		if res.Error != nil {
+			return res.Error
+		}
+	}
+
+	return nil
+}
+
+// Checks the TOTP code, or the recovery code when one is given, and marks it
+// as used
+func useSecondFactor(tx *gorm.DB, user *models.User, code string, recoveryCode string, now time.Time) error {
+
+	if len(recoveryCode) != 0 {
+		return useRecoveryCode(tx, user.ID, recoveryCode, now)
+	}
+
+	step, valid := lib.ValidateTOTP(user.TOTPSecret, code, now)
+
+	if !valid || step <= user.TOTPLastStep {
+		return ErrInvalidTwoFactorCode
+	}
+
+	// a code can't be used twice, even by two logins racing each other
+	res := tx.Model(&models.User{}).Where("id = ?", user.ID).Where("totp_last_step < ?", step).Update("totp_last_step", step)
+
+	if res.Error == nil && res.RowsAffected == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return res.Error
+}
+
+func useRecoveryCode(tx *gorm.DB, userId uuid.UUID, code string, now time.Time) error {
+
+	res := tx.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userId, lib.HashRecoveryCode(code)).
+		Update("used_at", now)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return nil
+}
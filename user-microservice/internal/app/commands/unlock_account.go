@@ -0,0 +1,41 @@
+package commands
+
+import (
+	"music-sharing/shared/apperrors"
+	"music-sharing/user-microservice/internal/app/models"
+	config "music-sharing/user-microservice/pkg"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Lifts the lockout of an account by resetting its failed login count
+type UnlockAccountCommand struct {
+	UserID uuid.UUID
+	Actor  *models.User
+}
+
+func (cmd *UnlockAccountCommand) Handle() error {
+
+	db := config.Container.Database
+	user := &models.User{}
+
+	res := db.Find(user, "id = ?", cmd.UserID)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if user.ID == uuid.Nil {
+		return apperrors.NotFound("user doesnt exist")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+
+		if res := recordLoginAttempt(tx, user.Email, &user.ID, "", false, models.LoginAttemptUnlockedByAdmin); res.Error != nil {
+			return res.Error
+		}
+
+		return recordAudit(tx, cmd.Actor, AuditAccountUnlocked, user.ID, map[string]interface{}{})
+	})
+}
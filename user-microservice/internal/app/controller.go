@@ -1,18 +1,24 @@
 package app
 
 import (
-	"errors"
 	"io"
+	"mime/multipart"
+	"music-sharing/shared/apperrors"
+	"music-sharing/shared/rest"
 	"music-sharing/user-microservice/internal/app/commands"
+	"music-sharing/user-microservice/internal/app/events"
 	"music-sharing/user-microservice/internal/app/models"
 	"music-sharing/user-microservice/internal/app/queries"
 	"music-sharing/user-microservice/internal/lib"
 	config "music-sharing/user-microservice/pkg"
+	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type (
@@ -29,11 +35,113 @@ type (
 		IsPrivate bool   `json:"isPrivate"`
 	}
 
+	// A JSON Merge Patch of the account of the caller, the members left out
+	// are not changed. ID may be left out too, it is always the caller's
 	UpdateAccountBody struct {
-		ID        uuid.UUID `json:"id" gorm:"primaryKey"`
-		FullName  string    `json:"fullName"`
-		Email     string    `json:"email"`
-		IsPrivate bool      `json:"isPrivate"`
+		ID        uuid.UUID `json:"id"`
+		FullName  *string   `json:"fullName" validate:"omitempty,min=1"`
+		IsPrivate *bool     `json:"isPrivate"`
+	}
+
+	// Passwordless accounts leave the current password out, the change is
+	// confirmed by email instead
+	ChangePasswordBody struct {
+		CurrentPassword string `json:"currentPassword"`
+		NewPassword     string `json:"newPassword" validate:"required,min=8"`
+	}
+
+	ChangeEmailBody struct {
+		Password string `json:"password"`
+		NewEmail string `json:"newEmail" validate:"required,email"`
+	}
+
+	ConfirmPasswordChangeBody struct {
+		Token string `json:"token" validate:"required"`
+	}
+
+	ConfirmEmailChangeBody struct {
+		Token string `json:"token" validate:"required"`
+	}
+
+	ConfirmAccountDeletionBody struct {
+		Token string `json:"token" validate:"required"`
+	}
+
+	VerifyEmailBody struct {
+		Token string `json:"token" validate:"required"`
+	}
+
+	ForgotPasswordBody struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+
+	ResetPasswordBody struct {
+		Token    string `json:"token" validate:"required"`
+		Password string `json:"password" validate:"required,min=8"`
+	}
+
+	ConfirmTwoFactorBody struct {
+		Code string `json:"code" validate:"required"`
+	}
+
+	// Accounts without a password send a code of the second factor instead
+	DisableTwoFactorBody struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recoveryCode"`
+	}
+
+	VerifyTwoFactorBody struct {
+		MFAToken     string `json:"mfaToken" validate:"required"`
+		Code         string `json:"code" validate:"required_without=RecoveryCode"`
+		RecoveryCode string `json:"recoveryCode"`
+	}
+
+	ChangeUserRoleBody struct {
+		Role string `json:"role" validate:"required"`
+	}
+
+	SuspendUserBody struct {
+		Reason string `json:"reason" validate:"required"`
+	}
+
+	MarkFeedSeenBody struct {
+		ActivityID uint `json:"activityId"`
+	}
+
+	MarkNotificationsReadBody struct {
+		IDs []uint `json:"ids"`
+		All bool   `json:"all"`
+	}
+
+	// The music service sends the owners of the tracks it shows, in batches
+	LookupFollowingsBody struct {
+		UserIDs []string `json:"userIds" validate:"max=500"`
+	}
+
+	// EventID is the id of the event the notice is sent for, it keeps a
+	// redelivered event from mailing twice
+	NotifyUserBody struct {
+		EventID string `json:"eventId" validate:"max=64"`
+		Subject string `json:"subject" validate:"required"`
+		Message string `json:"message" validate:"required"`
+	}
+
+	// Password is left out by the accounts without one, they confirm the
+	// deletion from an email instead
+	DeleteAccountBody struct {
+		Password string `json:"password"`
+	}
+
+	UploadProfileForm struct {
+		Profile *multipart.FileHeader `json:"profile"`
+	}
+
+	// OwnAccount is the account of the caller, with the fields only its owner
+	// may see
+	OwnAccount struct {
+		*models.User
+		PendingEmail string `json:"pendingEmail"`
 	}
 
 	UserController struct{}
@@ -41,9 +149,8 @@ type (
 
 func (ctrl *UserController) Login(c *gin.Context) {
 
-	db := config.Container.Database
 	loginBody := &LoginBody{}
-	user := &models.User{}
+	commandBus := config.Container.CommmandBus
 
 	err := lib.BindAndValidate(loginBody, c)
 
@@ -52,35 +159,29 @@ func (ctrl *UserController) Login(c *gin.Context) {
 		return
 	}
 
-	resp := db.Find(user, "Email = ?", loginBody.Email)
-
-	if resp.Error != nil {
-		c.Error(resp.Error)
-		return
+	cmd := &commands.LoginCommand{
+		Email:    loginBody.Email,
+		Password: loginBody.Password,
+		IP:       c.ClientIP(),
 	}
 
-	if user.ID == uuid.Nil {
-		c.Error(errors.New("user doesnt exist"))
-		return
-	}
+	err = commandBus.Send(cmd)
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(loginBody.Password)); err != nil {
+	if err != nil {
 		c.Error(err)
 		return
 	}
 
-	tokenString, err := lib.CreateJWT(map[string]interface{}{
-		"userId":    user.ID.String(),
-		"isPrivate": user.IsPrivate,
-	})
-
-	if err != nil {
-		c.Error(err)
+	if cmd.MFARequired {
+		c.JSON(200, gin.H{
+			"mfaRequired": true,
+			"mfaToken":    cmd.MFAToken,
+		})
 		return
 	}
 
 	c.JSON(200, gin.H{
-		"token": tokenString,
+		"token": cmd.Token,
 	})
 
 }
@@ -120,22 +221,35 @@ func (ctrl *UserController) MyProfile(c *gin.Context) {
 	user, exists := c.Get("user")
 
 	if !exists {
-		c.Error(errors.New("user doesnt exist"))
+		c.Error(apperrors.NotFound("user doesnt exist"))
 		return
 	}
 
-	c.JSON(200, user.(*models.User))
+	c.Header("ETag", rest.ETag(user.(*models.User).Version))
+	c.JSON(200, ownAccount(user.(*models.User)))
 
 }
 
 func (ctrl *UserController) ViewProfile(c *gin.Context) {
 
-	userId := c.Param("userId")
 	queryBus := config.Container.QueryBus
 
-	resp, err := queryBus.Send(&queries.GetUserProfileByIdQuery{
-		ID: uuid.MustParse(userId),
-	})
+	userId, err := uuid.Parse(c.Param("userId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	query := &queries.GetUserProfileByIdQuery{
+		ID: userId,
+	}
+
+	if viewer, exists := c.Get("user"); exists {
+		query.ViewerID = viewer.(*models.User).ID
+	}
+
+	resp, err := queryBus.Send(query)
 
 	if err != nil {
 		c.Error(err)
@@ -145,7 +259,7 @@ func (ctrl *UserController) ViewProfile(c *gin.Context) {
 	user := resp.(*queries.GetUserProfileByIdQueryResponse).User
 
 	if user.IsPrivate {
-		c.Error(errors.New("this account is private"))
+		c.Error(apperrors.Forbidden("this account is private"))
 		return
 	}
 
@@ -154,45 +268,51 @@ func (ctrl *UserController) ViewProfile(c *gin.Context) {
 }
 
 func (ctrl *UserController) FollowUser(c *gin.Context) {
-	userId := c.Param("userId")
 	user := c.MustGet("user").(*models.User)
 	commandBus := config.Container.CommmandBus
 
-	if len(userId) == 0 {
-		c.Error(errors.New("user id is required"))
+	userId, err := uuid.Parse(c.Param("userId"))
+
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	err := commandBus.Send(&commands.FollowOrUnfollowUserCommand{
+	cmd := &commands.FollowOrUnfollowUserCommand{
 		Follow:      true,
-		UserId:      uuid.MustParse(userId),
+		UserId:      userId,
 		CurrentUser: user,
-	})
+	}
+
+	err = commandBus.Send(cmd)
 
 	if err != nil {
 		c.Error(err)
 		return
 	}
 
+	// following a private account waits for its owner to approve it
 	c.JSON(200, gin.H{
-		"success": true,
+		"success":   true,
+		"requested": cmd.Requested,
 	})
 
 }
 
 func (ctrl *UserController) UnfollowUser(c *gin.Context) {
-	userId := c.Param("userId")
 	user := c.MustGet("user").(*models.User)
 	commandBus := config.Container.CommmandBus
 
-	if len(userId) == 0 {
-		c.Error(errors.New("user id is required"))
+	userId, err := uuid.Parse(c.Param("userId"))
+
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	err := commandBus.Send(&commands.FollowOrUnfollowUserCommand{
+	err = commandBus.Send(&commands.FollowOrUnfollowUserCommand{
 		Follow:      false,
-		UserId:      uuid.MustParse(userId),
+		UserId:      userId,
 		CurrentUser: user,
 	})
 
@@ -212,32 +332,83 @@ func (ctrl *UserController) UpdateMyAccount(c *gin.Context) {
 	user := c.MustGet("user").(*models.User)
 	body := &UpdateAccountBody{}
 
-	err := lib.BindAndValidate(body, c)
+	versions, versioned, err := rest.IfMatchVersion[uint](c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	removed, err := rest.BindMergePatch(body, c)
 
 	if err != nil {
 		c.Error(err)
 		return
 	}
 
-	if user.ID != body.ID {
-		c.Error(errors.New("user id doesnt match"))
+	if len(removed) != 0 {
+		fields := []apperrors.FieldError{}
+
+		for _, name := range removed {
+			fields = append(fields, apperrors.Field(name, "can't be removed"))
+		}
+
+		c.Error(apperrors.Validation("the patch removes required fields", fields...))
+		return
+	}
+
+	if body.ID != uuid.Nil && user.ID != body.ID {
+		c.Error(apperrors.Forbidden("user id doesnt match"))
 		return
 	}
 
-	resp := db.Save(user)
+	// the email and the password have their own commands that re-authenticate
+	changes := map[string]interface{}{"version": gorm.Expr("version + 1")}
+
+	if body.FullName != nil {
+		changes["full_name"] = *body.FullName
+	}
+
+	if body.IsPrivate != nil {
+		changes["is_private"] = *body.IsPrivate
+	}
+
+	scoped := db.Model(&models.User{}).Where("id = ?", user.ID)
+
+	// only the legacy route takes updates without If-Match
+	if versioned {
+		scoped = scoped.Where("version IN ?", versions)
+	}
+
+	resp := scoped.Updates(changes)
 
 	if resp.Error != nil {
 		c.Error(resp.Error)
 		return
 	}
 
+	if resp.RowsAffected == 0 {
+		c.Error(apperrors.PreconditionFailed("the account was changed since it was read, read it again and retry"))
+		return
+	}
+
+	if resp := db.First(user, "id = ?", user.ID); resp.Error != nil {
+		c.Error(resp.Error)
+		return
+	}
+
+	c.Header("ETag", rest.ETag(user.Version))
 	c.JSON(200, gin.H{
 		"success": true,
-		"user":    user,
+		"user":    ownAccount(user),
 	})
 
 }
 
+func ownAccount(user *models.User) *OwnAccount {
+	return &OwnAccount{User: user, PendingEmail: user.PendingEmail}
+}
+
 func (ctrl *UserController) UploadProfile(c *gin.Context) {
 
 	db := config.Container.Database
@@ -268,11 +439,1462 @@ func (ctrl *UserController) UploadProfile(c *gin.Context) {
 		return
 	}
 
-	user.ProfileURL = "/profiles/" + header.Filename
+	resp := db.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"profile_url": "/profiles/" + header.Filename,
+		"version":     gorm.Expr("version + 1"),
+	})
+
+	if resp.Error != nil {
+		c.Error(resp.Error)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+func (ctrl *UserController) DeleteMyAccount(c *gin.Context) {
+
+	user := c.MustGet("user").(*models.User)
+	commandBus := config.Container.CommmandBus
+	body := &DeleteAccountBody{}
+
+	err := lib.BindAndValidate(body, c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	cmd := &commands.DeleteAccountCommand{
+		Password:    body.Password,
+		CurrentUser: user,
+	}
+
+	err = commandBus.Send(cmd)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if cmd.ConfirmationSent {
+		c.JSON(202, gin.H{
+			"success":          true,
+			"confirmationSent": true,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+func (ctrl *UserController) ConfirmAccountDeletion(c *gin.Context) {
+
+	commandBus := config.Container.CommmandBus
+	body := &ConfirmAccountDeletionBody{}
+
+	err := lib.BindAndValidate(body, c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = commandBus.Send(&commands.ConfirmAccountDeletionCommand{
+		Token: body.Token,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+// Page of the link of the account deletion confirmation email, its form posts
+// the token to ConfirmAccountDeletion
+func (ctrl *UserController) ConfirmAccountDeletionPage(c *gin.Context) {
+	renderPage(c, 200, confirmLinkPage, gin.H{
+		"Title":   "Delete your account",
+		"Message": "Your account will be deleted after the grace period, log in and cancel it until then if you change your mind.",
+		"Button":  "Delete my account",
+		"Action":  "/v1/accountDeletions/confirmation",
+		"Token":   c.Query("token"),
+		"Done":    "Your account deletion is scheduled, you can close this page.",
+	})
+}
+
+func (ctrl *UserController) CancelAccountDeletion(c *gin.Context) {
 
-	db.Save(user)
+	user := c.MustGet("user").(*models.User)
+	commandBus := config.Container.CommmandBus
+
+	err := commandBus.Send(&commands.CancelAccountDeletionCommand{
+		CurrentUser: user,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	c.JSON(200, gin.H{
 		"success": true,
 	})
 }
+
+func (ctrl *UserController) MyAccountDeletion(c *gin.Context) {
+
+	user := c.MustGet("user").(*models.User)
+	queryBus := config.Container.QueryBus
+
+	resp, err := queryBus.Send(&queries.GetAccountDeletionQuery{
+		UserID: user.ID,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, resp.(*queries.GetAccountDeletionQueryResponse).Deletion)
+}
+
+func (ctrl *UserController) RequestDataExport(c *gin.Context) {
+
+	user := c.MustGet("user").(*models.User)
+	commandBus := config.Container.CommmandBus
+	cmd := &commands.RequestDataExportCommand{
+		CurrentUser: user,
+	}
+
+	err := commandBus.Send(cmd)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(202, gin.H{
+		"success": true,
+		"export":  cmd.Export,
+	})
+}
+
+func (ctrl *UserController) MyDataExports(c *gin.Context) {
+
+	user := c.MustGet("user").(*models.User)
+	queryBus := config.Container.QueryBus
+
+	resp, err := queryBus.Send(&queries.GetDataExportsQuery{
+		UserID: user.ID,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, resp.(*queries.GetDataExportsQueryResponse).Exports)
+}
+
+func (ctrl *UserController) DownloadDataExport(c *gin.Context) {
+
+	user := c.MustGet("user").(*models.User)
+	queryBus := config.Container.QueryBus
+
+	exportId, err := uuid.Parse(c.Param("exportId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	resp, err := queryBus.Send(&queries.GetDataExportQuery{
+		ID:     exportId,
+		UserID: user.ID,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	export := resp.(*queries.GetDataExportQueryResponse).Export
+
+	if export.Status != models.DataExportReady || export.ExpiresAt.Before(time.Now()) {
+		c.Error(apperrors.Conflict("data export is not available for download"))
+		return
+	}
+
+	c.FileAttachment(export.FilePath, "data-export-"+export.RequestedAt.Format("2006-01-02")+".zip")
+}
+
+func (ctrl *UserController) VerifyEmail(c *gin.Context) {
+
+	body := &VerifyEmailBody{}
+	commandBus := config.Container.CommmandBus
+
+	err := lib.BindAndValidate(body, c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = commandBus.Send(&commands.VerifyEmailCommand{
+		Token: body.Token,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+// Page of the link of the verification email, its form posts the token to
+// VerifyEmail
+func (ctrl *UserController) VerifyEmailPage(c *gin.Context) {
+	renderPage(c, 200, confirmLinkPage, gin.H{
+		"Title":   "Verify your email",
+		"Message": "Confirm that this email address belongs to your account.",
+		"Button":  "Verify",
+		"Action":  "/v1/emailVerifications",
+		"Token":   c.Query("token"),
+		"Done":    "Your email address is verified, you can close this page.",
+	})
+}
+
+func (ctrl *UserController) ResendVerificationEmail(c *gin.Context) {
+
+	user := c.MustGet("user").(*models.User)
+	commandBus := config.Container.CommmandBus
+
+	if user.EmailVerified {
+		c.Error(apperrors.Conflict("email is already verified"))
+		return
+	}
+
+	err := commandBus.Send(&commands.SendEmailVerificationCommand{
+		UserID: user.ID,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+func (ctrl *UserController) ForgotPassword(c *gin.Context) {
+
+	body := &ForgotPasswordBody{}
+	commandBus := config.Container.CommmandBus
+
+	err := lib.BindAndValidate(body, c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = commandBus.Send(&commands.ForgotPasswordCommand{
+		Email: body.Email,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+func (ctrl *UserController) ResetPassword(c *gin.Context) {
+
+	body := &ResetPasswordBody{}
+	commandBus := config.Container.CommmandBus
+
+	err := lib.BindAndValidate(body, c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = commandBus.Send(&commands.ResetPasswordCommand{
+		Token:    body.Token,
+		Password: body.Password,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+// Page of the link of the password reset email, its form posts the new
+// password to ResetPassword
+func (ctrl *UserController) ResetPasswordPage(c *gin.Context) {
+	renderPage(c, 200, resetPasswordPage, gin.H{
+		"Action": "/v1/passwordResets/confirmation",
+		"Token":  c.Query("token"),
+	})
+}
+
+func (ctrl *UserController) ChangePassword(c *gin.Context) {
+
+	user := c.MustGet("user").(*models.User)
+	commandBus := config.Container.CommmandBus
+	body := &ChangePasswordBody{}
+
+	err := lib.BindAndValidate(body, c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	cmd := &commands.ChangePasswordCommand{
+		CurrentPassword: body.CurrentPassword,
+		NewPassword:     body.NewPassword,
+		CurrentUser:     user,
+	}
+
+	err = commandBus.Send(cmd)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if cmd.ConfirmationSent {
+		c.JSON(202, gin.H{
+			"success":          true,
+			"confirmationSent": true,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"token":   cmd.Token,
+	})
+}
+
+func (ctrl *UserController) ConfirmPasswordChange(c *gin.Context) {
+
+	commandBus := config.Container.CommmandBus
+	body := &ConfirmPasswordChangeBody{}
+
+	err := lib.BindAndValidate(body, c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = commandBus.Send(&commands.ConfirmPasswordChangeCommand{
+		Token: body.Token,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+// Page of the link of the password confirmation email of a passwordless
+// account, its form posts the token to ConfirmPasswordChange
+func (ctrl *UserController) ConfirmPasswordChangePage(c *gin.Context) {
+	renderPage(c, 200, confirmLinkPage, gin.H{
+		"Title":   "Set your password",
+		"Message": "Confirm the password you chose for your account.",
+		"Button":  "Set my password",
+		"Action":  "/v1/passwordChanges/confirmation",
+		"Token":   c.Query("token"),
+		"Done":    "Your password is set, you can log in with it.",
+	})
+}
+
+func (ctrl *UserController) ChangeEmail(c *gin.Context) {
+
+	user := c.MustGet("user").(*models.User)
+	commandBus := config.Container.CommmandBus
+	body := &ChangeEmailBody{}
+
+	err := lib.BindAndValidate(body, c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = commandBus.Send(&commands.ChangeEmailCommand{
+		Password:    body.Password,
+		NewEmail:    body.NewEmail,
+		CurrentUser: user,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+func (ctrl *UserController) ConfirmEmailChange(c *gin.Context) {
+
+	commandBus := config.Container.CommmandBus
+	body := &ConfirmEmailChangeBody{}
+
+	err := lib.BindAndValidate(body, c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = commandBus.Send(&commands.ConfirmEmailChangeCommand{
+		Token: body.Token,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+// Page of the link sent to the new address of an email change, its form posts
+// the token to ConfirmEmailChange
+func (ctrl *UserController) ConfirmEmailChangePage(c *gin.Context) {
+	renderPage(c, 200, confirmLinkPage, gin.H{
+		"Title":   "Change your email",
+		"Message": "Confirm that this email address becomes the one of your account.",
+		"Button":  "Change my email",
+		"Action":  "/v1/emailChanges/confirmation",
+		"Token":   c.Query("token"),
+		"Done":    "This is now the email address of your account, you can close this page.",
+	})
+}
+
+func (ctrl *UserController) UnlockAccount(c *gin.Context) {
+
+	commandBus := config.Container.CommmandBus
+
+	userId, err := uuid.Parse(c.Param("userId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	cmd := &commands.UnlockAccountCommand{
+		UserID: userId,
+	}
+
+	if actor, exists := c.Get("user"); exists {
+		cmd.Actor = actor.(*models.User)
+	}
+
+	err = commandBus.Send(cmd)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+func (ctrl *UserController) EnrollTwoFactor(c *gin.Context) {
+
+	user := c.MustGet("user").(*models.User)
+	commandBus := config.Container.CommmandBus
+	cmd := &commands.EnrollTwoFactorCommand{
+		CurrentUser: user,
+	}
+
+	err := commandBus.Send(cmd)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"secret":          cmd.Secret,
+		"provisioningUri": cmd.ProvisioningURI,
+	})
+}
+
+func (ctrl *UserController) ConfirmTwoFactor(c *gin.Context) {
+
+	user := c.MustGet("user").(*models.User)
+	commandBus := config.Container.CommmandBus
+	body := &ConfirmTwoFactorBody{}
+
+	err := lib.BindAndValidate(body, c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	cmd := &commands.ConfirmTwoFactorCommand{
+		Code:        body.Code,
+		CurrentUser: user,
+	}
+
+	err = commandBus.Send(cmd)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success":       true,
+		"recoveryCodes": cmd.RecoveryCodes,
+	})
+}
+
+func (ctrl *UserController) DisableTwoFactor(c *gin.Context) {
+
+	user := c.MustGet("user").(*models.User)
+	commandBus := config.Container.CommmandBus
+	body := &DisableTwoFactorBody{}
+
+	err := lib.BindAndValidate(body, c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = commandBus.Send(&commands.DisableTwoFactorCommand{
+		Password:     body.Password,
+		Code:         body.Code,
+		RecoveryCode: body.RecoveryCode,
+		CurrentUser:  user,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+func (ctrl *UserController) VerifyTwoFactor(c *gin.Context) {
+
+	commandBus := config.Container.CommmandBus
+	body := &VerifyTwoFactorBody{}
+
+	err := lib.BindAndValidate(body, c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	cmd := &commands.VerifyTwoFactorCommand{
+		MFAToken:     body.MFAToken,
+		Code:         body.Code,
+		RecoveryCode: body.RecoveryCode,
+		IP:           c.ClientIP(),
+	}
+
+	err = commandBus.Send(cmd)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"token": cmd.Token,
+	})
+}
+
+func (ctrl *UserController) OIDCLogin(c *gin.Context) {
+
+	commandBus := config.Container.CommmandBus
+	cmd := &commands.StartOIDCLoginCommand{
+		Provider: c.Param("provider"),
+	}
+
+	err := commandBus.Send(cmd)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	setOIDCStateCookie(c, cmd)
+	c.Redirect(302, cmd.AuthURL)
+}
+
+func (ctrl *UserController) OIDCLink(c *gin.Context) {
+
+	user := c.MustGet("user").(*models.User)
+	commandBus := config.Container.CommmandBus
+	cmd := &commands.StartOIDCLoginCommand{
+		Provider: c.Param("provider"),
+		LinkUser: user,
+	}
+
+	err := commandBus.Send(cmd)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// the auth url is not handed out, a link started by one account and opened
+	// in the browser of another would link the identity of the other
+	setOIDCStateCookie(c, cmd)
+	c.Redirect(303, cmd.AuthURL)
+}
+
+const oidcStateCookie = "oidc_state"
+
+// Ties the flow to the browser that started it. Lax keeps the cookie on the
+// top level redirect back from the identity provider
+func setOIDCStateCookie(c *gin.Context, cmd *commands.StartOIDCLoginCommand) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, cmd.StateBinding, int(time.Until(cmd.StateExpiresAt).Seconds()), "/", "", cmd.SecureCookie, true)
+}
+
+func (ctrl *UserController) OIDCCallback(c *gin.Context) {
+
+	commandBus := config.Container.CommmandBus
+
+	if providerErr := c.Query("error"); len(providerErr) != 0 {
+		c.Error(apperrors.Unauthorized("identity provider error: " + providerErr))
+		return
+	}
+
+	stateBinding, _ := c.Cookie(oidcStateCookie)
+	c.SetCookie(oidcStateCookie, "", -1, "/", "", false, true)
+
+	cmd := &commands.CompleteOIDCLoginCommand{
+		Provider:     c.Param("provider"),
+		State:        c.Query("state"),
+		StateBinding: stateBinding,
+		Code:         c.Query("code"),
+		IP:           c.ClientIP(),
+	}
+
+	err := commandBus.Send(cmd)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if cmd.Linked {
+		c.JSON(200, gin.H{
+			"success": true,
+			"linked":  true,
+		})
+		return
+	}
+
+	if cmd.MFARequired {
+		c.JSON(200, gin.H{
+			"mfaRequired": true,
+			"mfaToken":    cmd.MFAToken,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"token": cmd.Token,
+	})
+}
+
+func (ctrl *UserController) OIDCUnlink(c *gin.Context) {
+
+	user := c.MustGet("user").(*models.User)
+	commandBus := config.Container.CommmandBus
+
+	err := commandBus.Send(&commands.UnlinkIdentityCommand{
+		Provider:    c.Param("provider"),
+		CurrentUser: user,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+func (ctrl *UserController) MyIdentities(c *gin.Context) {
+
+	user := c.MustGet("user").(*models.User)
+	queryBus := config.Container.QueryBus
+
+	resp, err := queryBus.Send(&queries.GetUserIdentitiesQuery{
+		UserID: user.ID,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, resp.(*queries.GetUserIdentitiesQueryResponse).Identities)
+}
+
+func (ctrl *UserController) ChangeUserRole(c *gin.Context) {
+
+	commandBus := config.Container.CommmandBus
+	body := &ChangeUserRoleBody{}
+
+	userId, err := uuid.Parse(c.Param("userId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = lib.BindAndValidate(body, c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	cmd := &commands.ChangeUserRoleCommand{
+		UserID: userId,
+		Role:   body.Role,
+	}
+
+	if actor, exists := c.Get("user"); exists {
+		cmd.Actor = actor.(*models.User)
+	}
+
+	err = commandBus.Send(cmd)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+// Optional boolean query parameter, nil when it's missing
+func boolQuery(c *gin.Context, key string) (*bool, error) {
+
+	raw, exists := c.GetQuery(key)
+
+	if !exists || raw == "" {
+		return nil, nil
+	}
+
+	value, err := strconv.ParseBool(raw)
+
+	if err != nil {
+		return nil, apperrors.Validation("invalid value for " + key)
+	}
+
+	return &value, nil
+}
+
+func (ctrl *UserController) ListUsers(c *gin.Context) {
+
+	queryBus := config.Container.QueryBus
+
+	suspended, err := boolQuery(c, "suspended")
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	verified, err := boolQuery(c, "verified")
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("pageSize"))
+
+	resp, err := queryBus.Send(&queries.ListUsersQuery{
+		Search:    c.Query("q"),
+		Role:      c.Query("role"),
+		Suspended: suspended,
+		Verified:  verified,
+		Page:      page,
+		PageSize:  pageSize,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, resp)
+}
+
+func (ctrl *UserController) SuspendUser(c *gin.Context) {
+
+	commandBus := config.Container.CommmandBus
+	body := &SuspendUserBody{}
+
+	userId, err := uuid.Parse(c.Param("userId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = lib.BindAndValidate(body, c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	cmd := &commands.SuspendUserCommand{
+		UserID: userId,
+		Reason: body.Reason,
+	}
+
+	if actor, exists := c.Get("user"); exists {
+		cmd.Actor = actor.(*models.User)
+	}
+
+	err = commandBus.Send(cmd)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+func (ctrl *UserController) UnsuspendUser(c *gin.Context) {
+
+	actor := c.MustGet("user").(*models.User)
+	commandBus := config.Container.CommmandBus
+
+	userId, err := uuid.Parse(c.Param("userId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = commandBus.Send(&commands.UnsuspendUserCommand{
+		UserID: userId,
+		Actor:  actor,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+func (ctrl *UserController) ForceLogoutUser(c *gin.Context) {
+
+	actor := c.MustGet("user").(*models.User)
+	commandBus := config.Container.CommmandBus
+
+	userId, err := uuid.Parse(c.Param("userId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = commandBus.Send(&commands.ForceLogoutUserCommand{
+		UserID: userId,
+		Actor:  actor,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+func (ctrl *UserController) ResetTwoFactor(c *gin.Context) {
+
+	actor := c.MustGet("user").(*models.User)
+	commandBus := config.Container.CommmandBus
+
+	userId, err := uuid.Parse(c.Param("userId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = commandBus.Send(&commands.ResetTwoFactorCommand{
+		UserID: userId,
+		Actor:  actor,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+func (ctrl *UserController) GetAuditLog(c *gin.Context) {
+
+	queryBus := config.Container.QueryBus
+	query := &queries.GetAuditLogQuery{
+		Action: c.Query("action"),
+	}
+
+	for key, target := range map[string]*uuid.UUID{"actorId": &query.ActorID, "targetId": &query.TargetID} {
+
+		raw := c.Query(key)
+
+		if raw == "" {
+			continue
+		}
+
+		id, err := uuid.Parse(raw)
+
+		if err != nil {
+			c.Error(apperrors.Validation("invalid value for " + key))
+			return
+		}
+
+		*target = id
+	}
+
+	query.Page, _ = strconv.Atoi(c.Query("page"))
+	query.PageSize, _ = strconv.Atoi(c.Query("pageSize"))
+
+	resp, err := queryBus.Send(query)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, resp)
+}
+
+func (ctrl *UserController) NotifyUser(c *gin.Context) {
+
+	commandBus := config.Container.CommmandBus
+	body := &NotifyUserBody{}
+
+	userId, err := uuid.Parse(c.Param("userId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = lib.BindAndValidate(body, c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = commandBus.Send(&commands.NotifyUserCommand{
+		UserID:  userId,
+		EventID: body.EventID,
+		Subject: body.Subject,
+		Message: body.Message,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+func (ctrl *UserController) BlockUser(c *gin.Context) {
+	ctrl.blockOrUnblock(c, models.BlockKindBlock, true)
+}
+
+func (ctrl *UserController) UnblockUser(c *gin.Context) {
+	ctrl.blockOrUnblock(c, models.BlockKindBlock, false)
+}
+
+func (ctrl *UserController) MuteUser(c *gin.Context) {
+	ctrl.blockOrUnblock(c, models.BlockKindMute, true)
+}
+
+func (ctrl *UserController) UnmuteUser(c *gin.Context) {
+	ctrl.blockOrUnblock(c, models.BlockKindMute, false)
+}
+
+func (ctrl *UserController) blockOrUnblock(c *gin.Context, kind string, block bool) {
+
+	user := c.MustGet("user").(*models.User)
+	commandBus := config.Container.CommmandBus
+
+	userId, err := uuid.Parse(c.Param("userId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if block {
+		err = commandBus.Send(&commands.BlockUserCommand{
+			Kind:        kind,
+			UserId:      userId,
+			CurrentUser: user,
+		})
+	} else {
+		err = commandBus.Send(&commands.UnblockUserCommand{
+			Kind:        kind,
+			UserId:      userId,
+			CurrentUser: user,
+		})
+	}
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+func (ctrl *UserController) MyBlocks(c *gin.Context) {
+
+	user := c.MustGet("user").(*models.User)
+	queryBus := config.Container.QueryBus
+
+	resp, err := queryBus.Send(&queries.GetMyBlocksQuery{
+		UserID: user.ID,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, resp.(*queries.GetMyBlocksQueryResponse).Blocks)
+}
+
+func (ctrl *UserController) MyFollowRequests(c *gin.Context) {
+
+	user := c.MustGet("user").(*models.User)
+	queryBus := config.Container.QueryBus
+
+	resp, err := queryBus.Send(&queries.GetFollowRequestsQuery{
+		UserID: user.ID,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, resp.(*queries.GetFollowRequestsQueryResponse).FollowRequests)
+}
+
+func (ctrl *UserController) ApproveFollowRequest(c *gin.Context) {
+	ctrl.respondToFollowRequest(c, true)
+}
+
+func (ctrl *UserController) DenyFollowRequest(c *gin.Context) {
+	ctrl.respondToFollowRequest(c, false)
+}
+
+func (ctrl *UserController) respondToFollowRequest(c *gin.Context, approve bool) {
+
+	user := c.MustGet("user").(*models.User)
+	commandBus := config.Container.CommmandBus
+
+	requesterId, err := uuid.Parse(c.Param("userId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = commandBus.Send(&commands.RespondToFollowRequestCommand{
+		RequesterID: requesterId,
+		Approve:     approve,
+		CurrentUser: user,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+func (ctrl *UserController) GetViewerContext(c *gin.Context) {
+
+	queryBus := config.Container.QueryBus
+
+	userId, err := uuid.Parse(c.Param("userId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	resp, err := queryBus.Send(&queries.GetViewerContextQuery{
+		UserID: userId,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, resp)
+}
+
+// The users among the ids of the body that the user follows
+func (ctrl *UserController) LookupFollowings(c *gin.Context) {
+
+	queryBus := config.Container.QueryBus
+	body := &LookupFollowingsBody{}
+
+	userId, err := uuid.Parse(c.Param("userId"))
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = lib.BindAndValidate(body, c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// ids that are not of a user, e.g. of seeded artists, are followed by nobody
+	userIds := []uuid.UUID{}
+
+	for _, id := range body.UserIDs {
+		if parsed, err := uuid.Parse(id); err == nil {
+			userIds = append(userIds, parsed)
+		}
+	}
+
+	resp, err := queryBus.Send(&queries.GetFollowedUsersQuery{
+		UserID:  userId,
+		UserIDs: userIds,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, resp)
+}
+
+func (ctrl *UserController) MyFeed(c *gin.Context) {
+
+	user := c.MustGet("user").(*models.User)
+	queryBus := config.Container.QueryBus
+
+	cursor, _ := strconv.ParseUint(c.Query("cursor"), 10, 64)
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	resp, err := queryBus.Send(&queries.GetFeedQuery{
+		UserID: user.ID,
+		Cursor: uint(cursor),
+		Limit:  limit,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, resp)
+}
+
+func (ctrl *UserController) MarkFeedSeen(c *gin.Context) {
+
+	user := c.MustGet("user").(*models.User)
+	commandBus := config.Container.CommmandBus
+	body := &MarkFeedSeenBody{}
+
+	err := lib.BindAndValidate(body, c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = commandBus.Send(&commands.MarkFeedSeenCommand{
+		ActivityID:  body.ActivityID,
+		CurrentUser: user,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+// Receives the events forwarded by the other microservices and publishes them
+// to the local broker. A failed subscriber fails the request so the sender
+// retries the delivery
+func (ctrl *UserController) ReceiveEvent(c *gin.Context) {
+
+	broker := config.Container.Broker
+
+	message, err := io.ReadAll(c.Request.Body)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	envelope, err := events.ParseEnvelope(message)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := broker.Publish(envelope.Name, message); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+func (ctrl *UserController) MyNotifications(c *gin.Context) {
+
+	user := c.MustGet("user").(*models.User)
+	queryBus := config.Container.QueryBus
+
+	unread, err := boolQuery(c, "unread")
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	cursor, _ := strconv.ParseUint(c.Query("cursor"), 10, 64)
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	resp, err := queryBus.Send(&queries.GetNotificationsQuery{
+		UserID:     user.ID,
+		UnreadOnly: unread != nil && *unread,
+		Cursor:     uint(cursor),
+		Limit:      limit,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, resp)
+}
+
+func (ctrl *UserController) MarkNotificationsRead(c *gin.Context) {
+
+	user := c.MustGet("user").(*models.User)
+	commandBus := config.Container.CommmandBus
+	body := &MarkNotificationsReadBody{}
+
+	err := lib.BindAndValidate(body, c)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = commandBus.Send(&commands.MarkNotificationsReadCommand{
+		IDs:         body.IDs,
+		All:         body.All,
+		CurrentUser: user,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+func (ctrl *UserController) MyNotificationPreferences(c *gin.Context) {
+
+	user := c.MustGet("user").(*models.User)
+	queryBus := config.Container.QueryBus
+
+	resp, err := queryBus.Send(&queries.GetNotificationPreferencesQuery{
+		UserID: user.ID,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, resp.(*queries.GetNotificationPreferencesQueryResponse).Preferences)
+}
+
+// The body maps notification types to whether they are enabled
+func (ctrl *UserController) UpdateNotificationPreferences(c *gin.Context) {
+
+	user := c.MustGet("user").(*models.User)
+	commandBus := config.Container.CommmandBus
+	preferences := map[string]bool{}
+
+	if err := c.ShouldBindJSON(&preferences); err != nil {
+		c.Error(err)
+		return
+	}
+
+	err := commandBus.Send(&commands.UpdateNotificationPreferencesCommand{
+		Preferences: preferences,
+		CurrentUser: user,
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+	})
+}
+
+// Ticket to open the notification stream with, see StreamNotifications
+func (ctrl *UserController) CreateStreamTicket(c *gin.Context) {
+
+	user := c.MustGet("user").(*models.User)
+	commandBus := config.Container.CommmandBus
+	cmd := &commands.CreateStreamTicketCommand{
+		CurrentUser: user,
+	}
+
+	if err := commandBus.Send(cmd); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"ticket":    cmd.Ticket,
+		"expiresAt": cmd.ExpiresAt,
+	})
+}
+
+// Server-sent events stream of the new notifications of the user. Browsers
+// can't set headers on an EventSource so it is opened with ?ticket=
+func (ctrl *UserController) StreamNotifications(c *gin.Context) {
+
+	user := c.MustGet("user").(*models.User)
+	hub := config.Container.Hub
+
+	notifications, unsubscribe := hub.Subscribe(user.ID)
+	defer unsubscribe()
+
+	keepAlive := time.NewTicker(25 * time.Second)
+	defer keepAlive.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case payload := <-notifications:
+			c.SSEvent("notification", string(payload))
+		case <-keepAlive.C:
+			c.SSEvent("ping", "")
+		}
+
+		return true
+	})
+}
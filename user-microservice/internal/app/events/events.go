@@ -0,0 +1,317 @@
+package events
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	UserRegisteredEvent = "UserRegistered"
+	UserFollowedEvent   = "UserFollowed"
+	UserUnfollowedEvent = "UserUnfollowed"
+
+	FollowRequestedEvent = "FollowRequested"
+
+	AccountDeletionRequestedEvent = "AccountDeletionRequested"
+	AccountDeletionCancelledEvent = "AccountDeletionCancelled"
+	UserDeletedEvent              = "UserDeleted"
+
+	EmailVerifiedEvent = "EmailVerified"
+	PasswordResetEvent = "PasswordReset"
+
+	PasswordChangedEvent = "PasswordChanged"
+	EmailChangedEvent    = "EmailChanged"
+
+	TwoFactorEnabledEvent  = "TwoFactorEnabled"
+	TwoFactorDisabledEvent = "TwoFactorDisabled"
+
+	IdentityLinkedEvent   = "IdentityLinked"
+	IdentityUnlinkedEvent = "IdentityUnlinked"
+
+	UserRoleChangedEvent = "UserRoleChanged"
+	UserSuspendedEvent   = "UserSuspended"
+	UserUnsuspendedEvent = "UserUnsuspended"
+
+	UserBlockedEvent   = "UserBlocked"
+	UserUnblockedEvent = "UserUnblocked"
+
+	// published by music-microservice and forwarded to /internal/events
+	MusicUploadedEvent   = "MusicUploaded"
+	MusicLikedEvent      = "MusicLiked"
+	MusicDeletedEvent    = "MusicDeleted"
+	MusicCommentedEvent  = "MusicCommented"
+	MusicRepostedEvent   = "MusicReposted"
+	MusicUnrepostedEvent = "MusicUnreposted"
+	MusicHiddenEvent     = "MusicHidden"
+	MusicUnhiddenEvent   = "MusicUnhidden"
+
+	MusicVisibilityChangedEvent = "MusicVisibilityChanged"
+	MusicReleasedEvent          = "MusicReleased"
+
+	ModerationCaseResolvedEvent = "ModerationCaseResolved"
+	TakedownActionedEvent       = "TakedownActioned"
+	TakedownReinstatedEvent     = "TakedownReinstated"
+)
+
+// Visibility of a track, empty on events published before it existed
+const (
+	MusicVisibilityPublic    = "public"
+	MusicVisibilityUnlisted  = "unlisted"
+	MusicVisibilityPrivate   = "private"
+	MusicVisibilityFollowers = "followers"
+)
+
+type (
+	UserRegistered struct {
+		UserID    uuid.UUID `json:"userId"`
+		FullName  string    `json:"fullName"`
+		Email     string    `json:"email"`
+		IsPrivate bool      `json:"isPrivate"`
+	}
+
+	UserFollowed struct {
+		FollowerID uuid.UUID `json:"followerId"`
+		FolloweeID uuid.UUID `json:"followeeId"`
+	}
+
+	UserUnfollowed struct {
+		FollowerID uuid.UUID `json:"followerId"`
+		FolloweeID uuid.UUID `json:"followeeId"`
+	}
+
+	FollowRequested struct {
+		RequesterID uuid.UUID `json:"requesterId"`
+		TargetID    uuid.UUID `json:"targetId"`
+	}
+
+	AccountDeletionRequested struct {
+		UserID       uuid.UUID `json:"userId"`
+		ScheduledFor time.Time `json:"scheduledFor"`
+	}
+
+	AccountDeletionCancelled struct {
+		UserID uuid.UUID `json:"userId"`
+	}
+
+	UserDeleted struct {
+		UserID uuid.UUID `json:"userId"`
+	}
+
+	EmailVerified struct {
+		UserID uuid.UUID `json:"userId"`
+		Email  string    `json:"email"`
+	}
+
+	PasswordReset struct {
+		UserID uuid.UUID `json:"userId"`
+	}
+
+	PasswordChanged struct {
+		UserID uuid.UUID `json:"userId"`
+	}
+
+	TwoFactorEnabled struct {
+		UserID uuid.UUID `json:"userId"`
+	}
+
+	TwoFactorDisabled struct {
+		UserID uuid.UUID `json:"userId"`
+	}
+
+	IdentityLinked struct {
+		UserID   uuid.UUID `json:"userId"`
+		Provider string    `json:"provider"`
+	}
+
+	IdentityUnlinked struct {
+		UserID   uuid.UUID `json:"userId"`
+		Provider string    `json:"provider"`
+	}
+
+	UserRoleChanged struct {
+		UserID  uuid.UUID  `json:"userId"`
+		OldRole string     `json:"oldRole"`
+		NewRole string     `json:"newRole"`
+		ActorID *uuid.UUID `json:"actorId"`
+	}
+
+	UserSuspended struct {
+		UserID uuid.UUID `json:"userId"`
+		Reason string    `json:"reason"`
+	}
+
+	UserUnsuspended struct {
+		UserID uuid.UUID `json:"userId"`
+	}
+
+	// Kind is "block" or "mute"
+	UserBlocked struct {
+		BlockerID uuid.UUID `json:"blockerId"`
+		BlockedID uuid.UUID `json:"blockedId"`
+		Kind      string    `json:"kind"`
+	}
+
+	UserUnblocked struct {
+		BlockerID uuid.UUID `json:"blockerId"`
+		BlockedID uuid.UUID `json:"blockedId"`
+		Kind      string    `json:"kind"`
+	}
+
+	// ReleaseAt is set for scheduled tracks, MusicReleased follows at that
+	// time
+	MusicUploaded struct {
+		MusicID    string     `json:"musicId"`
+		ArtistID   uuid.UUID  `json:"artistId"`
+		Title      string     `json:"title"`
+		Visibility string     `json:"visibility"`
+		ReleaseAt  *time.Time `json:"releaseAt,omitempty"`
+	}
+
+	MusicReleased struct {
+		MusicID    string    `json:"musicId"`
+		ArtistID   uuid.UUID `json:"artistId"`
+		Title      string    `json:"title"`
+		Visibility string    `json:"visibility"`
+	}
+
+	MusicLiked struct {
+		MusicID    string    `json:"musicId"`
+		ArtistID   uuid.UUID `json:"artistId"`
+		UserID     uuid.UUID `json:"userId"`
+		Visibility string    `json:"visibility"`
+	}
+
+	MusicVisibilityChanged struct {
+		MusicID    string    `json:"musicId"`
+		ArtistID   uuid.UUID `json:"artistId"`
+		Title      string    `json:"title"`
+		Visibility string    `json:"visibility"`
+		Previous   string    `json:"previous"`
+	}
+
+	MusicDeleted struct {
+		MusicID  string `json:"musicId"`
+		ArtistID string `json:"artistId"`
+	}
+
+	MusicReposted struct {
+		RepostID string    `json:"repostId"`
+		MusicID  string    `json:"musicId"`
+		ArtistID uuid.UUID `json:"artistId"`
+		UserID   uuid.UUID `json:"userId"`
+		Caption  string    `json:"caption"`
+	}
+
+	MusicUnreposted struct {
+		RepostID string    `json:"repostId"`
+		MusicID  string    `json:"musicId"`
+		UserID   uuid.UUID `json:"userId"`
+	}
+
+	MusicCommented struct {
+		CommentID      string     `json:"commentId"`
+		MusicID        string     `json:"musicId"`
+		ArtistID       uuid.UUID  `json:"artistId"`
+		AuthorID       uuid.UUID  `json:"authorId"`
+		ParentAuthorID *uuid.UUID `json:"parentAuthorId"`
+	}
+
+	MusicHidden struct {
+		MusicID  string    `json:"musicId"`
+		ArtistID uuid.UUID `json:"artistId"`
+		Reason   string    `json:"reason"`
+	}
+
+	MusicUnhidden struct {
+		MusicID  string    `json:"musicId"`
+		ArtistID uuid.UUID `json:"artistId"`
+	}
+
+	ModerationCaseResolved struct {
+		CaseID   string    `json:"caseId"`
+		MusicID  string    `json:"musicId"`
+		ArtistID uuid.UUID `json:"artistId"`
+		Action   string    `json:"action"`
+	}
+
+	// Strikes counts the actioned takedowns of the artist, this one included
+	TakedownActioned struct {
+		TakedownID string    `json:"takedownId"`
+		MusicIDs   []string  `json:"musicIds"`
+		ArtistID   uuid.UUID `json:"artistId"`
+		Strikes    int       `json:"strikes"`
+	}
+
+	TakedownReinstated struct {
+		TakedownID string    `json:"takedownId"`
+		MusicIDs   []string  `json:"musicIds"`
+		ArtistID   uuid.UUID `json:"artistId"`
+	}
+
+	EmailChanged struct {
+		UserID   uuid.UUID `json:"userId"`
+		OldEmail string    `json:"oldEmail"`
+		NewEmail string    `json:"newEmail"`
+	}
+)
+
+func (e *UserRegistered) EventName() string   { return UserRegisteredEvent }
+func (e *UserRegistered) AggregateID() string { return e.UserID.String() }
+
+func (e *UserFollowed) EventName() string   { return UserFollowedEvent }
+func (e *UserFollowed) AggregateID() string { return e.FolloweeID.String() }
+
+func (e *UserUnfollowed) EventName() string   { return UserUnfollowedEvent }
+func (e *UserUnfollowed) AggregateID() string { return e.FolloweeID.String() }
+
+func (e *FollowRequested) EventName() string   { return FollowRequestedEvent }
+func (e *FollowRequested) AggregateID() string { return e.TargetID.String() }
+
+func (e *AccountDeletionRequested) EventName() string   { return AccountDeletionRequestedEvent }
+func (e *AccountDeletionRequested) AggregateID() string { return e.UserID.String() }
+
+func (e *AccountDeletionCancelled) EventName() string   { return AccountDeletionCancelledEvent }
+func (e *AccountDeletionCancelled) AggregateID() string { return e.UserID.String() }
+
+func (e *UserDeleted) EventName() string   { return UserDeletedEvent }
+func (e *UserDeleted) AggregateID() string { return e.UserID.String() }
+
+func (e *EmailVerified) EventName() string   { return EmailVerifiedEvent }
+func (e *EmailVerified) AggregateID() string { return e.UserID.String() }
+
+func (e *PasswordReset) EventName() string   { return PasswordResetEvent }
+func (e *PasswordReset) AggregateID() string { return e.UserID.String() }
+
+func (e *PasswordChanged) EventName() string   { return PasswordChangedEvent }
+func (e *PasswordChanged) AggregateID() string { return e.UserID.String() }
+
+func (e *EmailChanged) EventName() string   { return EmailChangedEvent }
+func (e *EmailChanged) AggregateID() string { return e.UserID.String() }
+
+func (e *TwoFactorEnabled) EventName() string   { return TwoFactorEnabledEvent }
+func (e *TwoFactorEnabled) AggregateID() string { return e.UserID.String() }
+
+func (e *TwoFactorDisabled) EventName() string   { return TwoFactorDisabledEvent }
+func (e *TwoFactorDisabled) AggregateID() string { return e.UserID.String() }
+
+func (e *IdentityLinked) EventName() string   { return IdentityLinkedEvent }
+func (e *IdentityLinked) AggregateID() string { return e.UserID.String() }
+
+func (e *IdentityUnlinked) EventName() string   { return IdentityUnlinkedEvent }
+func (e *IdentityUnlinked) AggregateID() string { return e.UserID.String() }
+
+func (e *UserRoleChanged) EventName() string   { return UserRoleChangedEvent }
+func (e *UserRoleChanged) AggregateID() string { return e.UserID.String() }
+
+func (e *UserSuspended) EventName() string   { return UserSuspendedEvent }
+func (e *UserSuspended) AggregateID() string { return e.UserID.String() }
+
+func (e *UserUnsuspended) EventName() string   { return UserUnsuspendedEvent }
+func (e *UserUnsuspended) AggregateID() string { return e.UserID.String() }
+
+func (e *UserBlocked) EventName() string   { return UserBlockedEvent }
+func (e *UserBlocked) AggregateID() string { return e.BlockerID.String() }
+
+func (e *UserUnblocked) EventName() string   { return UserUnblockedEvent }
+func (e *UserUnblocked) AggregateID() string { return e.BlockerID.String() }
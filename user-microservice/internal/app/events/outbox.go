@@ -0,0 +1,63 @@
+package events
+
+import (
+	"encoding/json"
+	"music-sharing/user-microservice/internal/app/models"
+	"music-sharing/user-microservice/internal/interfaces"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Envelope is the message published to the broker for every outbox event. The
+// events forwarded by music-microservice have mongo object ids
+type Envelope struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	AggregateID string          `json:"aggregateId"`
+	OccurredAt  time.Time       `json:"occurredAt"`
+	Payload     json.RawMessage `json:"payload"`
+}
+
+// Record writes the event to the outbox using the given transaction, so it is
+// only persisted if the state change it describes is committed as well
+func Record(tx *gorm.DB, event interfaces.DomainEvent) error {
+
+	payload, err := json.Marshal(event)
+
+	if err != nil {
+		return err
+	}
+
+	outboxEvent := &models.OutboxEvent{
+		ID:          uuid.New(),
+		Name:        event.EventName(),
+		AggregateID: event.AggregateID(),
+		Payload:     string(payload),
+		OccurredAt:  time.Now().UTC(),
+	}
+
+	return tx.Create(outboxEvent).Error
+}
+
+func NewEnvelope(outboxEvent *models.OutboxEvent) *Envelope {
+	return &Envelope{
+		ID:          outboxEvent.ID.String(),
+		Name:        outboxEvent.Name,
+		AggregateID: outboxEvent.AggregateID,
+		OccurredAt:  outboxEvent.OccurredAt,
+		Payload:     json.RawMessage(outboxEvent.Payload),
+	}
+}
+
+func ParseEnvelope(message []byte) (*Envelope, error) {
+
+	envelope := &Envelope{}
+
+	if err := json.Unmarshal(message, envelope); err != nil {
+		return nil, err
+	}
+
+	return envelope, nil
+}
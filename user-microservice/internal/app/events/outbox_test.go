@@ -0,0 +1,95 @@
+package events
+
+import (
+	"encoding/json"
+	"music-sharing/user-microservice/internal/app/models"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// dryRunDB builds the statements without a database and hands every created
+// row to inserted
+func dryRunDB(t *testing.T, inserted func(tx *gorm.DB)) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "test:test@tcp(127.0.0.1:3306)/test", SkipInitializeWithVersion: true}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	db.Callback().Create().After("gorm:create").Register("test:inserted", inserted)
+
+	return db
+}
+
+func TestRecordWritesTheEventToTheOutbox(t *testing.T) {
+	event := &UserFollowed{FollowerID: uuid.New(), FolloweeID: uuid.New()}
+
+	var statement string
+	var outboxEvent *models.OutboxEvent
+
+	db := dryRunDB(t, func(tx *gorm.DB) {
+		statement = tx.Statement.SQL.String()
+		outboxEvent, _ = tx.Statement.Dest.(*models.OutboxEvent)
+	})
+
+	if err := Record(db, event); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.HasPrefix(statement, "INSERT INTO `outbox_events`") {
+		t.Fatalf("statement = %s, want an insert into the outbox", statement)
+	}
+
+	if outboxEvent == nil {
+		t.Fatal("no outbox event was inserted")
+	}
+
+	if outboxEvent.ID == uuid.Nil || outboxEvent.Name != UserFollowedEvent || outboxEvent.AggregateID != event.FolloweeID.String() {
+		t.Fatalf("inserted %+v, want the UserFollowed event of the followee", outboxEvent)
+	}
+
+	if outboxEvent.PublishedAt != nil || outboxEvent.OccurredAt.IsZero() {
+		t.Fatalf("inserted %+v, want a pending event", outboxEvent)
+	}
+
+	decoded := &UserFollowed{}
+
+	if err := json.Unmarshal([]byte(outboxEvent.Payload), decoded); err != nil || *decoded != *event {
+		t.Fatalf("payload = %s, want the event", outboxEvent.Payload)
+	}
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	outboxEvent := &models.OutboxEvent{
+		ID:          uuid.New(),
+		Name:        UserRegisteredEvent,
+		AggregateID: "user-1",
+		Payload:     `{"userId":"user-1"}`,
+	}
+
+	message, err := json.Marshal(NewEnvelope(outboxEvent))
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	envelope, err := ParseEnvelope(message)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if envelope.ID != outboxEvent.ID.String() || envelope.Name != UserRegisteredEvent || envelope.AggregateID != "user-1" || string(envelope.Payload) != outboxEvent.Payload {
+		t.Fatalf("parsed %+v, want the envelope of %+v", envelope, outboxEvent)
+	}
+
+	if _, err := ParseEnvelope([]byte("not json")); err == nil {
+		t.Fatal("a message that is not json was parsed")
+	}
+}
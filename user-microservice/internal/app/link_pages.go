@@ -0,0 +1,80 @@
+package app
+
+import (
+	"bytes"
+	"html/template"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The links of the emails are opened in a browser, these pages answer them
+// instead of the json the api clients get
+
+var resetPasswordPage = template.Must(template.New("resetPassword").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Reset your password</title></head>
+<body>
+<h1>Reset your password</h1>
+<form id="reset">
+<input type="password" name="password" minlength="8" placeholder="New password" required>
+<button type="submit">Reset</button>
+</form>
+<p id="result"></p>
+<script>
+document.getElementById("reset").addEventListener("submit", async (event) => {
+	event.preventDefault();
+	const res = await fetch({{.Action}}, {
+		method: "POST",
+		headers: { "Content-Type": "application/json" },
+		body: JSON.stringify({ token: {{.Token}}, password: event.target.password.value }),
+	});
+	const body = await res.json();
+	document.getElementById("result").textContent = res.ok ? "Your password is reset, you can log in with it." : body.detail;
+});
+</script>
+</body>
+</html>
+`))
+
+// The link only shows a button, the action runs on the POST it sends. Mail
+// scanners and link prefetchers open the link without pressing it
+var confirmLinkPage = template.Must(template.New("confirmLink").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+<form id="confirm">
+<button type="submit">{{.Button}}</button>
+</form>
+<p id="result"></p>
+<script>
+document.getElementById("confirm").addEventListener("submit", async (event) => {
+	event.preventDefault();
+	const res = await fetch({{.Action}}, {
+		method: "POST",
+		headers: { "Content-Type": "application/json" },
+		body: JSON.stringify({ token: {{.Token}} }),
+	});
+	const body = await res.json();
+	document.getElementById("result").textContent = res.ok ? {{.Done}} : body.detail;
+	if (res.ok) {
+		event.target.remove();
+	}
+});
+</script>
+</body>
+</html>
+`))
+
+func renderPage(c *gin.Context, status int, page *template.Template, data gin.H) {
+
+	buf := &bytes.Buffer{}
+
+	if err := page.Execute(buf, data); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
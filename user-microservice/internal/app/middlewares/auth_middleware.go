@@ -1,8 +1,7 @@
 package middlewares
 
 import (
-	"errors"
-	"fmt"
+	"music-sharing/shared/apperrors"
 	"music-sharing/user-microservice/internal/app/queries"
 	config "music-sharing/user-microservice/pkg"
 	"strings"
@@ -10,30 +9,83 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// Authenticates the clients that can't set headers, like EventSource, with
+// the ticket of ?ticket=. The ?token= of a session is still read for the
+// clients made before the tickets, otherwise it is AuthMiddleware
+func StreamAuthMiddleware(c *gin.Context) {
+
+	ticket := c.Query("ticket")
+
+	if len(ticket) == 0 {
+		if token := c.Query("token"); len(token) != 0 && len(c.Request.Header.Get("Authorization")) == 0 {
+			c.Request.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		AuthMiddleware(c)
+		return
+	}
+
+	resp, err := config.Container.QueryBus.Send(&queries.GetUserProfileByStreamTicketQuery{
+		Ticket: ticket,
+	})
+
+	if err != nil {
+		c.Error(err)
+		c.Abort()
+		return
+	}
+
+	c.Set("user", resp.(*queries.GetUserProfileByTokenQueryResponse).User)
+
+	c.Next()
+}
+
 func AuthMiddleware(c *gin.Context) {
 
 	queryBus := config.Container.QueryBus
 	header := c.Request.Header.Get("Authorization")
 
-	if len(header) == 0 {
-		c.Error(errors.New("no authorization header"))
+	parts := strings.Split(header, " ")
+
+	if len(header) == 0 || len(parts) != 2 {
+		c.Error(apperrors.Unauthorized("no authorization header"))
+		c.Abort()
 		return
 	}
 
-	tokenString := strings.Split(header, " ")[1]
-
 	resp, err := queryBus.Send(&queries.GetUserProfileByTokenQuery{
-		Token: tokenString,
+		Token: parts[1],
 	})
 
 	if err != nil {
 		c.Error(err)
+		c.Abort()
 		return
 	}
 
 	user := resp.(*queries.GetUserProfileByTokenQueryResponse).User
-	fmt.Println(user)
 	c.Set("user", user)
 
 	c.Next()
 }
+
+// Sets the user when the request has a valid token and lets anonymous
+// requests through
+func OptionalAuthMiddleware(c *gin.Context) {
+
+	queryBus := config.Container.QueryBus
+	header := c.Request.Header.Get("Authorization")
+	parts := strings.Split(header, " ")
+
+	if len(parts) == 2 {
+		resp, err := queryBus.Send(&queries.GetUserProfileByTokenQuery{
+			Token: parts[1],
+		})
+
+		if err == nil {
+			c.Set("user", resp.(*queries.GetUserProfileByTokenQueryResponse).User)
+		}
+	}
+
+	c.Next()
+}
@@ -1,22 +1,26 @@
 package middlewares
 
 import (
-	"net/http"
+	"errors"
+	"music-sharing/shared/apperrors"
+	"strings"
 
-	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-func ErrorHandlerMiddleware(c *gin.Context) {
+// Answers a failed request with the problem of its first error, later ones
+// are usually a consequence of it. Internal errors are logged and their
+// details are never sent
+var ErrorHandlerMiddleware = apperrors.Handler(classifyError)
 
-	c.Next()
-
-	errors := c.Errors
-
-	if len(errors) > 0 {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"errors":  errors.Errors(),
-			"success": false,
-		})
+func classifyError(err error) *apperrors.Error {
+	switch {
+	// uuid.Parse has no exported errors to compare with
+	case strings.HasPrefix(err.Error(), "invalid UUID"):
+		return apperrors.Validation("invalid id")
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return apperrors.NotFound("not found")
 	}
 
+	return nil
 }
@@ -0,0 +1,81 @@
+package middlewares
+
+import (
+	"music-sharing/shared/idempotency"
+	"music-sharing/user-microservice/internal/app/models"
+	config "music-sharing/user-microservice/pkg"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Replays the stored response of a mutating request retried with the same
+// Idempotency-Key header, the keys are stored in the idempotency_keys table
+var IdempotencyMiddleware = idempotency.Middleware(&idempotencyStore{})
+
+type idempotencyStore struct{}
+
+func (s *idempotencyStore) Claim(record *idempotency.Record) (*idempotency.Record, error) {
+
+	db := config.Container.Database
+	now := time.Now().UTC()
+
+	res := db.Where("idempotency_key = ? AND scope = ?", record.Key, record.Scope).
+		Where("expires_at < ? OR (status = 0 AND created_at < ?)", now, now.Add(-idempotency.Lease)).
+		Delete(&models.IdempotencyKey{})
+
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	res = db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.IdempotencyKey{
+		Key:         record.Key,
+		Scope:       record.Scope,
+		RequestHash: record.RequestHash,
+		CreatedAt:   record.CreatedAt,
+		ExpiresAt:   record.ExpiresAt,
+	})
+
+	if res.Error != nil || res.RowsAffected != 0 {
+		return nil, res.Error
+	}
+
+	stored := models.IdempotencyKey{}
+
+	res = db.Where("idempotency_key = ? AND scope = ?", record.Key, record.Scope).First(&stored)
+
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	return &idempotency.Record{
+		Key:         stored.Key,
+		Scope:       stored.Scope,
+		RequestHash: stored.RequestHash,
+		Status:      stored.Status,
+		ContentType: stored.ContentType,
+		Body:        stored.Body,
+		Withheld:    stored.Withheld,
+		CreatedAt:   stored.CreatedAt,
+		ExpiresAt:   stored.ExpiresAt,
+	}, nil
+}
+
+func (s *idempotencyStore) Complete(record *idempotency.Record) error {
+	return s.scoped(record).Updates(map[string]interface{}{
+		"status":       record.Status,
+		"content_type": record.ContentType,
+		"body":         record.Body,
+		"withheld":     record.Withheld,
+	}).Error
+}
+
+func (s *idempotencyStore) Release(record *idempotency.Record) error {
+	return s.scoped(record).Delete(&models.IdempotencyKey{}).Error
+}
+
+func (s *idempotencyStore) scoped(record *idempotency.Record) *gorm.DB {
+	return config.Container.Database.Model(&models.IdempotencyKey{}).
+		Where("idempotency_key = ? AND scope = ?", record.Key, record.Scope)
+}
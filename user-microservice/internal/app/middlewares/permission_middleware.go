@@ -0,0 +1,26 @@
+package middlewares
+
+import (
+	"music-sharing/shared/apperrors"
+	"music-sharing/shared/permissions"
+	"music-sharing/user-microservice/internal/app/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Must run after AuthMiddleware, the role is read from the stored user so a
+// role change applies to user-microservice right away
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		user, exists := c.Get("user")
+
+		if !exists || !permissions.HasPermission(user.(*models.User).Role, permission) {
+			c.Error(apperrors.Forbidden("you are not authorized"))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AccountDeletionPending    = "pending"
+	AccountDeletionInProgress = "in_progress"
+	AccountDeletionCompleted  = "completed"
+	AccountDeletionCancelled  = "cancelled"
+
+	AccountDeletionStepMusic   = "music"
+	AccountDeletionStepFollows = "follows"
+	AccountDeletionStepAccount = "account"
+)
+
+// The cascade runs the steps in this order, the account itself is removed last
+var AccountDeletionSteps = []string{
+	AccountDeletionStepMusic,
+	AccountDeletionStepFollows,
+	AccountDeletionStepAccount,
+}
+
+type AccountDeletion struct {
+	ID               uuid.UUID  `json:"id" gorm:"primaryKey"`
+	UserID           uuid.UUID  `json:"userId" gorm:"uniqueIndex"`
+	Status           string     `json:"status" gorm:"index"`
+	RequestedAt      time.Time  `json:"requestedAt"`
+	ScheduledFor     time.Time  `json:"scheduledFor" gorm:"index"`
+	StartedAt        *time.Time `json:"startedAt"`
+	CompletedAt      *time.Time `json:"completedAt"`
+	CompletedSteps   string     `json:"completedSteps"`
+	Progress         uint       `json:"progress"`
+	FollowsRemoved   int64      `json:"followsRemoved"`
+	LikesRemoved     int64      `json:"likesRemoved"`
+	ListensRemoved   int64      `json:"listensRemoved"`
+	TracksRemoved    int64      `json:"tracksRemoved"`
+	TracksAnonymised int64      `json:"tracksAnonymised"`
+	Attempts         uint       `json:"attempts"`
+	// kept for the operators, the errors of internalapi hold internal urls
+	LastError   string     `json:"-"`
+	LockedUntil *time.Time `json:"-"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+func (d *AccountDeletion) HasCompletedStep(step string) bool {
+	for _, completed := range strings.Split(d.CompletedSteps, ",") {
+		if completed == step {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d *AccountDeletion) CompleteStep(step string) {
+	if d.HasCompletedStep(step) {
+		return
+	}
+
+	if len(d.CompletedSteps) == 0 {
+		d.CompletedSteps = step
+	} else {
+		d.CompletedSteps += "," + step
+	}
+
+	d.Progress = uint(len(strings.Split(d.CompletedSteps, ",")) * 100 / len(AccountDeletionSteps))
+}
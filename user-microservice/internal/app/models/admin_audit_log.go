@@ -0,0 +1,30 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AdminAuditLog records every administrative action. ActorID is nil for the
+// actions made through the internal api. Entries can't be updated or deleted
+type AdminAuditLog struct {
+	ID        uuid.UUID  `json:"id" gorm:"primaryKey"`
+	ActorID   *uuid.UUID `json:"actorId" gorm:"index"`
+	Action    string     `json:"action" gorm:"index;size:64"`
+	TargetID  uuid.UUID  `json:"targetId" gorm:"index"`
+	Diff      string     `json:"diff" gorm:"type:text"`
+	CreatedAt time.Time  `json:"createdAt" gorm:"index"`
+}
+
+var ErrImmutableAuditLog = errors.New("audit log entries are immutable")
+
+func (l *AdminAuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrImmutableAuditLog
+}
+
+func (l *AdminAuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrImmutableAuditLog
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// hides both users from each other and removes their follow edges
+	BlockKindBlock = "block"
+	// only hides the muted user from the feed of the muter
+	BlockKindMute = "mute"
+)
+
+// A user has at most one block or mute on another user, blocking a muted
+// user turns the mute into a block
+type Block struct {
+	BlockerID uuid.UUID `json:"blockerId" gorm:"primaryKey"`
+	BlockedID uuid.UUID `json:"blockedId" gorm:"primaryKey;index"`
+	Kind      string    `json:"kind" gorm:"size:16"`
+	CreatedAt time.Time `json:"createdAt"`
+}
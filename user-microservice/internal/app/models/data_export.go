@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DataExportPending    = "pending"
+	DataExportProcessing = "processing"
+	DataExportReady      = "ready"
+	DataExportFailed     = "failed"
+	DataExportExpired    = "expired"
+)
+
+type DataExport struct {
+	ID          uuid.UUID  `json:"id" gorm:"primaryKey"`
+	UserID      uuid.UUID  `json:"userId" gorm:"index"`
+	Status      string     `json:"status" gorm:"index"`
+	RequestedAt time.Time  `json:"requestedAt"`
+	CompletedAt *time.Time `json:"completedAt"`
+	ExpiresAt   *time.Time `json:"expiresAt" gorm:"index"`
+	FilePath    string     `json:"-"`
+	Error       string     `json:"error"`
+	Attempts    uint       `json:"-"`
+	LockedUntil *time.Time `json:"-"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ActivityUploaded = "uploaded"
+	ActivityLiked    = "liked"
+	ActivityFollowed = "followed"
+	ActivityReposted = "reposted"
+	ActivityReleased = "released"
+)
+
+// Activity is something a user did that shows up in the feed of their
+// followers. The id is auto incremented so it doubles as the feed cursor.
+// Activities of very popular users are not fanned out and are pulled from
+// this table when a follower reads their feed instead
+type Activity struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	EventID   string    `json:"-" gorm:"uniqueIndex;size:64"`
+	ActorID   uuid.UUID `json:"actorId" gorm:"index"`
+	Verb      string    `json:"verb" gorm:"size:32"`
+	ObjectID  string    `json:"objectId" gorm:"index;size:64"`
+	Title     string    `json:"title,omitempty"`
+	Caption   string    `json:"caption,omitempty"`
+	FannedOut bool      `json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// FeedItem is an activity written to the feed of one follower
+type FeedItem struct {
+	OwnerID    uuid.UUID `gorm:"primaryKey"`
+	ActivityID uint      `gorm:"primaryKey;index"`
+}
+
+// FeedState keeps the id of the last activity the user has seen
+type FeedState struct {
+	UserID         uuid.UUID `json:"userId" gorm:"primaryKey"`
+	SeenActivityID uint      `json:"seenActivityId"`
+	SeenAt         time.Time `json:"seenAt"`
+}
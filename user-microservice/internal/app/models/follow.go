@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Follow struct {
+	FollowerID uuid.UUID `json:"followerId" gorm:"primaryKey"`
+	FolloweeID uuid.UUID `json:"followeeId" gorm:"primaryKey;index"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// A follow of a private account waiting for its owner to approve it
+type FollowRequest struct {
+	RequesterID uuid.UUID `json:"requesterId" gorm:"primaryKey"`
+	TargetID    uuid.UUID `json:"targetId" gorm:"primaryKey;index"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
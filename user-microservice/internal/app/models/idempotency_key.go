@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// IdempotencyKey is the stored outcome of a mutating request sent with an
+// Idempotency-Key header. Keys are scoped to the caller that sent them and
+// Status is 0 while the first request is still running
+type IdempotencyKey struct {
+	Key         string    `gorm:"column:idempotency_key;primaryKey;size:255"`
+	Scope       string    `gorm:"primaryKey;size:64"`
+	RequestHash string    `gorm:"size:64"`
+	Status      int       `gorm:"index"`
+	ContentType string    `gorm:"size:255"`
+	Body        []byte    `gorm:"type:longblob"`
+	Withheld    bool      `gorm:"not null;default:false"`
+	CreatedAt   time.Time `gorm:"index"`
+	ExpiresAt   time.Time `gorm:"index"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	LoginAttemptUnknownEmail    = "unknown_email"
+	LoginAttemptWrongPassword   = "wrong_password"
+	LoginAttemptLockedOut       = "locked_out"
+	LoginAttemptWrongMFACode    = "wrong_mfa_code"
+	LoginAttemptSucceeded       = "succeeded"
+	LoginAttemptUnlockedByAdmin = "unlocked_by_admin"
+)
+
+// LoginAttempt is the audit log of failed logins. Successful logins and admin
+// unlocks are recorded too since they reset the failure count of the account
+type LoginAttempt struct {
+	ID        uuid.UUID  `json:"id" gorm:"primaryKey"`
+	Email     string     `json:"email" gorm:"index"`
+	UserID    *uuid.UUID `json:"userId" gorm:"index"`
+	IP        string     `json:"ip" gorm:"index"`
+	Failed    bool       `json:"failed"`
+	Reason    string     `json:"reason"`
+	CreatedAt time.Time  `json:"createdAt" gorm:"index"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	NotificationLike          = "like"
+	NotificationFollow        = "follow"
+	NotificationFollowRequest = "follow_request"
+	NotificationComment       = "comment"
+	NotificationRepost        = "repost"
+	NotificationModeration    = "moderation"
+)
+
+var NotificationTypes = []string{
+	NotificationLike,
+	NotificationFollow,
+	NotificationFollowRequest,
+	NotificationComment,
+	NotificationRepost,
+	NotificationModeration,
+}
+
+// Notification is created from a domain event, EventID keeps redelivered
+// events from notifying twice. The id is auto incremented and used as cursor
+type Notification struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uuid.UUID  `json:"userId" gorm:"uniqueIndex:idx_notification_event;index:idx_notification_user"`
+	EventID   string     `json:"-" gorm:"uniqueIndex:idx_notification_event;size:64"`
+	Type      string     `json:"type" gorm:"size:32"`
+	ActorID   *uuid.UUID `json:"actorId" gorm:"index"`
+	ObjectID  string     `json:"objectId" gorm:"size:64"`
+	Message   string     `json:"message"`
+	ReadAt    *time.Time `json:"readAt" gorm:"index:idx_notification_user"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// A missing preference means the type is enabled
+type NotificationPreference struct {
+	UserID  uuid.UUID `json:"userId" gorm:"primaryKey"`
+	Type    string    `json:"type" gorm:"primaryKey;size:32"`
+	Enabled bool      `json:"enabled"`
+}
+
+// SentNotice records a notice mailed on behalf of another microservice under
+// the id of the event that asked for it, so a redelivered event mails once
+type SentNotice struct {
+	UserID    uuid.UUID `gorm:"primaryKey"`
+	EventID   string    `gorm:"primaryKey;size:64"`
+	CreatedAt time.Time
+}
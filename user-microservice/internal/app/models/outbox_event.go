@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// An outbox event is dead lettered once the relay gave up on it after it failed
+// every attempt, it is never published again
+type OutboxEvent struct {
+	ID             uuid.UUID  `json:"id" gorm:"primaryKey"`
+	Name           string     `json:"name" gorm:"index"`
+	AggregateID    string     `json:"aggregateId" gorm:"index"`
+	Payload        string     `json:"payload" gorm:"type:text"`
+	OccurredAt     time.Time  `json:"occurredAt" gorm:"index"`
+	PublishedAt    *time.Time `json:"publishedAt" gorm:"index"`
+	LockedUntil    *time.Time `json:"lockedUntil"`
+	Attempts       uint       `json:"attempts"`
+	LastError      string     `json:"lastError"`
+	DeadLetteredAt *time.Time `json:"deadLetteredAt" gorm:"index"`
+}
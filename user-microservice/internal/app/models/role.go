@@ -0,0 +1,10 @@
+package models
+
+import "music-sharing/shared/permissions"
+
+const (
+	RoleUser      = permissions.RoleUser
+	RoleArtist    = permissions.RoleArtist
+	RoleModerator = permissions.RoleModerator
+	RoleAdmin     = permissions.RoleAdmin
+)
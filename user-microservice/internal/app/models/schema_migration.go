@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// SchemaMigration records a data migration that ran, AutoMigrate only creates
+// the tables and the columns
+type SchemaMigration struct {
+	Name  string    `json:"name" gorm:"primaryKey;size:191"`
+	RanAt time.Time `json:"ranAt"`
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -11,9 +13,23 @@ type User struct {
 	FullName       string    `json:"fullName"`
 	Gender         string    `json:"gender"`
 	Email          string    `json:"email"`
-	HashedPassword string    `json:"hashedPassword"`
-	Followers      uint      `json:"followers"`
-	Followings     uint      `json:"followings"`
-	ProfileURL     string    `json:"profileUrl"`
-	IsPrivate      bool      `json:"isPrivate"`
+	HashedPassword string    `json:"-"`
+	// first password of a passwordless account until the emailed link is opened
+	PendingHashedPassword string     `json:"-"`
+	Followers             uint       `json:"followers"`
+	Followings            uint       `json:"followings"`
+	ProfileURL            string     `json:"profileUrl"`
+	IsPrivate             bool       `json:"isPrivate"`
+	Role                  string     `json:"role" gorm:"size:32;default:user"`
+	EmailVerified         bool       `json:"emailVerified"`
+	EmailVerifiedAt       *time.Time `json:"emailVerifiedAt"`
+	PendingEmail          string     `json:"-"`
+	TokenVersion          uint       `json:"-"`
+	TOTPEnabled           bool       `json:"totpEnabled"`
+	TOTPSecret            string     `json:"-"`
+	TOTPLastStep          int64      `json:"-"`
+	SuspendedAt           *time.Time `json:"suspendedAt"`
+	SuspendReason         string     `json:"suspendReason"`
+	// bumped by every edit of the profile and sent as its ETag
+	Version uint `json:"version" gorm:"not null;default:0"`
 }
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserIdentity links an account of an external identity provider to a user
+type UserIdentity struct {
+	ID        uuid.UUID `json:"id" gorm:"primaryKey"`
+	UserID    uuid.UUID `json:"userId" gorm:"index"`
+	Provider  string    `json:"provider" gorm:"uniqueIndex:idx_provider_subject;size:64"`
+	Subject   string    `json:"subject" gorm:"uniqueIndex:idx_provider_subject;size:255"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// OAuthState is the server side state of an authorization code flow, it is
+// deleted as soon as the callback uses it
+type OAuthState struct {
+	State        string     `gorm:"primaryKey;size:64"`
+	Provider     string     `gorm:"size:64"`
+	Nonce        string     `gorm:"size:64"`
+	CodeVerifier string     `gorm:"size:128"`
+	LinkUserID   *uuid.UUID `gorm:"index"`
+	ExpiresAt    time.Time  `gorm:"index"`
+}
@@ -0,0 +1,162 @@
+package queries
+
+import (
+	"music-sharing/user-microservice/internal/app/models"
+	config "music-sharing/user-microservice/pkg"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAdminPageSize = 20
+	maxAdminPageSize     = 100
+)
+
+type (
+	ListUsersQueryResponse struct {
+		Users    []models.User `json:"users"`
+		Total    int64         `json:"total"`
+		Page     int           `json:"page"`
+		PageSize int           `json:"pageSize"`
+	}
+
+	// Search matches the full name or the email. Suspended and Verified are
+	// ignored when nil
+	ListUsersQuery struct {
+		Search    string
+		Role      string
+		Suspended *bool
+		Verified  *bool
+		Page      int
+		PageSize  int
+	}
+
+	GetAuditLogQueryResponse struct {
+		Entries  []models.AdminAuditLog `json:"entries"`
+		Total    int64                  `json:"total"`
+		Page     int                    `json:"page"`
+		PageSize int                    `json:"pageSize"`
+	}
+
+	GetAuditLogQuery struct {
+		ActorID  uuid.UUID
+		TargetID uuid.UUID
+		Action   string
+		Page     int
+		PageSize int
+	}
+)
+
+func pagination(page int, pageSize int) (int, int) {
+
+	if page < 1 {
+		page = 1
+	}
+
+	if pageSize < 1 {
+		pageSize = defaultAdminPageSize
+	}
+
+	if pageSize > maxAdminPageSize {
+		pageSize = maxAdminPageSize
+	}
+
+	return page, pageSize
+}
+
+func (c *ListUsersQuery) Handle() (interface{}, error) {
+
+	db := config.Container.Database
+
+	page, pageSize := pagination(c.Page, c.PageSize)
+	query := db.Model(&models.User{})
+
+	if c.Search != "" {
+		like := "%" + c.Search + "%"
+		query = query.Where("full_name LIKE ? OR email LIKE ?", like, like)
+	}
+
+	if c.Role != "" {
+		query = query.Where("role = ?", c.Role)
+	}
+
+	if c.Suspended != nil {
+		if *c.Suspended {
+			query = query.Where("suspended_at IS NOT NULL")
+		} else {
+			query = query.Where("suspended_at IS NULL")
+		}
+	}
+
+	if c.Verified != nil {
+		query = query.Where("email_verified = ?", *c.Verified)
+	}
+
+	var total int64
+
+	if res := query.Count(&total); res.Error != nil {
+		return nil, res.Error
+	}
+
+	users := []models.User{}
+
+	res := query.Order("created_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&users)
+
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	resp := &ListUsersQueryResponse{
+		Users:    users,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}
+
+	return resp, nil
+
+}
+
+func (c *GetAuditLogQuery) Handle() (interface{}, error) {
+
+	db := config.Container.Database
+
+	page, pageSize := pagination(c.Page, c.PageSize)
+	query := db.Model(&models.AdminAuditLog{})
+
+	if c.ActorID != uuid.Nil {
+		query = query.Where("actor_id = ?", c.ActorID)
+	}
+
+	if c.TargetID != uuid.Nil {
+		query = query.Where("target_id = ?", c.TargetID)
+	}
+
+	if c.Action != "" {
+		query = query.Where("action = ?", c.Action)
+	}
+
+	var total int64
+
+	if res := query.Count(&total); res.Error != nil {
+		return nil, res.Error
+	}
+
+	entries := []models.AdminAuditLog{}
+
+	res := query.Order("created_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&entries)
+
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	resp := &GetAuditLogQueryResponse{
+		Entries:  entries,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}
+
+	return resp, nil
+
+}
@@ -0,0 +1,43 @@
+package queries
+
+import (
+	"music-sharing/shared/apperrors"
+	"music-sharing/user-microservice/internal/app/models"
+	config "music-sharing/user-microservice/pkg"
+
+	"github.com/google/uuid"
+)
+
+type (
+	GetAccountDeletionQueryResponse struct {
+		Deletion *models.AccountDeletion `json:"deletion"`
+	}
+
+	GetAccountDeletionQuery struct {
+		UserID uuid.UUID
+	}
+)
+
+func (c *GetAccountDeletionQuery) Handle() (interface{}, error) {
+
+	db := config.Container.Database
+
+	deletion := &models.AccountDeletion{}
+
+	res := db.Find(deletion, "user_id = ?", c.UserID)
+
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	if deletion.ID == uuid.Nil {
+		return nil, apperrors.NotFound("no account deletion was requested")
+	}
+
+	resp := &GetAccountDeletionQueryResponse{
+		Deletion: deletion,
+	}
+
+	return resp, nil
+
+}
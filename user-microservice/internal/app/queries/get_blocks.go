@@ -0,0 +1,77 @@
+package queries
+
+import (
+	"music-sharing/user-microservice/internal/app/models"
+	config "music-sharing/user-microservice/pkg"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type (
+	GetMyBlocksQueryResponse struct {
+		Blocks []models.Block `json:"blocks"`
+	}
+
+	// The blocks and mutes made by the user
+	GetMyBlocksQuery struct {
+		UserID uuid.UUID
+	}
+
+	// HiddenUserIDs are the users the user blocked or was blocked by, their
+	// content is hidden both ways. MutedUserIDs only apply to the user's feed
+	GetBlockListQueryResponse struct {
+		HiddenUserIDs []uuid.UUID `json:"hiddenUserIds"`
+		MutedUserIDs  []uuid.UUID `json:"mutedUserIds"`
+	}
+)
+
+func (c *GetMyBlocksQuery) Handle() (interface{}, error) {
+
+	db := config.Container.Database
+
+	blocks := []models.Block{}
+
+	res := db.Order("created_at DESC").Find(&blocks, "blocker_id = ?", c.UserID)
+
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	resp := &GetMyBlocksQueryResponse{
+		Blocks: blocks,
+	}
+
+	return resp, nil
+
+}
+
+func blockListOf(db *gorm.DB, userId uuid.UUID) (*GetBlockListQueryResponse, error) {
+
+	blocks := []models.Block{}
+
+	res := db.Find(&blocks, "blocker_id = ? OR (blocked_id = ? AND kind = ?)", userId, userId, models.BlockKindBlock)
+
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	resp := &GetBlockListQueryResponse{
+		HiddenUserIDs: []uuid.UUID{},
+		MutedUserIDs:  []uuid.UUID{},
+	}
+
+	for _, block := range blocks {
+		switch {
+		case block.BlockedID == userId:
+			resp.HiddenUserIDs = append(resp.HiddenUserIDs, block.BlockerID)
+		case block.Kind == models.BlockKindBlock:
+			resp.HiddenUserIDs = append(resp.HiddenUserIDs, block.BlockedID)
+		default:
+			resp.MutedUserIDs = append(resp.MutedUserIDs, block.BlockedID)
+		}
+	}
+
+	return resp, nil
+
+}
@@ -0,0 +1,72 @@
+package queries
+
+import (
+	"music-sharing/shared/apperrors"
+	"music-sharing/user-microservice/internal/app/models"
+	config "music-sharing/user-microservice/pkg"
+
+	"github.com/google/uuid"
+)
+
+type (
+	GetDataExportsQueryResponse struct {
+		Exports []models.DataExport `json:"exports"`
+	}
+
+	GetDataExportsQuery struct {
+		UserID uuid.UUID
+	}
+
+	GetDataExportQueryResponse struct {
+		Export *models.DataExport `json:"export"`
+	}
+
+	GetDataExportQuery struct {
+		ID     uuid.UUID
+		UserID uuid.UUID
+	}
+)
+
+func (c *GetDataExportsQuery) Handle() (interface{}, error) {
+
+	db := config.Container.Database
+
+	exports := []models.DataExport{}
+
+	res := db.Order("requested_at DESC").Find(&exports, "user_id = ?", c.UserID)
+
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	resp := &GetDataExportsQueryResponse{
+		Exports: exports,
+	}
+
+	return resp, nil
+
+}
+
+func (c *GetDataExportQuery) Handle() (interface{}, error) {
+
+	db := config.Container.Database
+
+	export := &models.DataExport{}
+
+	res := db.Find(export, "id = ? AND user_id = ?", c.ID, c.UserID)
+
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	if export.ID == uuid.Nil {
+		return nil, apperrors.NotFound("data export doesnt exist")
+	}
+
+	resp := &GetDataExportQueryResponse{
+		Export: export,
+	}
+
+	return resp, nil
+
+}
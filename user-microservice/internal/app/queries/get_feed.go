@@ -0,0 +1,106 @@
+package queries
+
+import (
+	"music-sharing/user-microservice/internal/app/models"
+	config "music-sharing/user-microservice/pkg"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultFeedLimit = 20
+	maxFeedLimit     = 100
+)
+
+type (
+	FeedEntry struct {
+		models.Activity
+		Seen bool `json:"seen"`
+	}
+
+	// NextCursor is 0 when there are no older entries
+	GetFeedQueryResponse struct {
+		Entries        []FeedEntry `json:"entries"`
+		NextCursor     uint        `json:"nextCursor"`
+		SeenActivityID uint        `json:"seenActivityId"`
+	}
+
+	// Entries older than Cursor, newest first. A zero Cursor starts from the
+	// newest entry
+	GetFeedQuery struct {
+		UserID uuid.UUID
+		Cursor uint
+		Limit  int
+	}
+)
+
+func (c *GetFeedQuery) Handle() (interface{}, error) {
+
+	db := config.Container.Database
+
+	limit := c.Limit
+
+	if limit < 1 {
+		limit = defaultFeedLimit
+	}
+
+	if limit > maxFeedLimit {
+		limit = maxFeedLimit
+	}
+
+	blocks, err := blockListOf(db, c.UserID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := append(blocks.HiddenUserIDs, blocks.MutedUserIDs...)
+
+	// fanned out activities are read from the feed items of the user, the
+	// others are pulled from the users they follow
+	pushed := db.Model(&models.FeedItem{}).Select("activity_id").Where("owner_id = ?", c.UserID)
+	followed := db.Model(&models.Follow{}).Select("followee_id").Where("follower_id = ?", c.UserID)
+
+	query := db.Model(&models.Activity{}).
+		Where(db.Where("id IN (?)", pushed).Or("fanned_out = ? AND actor_id IN (?)", false, followed))
+
+	if c.Cursor != 0 {
+		query = query.Where("id < ?", c.Cursor)
+	}
+
+	if len(excluded) != 0 {
+		query = query.Where("actor_id NOT IN ?", excluded)
+	}
+
+	activities := []models.Activity{}
+
+	if res := query.Order("id DESC").Limit(limit + 1).Find(&activities); res.Error != nil {
+		return nil, res.Error
+	}
+
+	state := &models.FeedState{}
+
+	if res := db.Find(state, "user_id = ?", c.UserID); res.Error != nil {
+		return nil, res.Error
+	}
+
+	resp := &GetFeedQueryResponse{
+		Entries:        []FeedEntry{},
+		SeenActivityID: state.SeenActivityID,
+	}
+
+	if len(activities) > limit {
+		activities = activities[:limit]
+		resp.NextCursor = activities[limit-1].ID
+	}
+
+	for _, activity := range activities {
+		resp.Entries = append(resp.Entries, FeedEntry{
+			Activity: activity,
+			Seen:     activity.ID <= state.SeenActivityID,
+		})
+	}
+
+	return resp, nil
+
+}
@@ -0,0 +1,39 @@
+package queries
+
+import (
+	"music-sharing/user-microservice/internal/app/models"
+	config "music-sharing/user-microservice/pkg"
+
+	"github.com/google/uuid"
+)
+
+type (
+	GetFollowRequestsQueryResponse struct {
+		FollowRequests []models.FollowRequest `json:"followRequests"`
+	}
+
+	// The pending follow requests to the user, newest first
+	GetFollowRequestsQuery struct {
+		UserID uuid.UUID
+	}
+)
+
+func (c *GetFollowRequestsQuery) Handle() (interface{}, error) {
+
+	db := config.Container.Database
+
+	requests := []models.FollowRequest{}
+
+	res := db.Order("created_at DESC").Find(&requests, "target_id = ?", c.UserID)
+
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	resp := &GetFollowRequestsQueryResponse{
+		FollowRequests: requests,
+	}
+
+	return resp, nil
+
+}
@@ -0,0 +1,44 @@
+package queries
+
+import (
+	"music-sharing/user-microservice/internal/app/models"
+	config "music-sharing/user-microservice/pkg"
+
+	"github.com/google/uuid"
+)
+
+type (
+	GetFollowedUsersQueryResponse struct {
+		FollowingUserIDs []uuid.UUID `json:"followingUserIds"`
+	}
+
+	// The users among UserIDs that the user follows. Other services ask about
+	// the owners of what they show instead of loading every follow of a user
+	GetFollowedUsersQuery struct {
+		UserID  uuid.UUID
+		UserIDs []uuid.UUID
+	}
+)
+
+func (c *GetFollowedUsersQuery) Handle() (interface{}, error) {
+
+	db := config.Container.Database
+
+	resp := &GetFollowedUsersQueryResponse{
+		FollowingUserIDs: []uuid.UUID{},
+	}
+
+	if len(c.UserIDs) == 0 {
+		return resp, nil
+	}
+
+	res := db.Model(&models.Follow{}).
+		Where("follower_id = ? AND followee_id IN ?", c.UserID, c.UserIDs).
+		Pluck("followee_id", &resp.FollowingUserIDs)
+
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,108 @@
+package queries
+
+import (
+	"music-sharing/user-microservice/internal/app/models"
+	config "music-sharing/user-microservice/pkg"
+
+	"github.com/google/uuid"
+)
+
+type (
+	// NextCursor is 0 when there are no older notifications
+	GetNotificationsQueryResponse struct {
+		Notifications []models.Notification `json:"notifications"`
+		UnreadCount   int64                 `json:"unreadCount"`
+		NextCursor    uint                  `json:"nextCursor"`
+	}
+
+	// Notifications older than Cursor, newest first
+	GetNotificationsQuery struct {
+		UserID     uuid.UUID
+		UnreadOnly bool
+		Cursor     uint
+		Limit      int
+	}
+
+	GetNotificationPreferencesQueryResponse struct {
+		Preferences map[string]bool `json:"preferences"`
+	}
+
+	GetNotificationPreferencesQuery struct {
+		UserID uuid.UUID
+	}
+)
+
+func (c *GetNotificationsQuery) Handle() (interface{}, error) {
+
+	db := config.Container.Database
+
+	limit := c.Limit
+
+	if limit < 1 {
+		limit = defaultFeedLimit
+	}
+
+	if limit > maxFeedLimit {
+		limit = maxFeedLimit
+	}
+
+	query := db.Model(&models.Notification{}).Where("user_id = ?", c.UserID)
+
+	if c.UnreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+
+	if c.Cursor != 0 {
+		query = query.Where("id < ?", c.Cursor)
+	}
+
+	notifications := []models.Notification{}
+
+	if res := query.Order("id DESC").Limit(limit + 1).Find(&notifications); res.Error != nil {
+		return nil, res.Error
+	}
+
+	resp := &GetNotificationsQueryResponse{
+		Notifications: notifications,
+	}
+
+	if len(notifications) > limit {
+		resp.Notifications = notifications[:limit]
+		resp.NextCursor = notifications[limit-1].ID
+	}
+
+	res := db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", c.UserID).Count(&resp.UnreadCount)
+
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	return resp, nil
+
+}
+
+func (c *GetNotificationPreferencesQuery) Handle() (interface{}, error) {
+
+	db := config.Container.Database
+
+	preferences := []models.NotificationPreference{}
+
+	if res := db.Find(&preferences, "user_id = ?", c.UserID); res.Error != nil {
+		return nil, res.Error
+	}
+
+	resp := &GetNotificationPreferencesQueryResponse{
+		Preferences: map[string]bool{},
+	}
+
+	for _, notificationType := range models.NotificationTypes {
+		resp.Preferences[notificationType] = true
+	}
+
+	for _, preference := range preferences {
+		resp.Preferences[preference.Type] = preference.Enabled
+	}
+
+	return resp, nil
+
+}
@@ -0,0 +1,38 @@
+package queries
+
+import (
+	"music-sharing/user-microservice/internal/app/models"
+	config "music-sharing/user-microservice/pkg"
+
+	"github.com/google/uuid"
+)
+
+type (
+	GetUserIdentitiesQueryResponse struct {
+		Identities []models.UserIdentity `json:"identities"`
+	}
+
+	GetUserIdentitiesQuery struct {
+		UserID uuid.UUID
+	}
+)
+
+func (c *GetUserIdentitiesQuery) Handle() (interface{}, error) {
+
+	db := config.Container.Database
+
+	identities := []models.UserIdentity{}
+
+	res := db.Find(&identities, "user_id = ?", c.UserID)
+
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	resp := &GetUserIdentitiesQueryResponse{
+		Identities: identities,
+	}
+
+	return resp, nil
+
+}
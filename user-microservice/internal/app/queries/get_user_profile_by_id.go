@@ -1,7 +1,7 @@
 package queries
 
 import (
-	"errors"
+	"music-sharing/shared/apperrors"
 	"music-sharing/user-microservice/internal/app/models"
 	config "music-sharing/user-microservice/pkg"
 
@@ -13,8 +13,11 @@ type (
 		User *models.User `json:"user"`
 	}
 
+	// ViewerID is the authenticated user, if any. Users who blocked each
+	// other can't see each other's profile
 	GetUserProfileByIdQuery struct {
-		ID uuid.UUID
+		ID       uuid.UUID
+		ViewerID uuid.UUID
 	}
 )
 
@@ -27,17 +30,34 @@ func (c *GetUserProfileByIdQuery) Handle() (interface{}, error) {
 	res := db.Find(user, "ID = ?", c.ID)
 
 	if user.ID == uuid.Nil {
-		return nil, errors.New("user doesnt exist")
+		return nil, apperrors.NotFound("user doesnt exist")
 	}
 
 	if res.Error != nil {
 		return nil, res.Error
 	}
 
+	if c.ViewerID != uuid.Nil && c.ViewerID != user.ID {
+		var count int64
+
+		res := db.Model(&models.Block{}).
+			Where("kind = ?", models.BlockKindBlock).
+			Where("(blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)", c.ViewerID, user.ID, user.ID, c.ViewerID).
+			Count(&count)
+
+		if res.Error != nil {
+			return nil, res.Error
+		}
+
+		if count != 0 {
+			return nil, apperrors.NotFound("user doesnt exist")
+		}
+	}
+
 	resp := &GetUserProfileByIdQueryResponse{
 		User: user,
 	}
 
 	return resp, nil
 
-}
\ No newline at end of file
+}
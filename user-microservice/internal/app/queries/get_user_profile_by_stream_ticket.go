@@ -0,0 +1,31 @@
+package queries
+
+import (
+	"music-sharing/shared/apperrors"
+	"music-sharing/user-microservice/internal/lib"
+)
+
+type GetUserProfileByStreamTicketQuery struct {
+	Ticket string
+}
+
+func (c *GetUserProfileByStreamTicketQuery) Handle() (interface{}, error) {
+
+	claims, err := lib.ParseSignedToken(lib.StreamTicketPurpose, c.Ticket)
+
+	if err != nil {
+		return nil, apperrors.Unauthorized("invalid or expired ticket")
+	}
+
+	user, err := sessionUser(claims)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &GetUserProfileByTokenQueryResponse{
+		User: user,
+	}
+
+	return resp, nil
+}
@@ -1,11 +1,10 @@
 package queries
 
 import (
-	"errors"
+	"music-sharing/shared/apperrors"
 	"music-sharing/user-microservice/internal/app/models"
 	"music-sharing/user-microservice/internal/lib"
 	config "music-sharing/user-microservice/pkg"
-	"strings"
 
 	"github.com/google/uuid"
 )
@@ -22,30 +21,55 @@ type (
 
 func (c *GetUserProfileByTokenQuery) Handle() (interface{}, error) {
 
-	db := config.Container.Database
-
-	user := &models.User{}
 	claims, err := lib.ParseJWT(c.Token)
-	userId := claims["userId"].(string)
 
 	if err != nil {
 		return nil, err
 	}
 
+	user, err := sessionUser(claims)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &GetUserProfileByTokenQueryResponse{
+		User: user,
+	}
+
+	return resp, nil
+
+}
+
+// The user of a session or of a ticket minted from one, as long as the session
+// was not revoked since and the user is not suspended
+func sessionUser(claims map[string]interface{}) (*models.User, error) {
+
+	db := config.Container.Database
+
+	user := &models.User{}
+	userId, _ := claims["userId"].(string)
+
 	res := db.Find(user, "id = ?", userId)
 
 	if user.ID == uuid.Nil {
-		return nil, errors.New(strings.Join([]string{"tried to fetch user but doesnt exist", userId}, " "))
+		return nil, apperrors.Unauthorized("user doesnt exist")
 	}
 
 	if res.Error != nil {
 		return nil, res.Error
 	}
 
-	resp := &GetUserProfileByTokenQueryResponse{
-		User: user,
+	// tokens issued before the last password change are revoked
+	tokenVersion, _ := claims["tokenVersion"].(float64)
+
+	if uint(tokenVersion) != user.TokenVersion {
+		return nil, apperrors.Unauthorized("session has been revoked, please login again")
 	}
 
-	return resp, nil
+	if user.SuspendedAt != nil {
+		return nil, apperrors.Forbidden("this account is suspended")
+	}
 
+	return user, nil
 }
@@ -0,0 +1,55 @@
+package queries
+
+import (
+	"music-sharing/shared/apperrors"
+	"music-sharing/user-microservice/internal/app/models"
+	config "music-sharing/user-microservice/pkg"
+
+	"github.com/google/uuid"
+)
+
+type (
+	// What other services need to decide what the user can do and see. The
+	// sessions minted with an older token version are revoked. The follows are
+	// not part of it, see GetFollowedUsersQuery
+	GetViewerContextQueryResponse struct {
+		Role          string      `json:"role"`
+		TokenVersion  uint        `json:"tokenVersion"`
+		Suspended     bool        `json:"suspended"`
+		HiddenUserIDs []uuid.UUID `json:"hiddenUserIds"`
+	}
+
+	GetViewerContextQuery struct {
+		UserID uuid.UUID
+	}
+)
+
+func (c *GetViewerContextQuery) Handle() (interface{}, error) {
+
+	db := config.Container.Database
+
+	user := &models.User{}
+
+	res := db.Find(user, "id = ?", c.UserID)
+
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	if user.ID == uuid.Nil {
+		return nil, apperrors.NotFound("user doesnt exist")
+	}
+
+	blockList, err := blockListOf(db, c.UserID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &GetViewerContextQueryResponse{
+		Role:          user.Role,
+		TokenVersion:  user.TokenVersion,
+		Suspended:     user.SuspendedAt != nil,
+		HiddenUserIDs: blockList.HiddenUserIDs,
+	}, nil
+}
@@ -0,0 +1,207 @@
+package subscribers
+
+import (
+	"music-sharing/user-microservice/internal/app/commands"
+	"music-sharing/user-microservice/internal/app/events"
+	"music-sharing/user-microservice/internal/app/models"
+	config "music-sharing/user-microservice/pkg"
+)
+
+func onUserFollowedFeed(message []byte) error {
+
+	event := &events.UserFollowed{}
+	envelope, err := decodeWithEnvelope(message, event)
+
+	if err != nil {
+		return err
+	}
+
+	return config.Container.CommmandBus.Send(&commands.RecordActivityCommand{
+		EventID:  envelope.ID,
+		ActorID:  event.FollowerID,
+		Verb:     models.ActivityFollowed,
+		ObjectID: event.FolloweeID.String(),
+	})
+}
+
+func onUserUnfollowedFeed(message []byte) error {
+
+	event := &events.UserUnfollowed{}
+
+	if err := decode(message, event); err != nil {
+		return err
+	}
+
+	return config.Container.CommmandBus.Send(&commands.PruneFeedCommand{
+		OwnerID: event.FollowerID,
+		ActorID: event.FolloweeID,
+	})
+}
+
+func onMusicUploaded(message []byte) error {
+
+	event := &events.MusicUploaded{}
+	envelope, err := decodeWithEnvelope(message, event)
+
+	if err != nil {
+		return err
+	}
+
+	// unlisted and private tracks stay out of the feeds, scheduled ones get
+	// in when they are released
+	if !isListed(event.Visibility) || event.ReleaseAt != nil {
+		return nil
+	}
+
+	return config.Container.CommmandBus.Send(&commands.RecordActivityCommand{
+		EventID:  envelope.ID,
+		ActorID:  event.ArtistID,
+		Verb:     models.ActivityUploaded,
+		ObjectID: event.MusicID,
+		Title:    event.Title,
+	})
+}
+
+func onMusicLiked(message []byte) error {
+
+	event := &events.MusicLiked{}
+	envelope, err := decodeWithEnvelope(message, event)
+
+	if err != nil {
+		return err
+	}
+
+	// the followers of the user may not be in the audience of the track
+	if !isPublic(event.Visibility) {
+		return nil
+	}
+
+	return config.Container.CommmandBus.Send(&commands.RecordActivityCommand{
+		EventID:  envelope.ID,
+		ActorID:  event.UserID,
+		Verb:     models.ActivityLiked,
+		ObjectID: event.MusicID,
+	})
+}
+
+func onMusicDeleted(message []byte) error {
+
+	event := &events.MusicDeleted{}
+
+	if err := decode(message, event); err != nil {
+		return err
+	}
+
+	return config.Container.CommmandBus.Send(&commands.RemoveActivitiesCommand{
+		ObjectID: event.MusicID,
+	})
+}
+
+func onMusicReposted(message []byte) error {
+
+	event := &events.MusicReposted{}
+	envelope, err := decodeWithEnvelope(message, event)
+
+	if err != nil {
+		return err
+	}
+
+	return config.Container.CommmandBus.Send(&commands.RecordActivityCommand{
+		EventID:  envelope.ID,
+		ActorID:  event.UserID,
+		Verb:     models.ActivityReposted,
+		ObjectID: event.MusicID,
+		Caption:  event.Caption,
+	})
+}
+
+func onMusicUnreposted(message []byte) error {
+
+	event := &events.MusicUnreposted{}
+
+	if err := decode(message, event); err != nil {
+		return err
+	}
+
+	return config.Container.CommmandBus.Send(&commands.RemoveActivitiesCommand{
+		ObjectID: event.MusicID,
+		ActorID:  event.UserID,
+		Verb:     models.ActivityReposted,
+	})
+}
+
+func onMusicReleased(message []byte) error {
+
+	event := &events.MusicReleased{}
+	envelope, err := decodeWithEnvelope(message, event)
+
+	if err != nil {
+		return err
+	}
+
+	if !isListed(event.Visibility) {
+		return nil
+	}
+
+	return config.Container.CommmandBus.Send(&commands.RecordActivityCommand{
+		EventID:  envelope.ID,
+		ActorID:  event.ArtistID,
+		Verb:     models.ActivityReleased,
+		ObjectID: event.MusicID,
+		Title:    event.Title,
+	})
+}
+
+// A track that is no longer listed leaves the feeds, one that gets listed is
+// announced like an upload
+func onMusicVisibilityChanged(message []byte) error {
+
+	event := &events.MusicVisibilityChanged{}
+	envelope, err := decodeWithEnvelope(message, event)
+
+	if err != nil {
+		return err
+	}
+
+	commandBus := config.Container.CommmandBus
+
+	switch {
+	case !isListed(event.Visibility):
+		if err := commandBus.Send(&commands.RemoveActivitiesCommand{ObjectID: event.MusicID}); err != nil {
+			return err
+		}
+	case !isPublic(event.Visibility):
+		// likes and reposts are shown to the followers of whoever made them
+		for _, verb := range []string{models.ActivityLiked, models.ActivityReposted} {
+			err := commandBus.Send(&commands.RemoveActivitiesCommand{
+				ObjectID: event.MusicID,
+				Verb:     verb,
+			})
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if !isListed(event.Visibility) || isListed(event.Previous) {
+		return nil
+	}
+
+	return commandBus.Send(&commands.RecordActivityCommand{
+		EventID:  envelope.ID,
+		ActorID:  event.ArtistID,
+		Verb:     models.ActivityUploaded,
+		ObjectID: event.MusicID,
+		Title:    event.Title,
+	})
+}
+
+func isPublic(visibility string) bool {
+	return len(visibility) == 0 || visibility == events.MusicVisibilityPublic
+}
+
+// Listed tracks show up in the feeds of the followers of their artist
+func isListed(visibility string) bool {
+	return isPublic(visibility) || visibility == events.MusicVisibilityFollowers
+}
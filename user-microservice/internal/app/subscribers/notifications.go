@@ -0,0 +1,240 @@
+package subscribers
+
+import (
+	"fmt"
+	"music-sharing/user-microservice/internal/app/commands"
+	"music-sharing/user-microservice/internal/app/events"
+	"music-sharing/user-microservice/internal/app/models"
+	config "music-sharing/user-microservice/pkg"
+	"os"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+func onUserFollowedNotify(message []byte) error {
+
+	event := &events.UserFollowed{}
+	envelope, err := decodeWithEnvelope(message, event)
+
+	if err != nil {
+		return err
+	}
+
+	return config.Container.CommmandBus.Send(&commands.CreateNotificationCommand{
+		EventID:  envelope.ID,
+		UserID:   event.FolloweeID,
+		Type:     models.NotificationFollow,
+		ActorID:  event.FollowerID,
+		ObjectID: event.FollowerID.String(),
+		Message:  "started following you",
+	})
+}
+
+func onFollowRequestedNotify(message []byte) error {
+
+	event := &events.FollowRequested{}
+	envelope, err := decodeWithEnvelope(message, event)
+
+	if err != nil {
+		return err
+	}
+
+	return config.Container.CommmandBus.Send(&commands.CreateNotificationCommand{
+		EventID:  envelope.ID,
+		UserID:   event.TargetID,
+		Type:     models.NotificationFollowRequest,
+		ActorID:  event.RequesterID,
+		ObjectID: event.RequesterID.String(),
+		Message:  "asked to follow you",
+	})
+}
+
+func onMusicLikedNotify(message []byte) error {
+
+	event := &events.MusicLiked{}
+	envelope, err := decodeWithEnvelope(message, event)
+
+	if err != nil {
+		return err
+	}
+
+	return config.Container.CommmandBus.Send(&commands.CreateNotificationCommand{
+		EventID:  envelope.ID,
+		UserID:   event.ArtistID,
+		Type:     models.NotificationLike,
+		ActorID:  event.UserID,
+		ObjectID: event.MusicID,
+		Message:  "liked your music",
+	})
+}
+
+func onMusicRepostedNotify(message []byte) error {
+
+	event := &events.MusicReposted{}
+	envelope, err := decodeWithEnvelope(message, event)
+
+	if err != nil {
+		return err
+	}
+
+	return config.Container.CommmandBus.Send(&commands.CreateNotificationCommand{
+		EventID:  envelope.ID,
+		UserID:   event.ArtistID,
+		Type:     models.NotificationRepost,
+		ActorID:  event.UserID,
+		ObjectID: event.MusicID,
+		Message:  "reposted your music",
+	})
+}
+
+// Notifies the artist, and the author of the parent comment of a reply
+func onMusicCommented(message []byte) error {
+
+	event := &events.MusicCommented{}
+	envelope, err := decodeWithEnvelope(message, event)
+
+	if err != nil {
+		return err
+	}
+
+	err = config.Container.CommmandBus.Send(&commands.CreateNotificationCommand{
+		EventID:  envelope.ID,
+		UserID:   event.ArtistID,
+		Type:     models.NotificationComment,
+		ActorID:  event.AuthorID,
+		ObjectID: event.MusicID,
+		Message:  "commented on your music",
+	})
+
+	if err != nil || event.ParentAuthorID == nil || *event.ParentAuthorID == event.ArtistID {
+		return err
+	}
+
+	return config.Container.CommmandBus.Send(&commands.CreateNotificationCommand{
+		EventID:  envelope.ID,
+		UserID:   *event.ParentAuthorID,
+		Type:     models.NotificationComment,
+		ActorID:  event.AuthorID,
+		ObjectID: event.MusicID,
+		Message:  "replied to your comment",
+	})
+}
+
+func onMusicHidden(message []byte) error {
+
+	event := &events.MusicHidden{}
+	envelope, err := decodeWithEnvelope(message, event)
+
+	if err != nil {
+		return err
+	}
+
+	return config.Container.CommmandBus.Send(&commands.CreateNotificationCommand{
+		EventID:  envelope.ID,
+		UserID:   event.ArtistID,
+		Type:     models.NotificationModeration,
+		ObjectID: event.MusicID,
+		Message:  "Your music was hidden by a moderator: " + event.Reason,
+	})
+}
+
+func onMusicUnhidden(message []byte) error {
+
+	event := &events.MusicUnhidden{}
+	envelope, err := decodeWithEnvelope(message, event)
+
+	if err != nil {
+		return err
+	}
+
+	return config.Container.CommmandBus.Send(&commands.CreateNotificationCommand{
+		EventID:  envelope.ID,
+		UserID:   event.ArtistID,
+		Type:     models.NotificationModeration,
+		ObjectID: event.MusicID,
+		Message:  "Your music is visible again",
+	})
+}
+
+// Hidden tracks are notified by onMusicHidden, only removals are left
+func onModerationCaseResolved(message []byte) error {
+
+	event := &events.ModerationCaseResolved{}
+	envelope, err := decodeWithEnvelope(message, event)
+
+	if err != nil {
+		return err
+	}
+
+	if event.Action != "remove" {
+		return nil
+	}
+
+	return config.Container.CommmandBus.Send(&commands.CreateNotificationCommand{
+		EventID:  envelope.ID,
+		UserID:   event.ArtistID,
+		Type:     models.NotificationModeration,
+		ObjectID: event.MusicID,
+		Message:  "Your music was removed after being reported",
+	})
+}
+
+// Artists reaching TAKEDOWN_STRIKE_THRESHOLD actioned takedowns, 3 by default,
+// are suspended as repeat infringers
+func isRepeatInfringer(strikes int) bool {
+	threshold, err := strconv.Atoi(os.Getenv("TAKEDOWN_STRIKE_THRESHOLD"))
+
+	if err != nil || threshold <= 0 {
+		threshold = 3
+	}
+
+	return strikes >= threshold
+}
+
+func onTakedownActioned(message []byte) error {
+
+	commandBus := config.Container.CommmandBus
+	event := &events.TakedownActioned{}
+	envelope, err := decodeWithEnvelope(message, event)
+
+	if err != nil {
+		return err
+	}
+
+	err = commandBus.Send(&commands.CreateNotificationCommand{
+		EventID:  envelope.ID,
+		UserID:   event.ArtistID,
+		Type:     models.NotificationModeration,
+		ObjectID: event.TakedownID,
+		Message:  fmt.Sprintf("%d of your musics were disabled after a copyright takedown", len(event.MusicIDs)),
+	})
+
+	// the tracks of deleted accounts are anonymised to the nil id
+	if err != nil || event.ArtistID == uuid.Nil || !isRepeatInfringer(event.Strikes) {
+		return err
+	}
+
+	return commandBus.Send(&commands.SuspendUserCommand{
+		UserID: event.ArtistID,
+		Reason: fmt.Sprintf("%d copyright takedowns", event.Strikes),
+	})
+}
+
+func onTakedownReinstated(message []byte) error {
+
+	event := &events.TakedownReinstated{}
+	envelope, err := decodeWithEnvelope(message, event)
+
+	if err != nil {
+		return err
+	}
+
+	return config.Container.CommmandBus.Send(&commands.CreateNotificationCommand{
+		EventID:  envelope.ID,
+		UserID:   event.ArtistID,
+		Type:     models.NotificationModeration,
+		ObjectID: event.TakedownID,
+		Message:  "Your musics were reinstated after your counter-notice",
+	})
+}
@@ -0,0 +1,28 @@
+package subscribers
+
+import "testing"
+
+func TestIsRepeatInfringer(t *testing.T) {
+	cases := []struct {
+		threshold string
+		strikes   int
+		want      bool
+	}{
+		{"", 2, false},
+		{"", 3, true},
+		{"", 4, true},
+		{"5", 4, false},
+		{"5", 5, true},
+		{"1", 1, true},
+		{"0", 2, false},
+		{"many", 3, true},
+	}
+
+	for _, tc := range cases {
+		t.Setenv("TAKEDOWN_STRIKE_THRESHOLD", tc.threshold)
+
+		if got := isRepeatInfringer(tc.strikes); got != tc.want {
+			t.Fatalf("isRepeatInfringer(%d) with threshold %q = %t, want %t", tc.strikes, tc.threshold, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package subscribers
+
+import (
+	"encoding/json"
+	"music-sharing/user-microservice/internal/app/commands"
+	"music-sharing/user-microservice/internal/app/events"
+	"music-sharing/user-microservice/internal/interfaces"
+	config "music-sharing/user-microservice/pkg"
+)
+
+// Register wires the reactions of user-microservice to the domain events
+func Register(broker interfaces.MessageBroker) {
+	broker.Subscribe(events.UserRegisteredEvent, onUserRegistered)
+	broker.Subscribe(events.UserFollowedEvent, onUserFollowedFeed)
+	broker.Subscribe(events.UserUnfollowedEvent, onUserUnfollowedFeed)
+	broker.Subscribe(events.MusicUploadedEvent, onMusicUploaded)
+	broker.Subscribe(events.MusicReleasedEvent, onMusicReleased)
+	broker.Subscribe(events.MusicLikedEvent, onMusicLiked)
+	broker.Subscribe(events.MusicDeletedEvent, onMusicDeleted)
+	broker.Subscribe(events.MusicRepostedEvent, onMusicReposted)
+	broker.Subscribe(events.MusicUnrepostedEvent, onMusicUnreposted)
+	broker.Subscribe(events.MusicVisibilityChangedEvent, onMusicVisibilityChanged)
+
+	broker.Subscribe(events.UserFollowedEvent, onUserFollowedNotify)
+	broker.Subscribe(events.FollowRequestedEvent, onFollowRequestedNotify)
+	broker.Subscribe(events.MusicLikedEvent, onMusicLikedNotify)
+	broker.Subscribe(events.MusicCommentedEvent, onMusicCommented)
+	broker.Subscribe(events.MusicRepostedEvent, onMusicRepostedNotify)
+	broker.Subscribe(events.MusicHiddenEvent, onMusicHidden)
+	broker.Subscribe(events.MusicUnhiddenEvent, onMusicUnhidden)
+	broker.Subscribe(events.ModerationCaseResolvedEvent, onModerationCaseResolved)
+	broker.Subscribe(events.TakedownActionedEvent, onTakedownActioned)
+	broker.Subscribe(events.TakedownReinstatedEvent, onTakedownReinstated)
+}
+
+func decode(message []byte, payload interface{}) error {
+
+	_, err := decodeWithEnvelope(message, payload)
+
+	return err
+}
+
+func decodeWithEnvelope(message []byte, payload interface{}) (*events.Envelope, error) {
+
+	envelope, err := events.ParseEnvelope(message)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return envelope, json.Unmarshal(envelope.Payload, payload)
+}
+
+func onUserRegistered(message []byte) error {
+
+	event := &events.UserRegistered{}
+
+	if err := decode(message, event); err != nil {
+		return err
+	}
+
+	return config.Container.CommmandBus.Send(&commands.SendEmailVerificationCommand{
+		UserID: event.UserID,
+	})
+}
@@ -0,0 +1,84 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"music-sharing/user-microservice/internal/app/commands"
+	"music-sharing/user-microservice/internal/app/models"
+	config "music-sharing/user-microservice/pkg"
+	"time"
+)
+
+// AccountDeletionWorker runs the cascade of every account deletion whose grace
+// period ended. A deletion is leased before it runs so only one replica works
+// on it at a time, and failed deletions are retried on the next tick
+type AccountDeletionWorker struct {
+	interval time.Duration
+	lease    time.Duration
+}
+
+func NewAccountDeletionWorker(interval time.Duration) *AccountDeletionWorker {
+	return &AccountDeletionWorker{
+		interval: interval,
+		lease:    10 * time.Minute,
+	}
+}
+
+func (w *AccountDeletionWorker) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := w.RunDue(); err != nil {
+					log.Printf("account deletion worker: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+func (w *AccountDeletionWorker) RunDue() error {
+
+	db := config.Container.Database
+	commandBus := config.Container.CommmandBus
+	now := time.Now().UTC()
+	due := []models.AccountDeletion{}
+
+	res := db.Where("status IN ? AND scheduled_for <= ?", []string{models.AccountDeletionPending, models.AccountDeletionInProgress}, now).
+		Where("locked_until IS NULL OR locked_until < ?", now).
+		Limit(10).
+		Find(&due)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	for _, deletion := range due {
+		lockedUntil := now.Add(w.lease)
+
+		claim := db.Model(&models.AccountDeletion{}).
+			Where("id = ? AND (locked_until IS NULL OR locked_until < ?)", deletion.ID, now).
+			Update("locked_until", lockedUntil)
+
+		if claim.Error != nil {
+			return claim.Error
+		}
+
+		if claim.RowsAffected == 0 {
+			continue
+		}
+
+		if err := commandBus.Send(&commands.RunAccountDeletionCommand{DeletionID: deletion.ID}); err != nil {
+			log.Printf("account deletion %s: %v", deletion.ID, err)
+		}
+
+		db.Model(&models.AccountDeletion{}).Where("id = ?", deletion.ID).Update("locked_until", nil)
+	}
+
+	return nil
+}
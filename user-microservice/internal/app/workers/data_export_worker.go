@@ -0,0 +1,82 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"music-sharing/user-microservice/internal/app/commands"
+	"music-sharing/user-microservice/internal/app/models"
+	config "music-sharing/user-microservice/pkg"
+	"time"
+)
+
+// DataExportWorker builds the queued data exports in the background and
+// removes the archives once they expire
+type DataExportWorker struct {
+	interval time.Duration
+	lease    time.Duration
+}
+
+func NewDataExportWorker(interval time.Duration) *DataExportWorker {
+	return &DataExportWorker{
+		interval: interval,
+		lease:    10 * time.Minute,
+	}
+}
+
+func (w *DataExportWorker) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := w.RunDue(); err != nil {
+					log.Printf("data export worker: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+func (w *DataExportWorker) RunDue() error {
+
+	db := config.Container.Database
+	commandBus := config.Container.CommmandBus
+	now := time.Now().UTC()
+	queued := []models.DataExport{}
+
+	res := db.Where("status IN ?", []string{models.DataExportPending, models.DataExportProcessing}).
+		Where("locked_until IS NULL OR locked_until < ?", now).
+		Order("requested_at").
+		Limit(5).
+		Find(&queued)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	for _, export := range queued {
+		claim := db.Model(&models.DataExport{}).
+			Where("id = ? AND (locked_until IS NULL OR locked_until < ?)", export.ID, now).
+			Update("locked_until", now.Add(w.lease))
+
+		if claim.Error != nil {
+			return claim.Error
+		}
+
+		if claim.RowsAffected == 0 {
+			continue
+		}
+
+		if err := commandBus.Send(&commands.BuildDataExportCommand{ExportID: export.ID}); err != nil {
+			log.Printf("data export %s: %v", export.ID, err)
+		}
+
+		db.Model(&models.DataExport{}).Where("id = ?", export.ID).Update("locked_until", nil)
+	}
+
+	return commandBus.Send(&commands.ExpireDataExportsCommand{})
+}
@@ -0,0 +1,46 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"music-sharing/user-microservice/internal/app/models"
+	config "music-sharing/user-microservice/pkg"
+	"time"
+)
+
+// IdempotencyKeyWorker deletes the idempotency keys whose window ended. The
+// middleware already ignores them, this only keeps the table small
+type IdempotencyKeyWorker struct {
+	interval time.Duration
+}
+
+func NewIdempotencyKeyWorker(interval time.Duration) *IdempotencyKeyWorker {
+	return &IdempotencyKeyWorker{
+		interval: interval,
+	}
+}
+
+func (w *IdempotencyKeyWorker) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := w.RunDue(); err != nil {
+					log.Printf("idempotency key worker: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+func (w *IdempotencyKeyWorker) RunDue() error {
+
+	db := config.Container.Database
+
+	return db.Where("expires_at < ?", time.Now().UTC()).Delete(&models.IdempotencyKey{}).Error
+}
@@ -15,7 +15,37 @@ func GetDB() *gorm.DB {
 
 	db, _ = gorm.Open(mysql.Open(os.Getenv("MYSQL_CONN")), &gorm.Config{})
 
-	db.AutoMigrate(&models.User{})
+	err := db.AutoMigrate(
+		&models.User{},
+		&models.OutboxEvent{},
+		&models.Follow{},
+		&models.FollowRequest{},
+		&models.AccountDeletion{},
+		&models.DataExport{},
+		&models.LoginAttempt{},
+		&models.RecoveryCode{},
+		&models.UserIdentity{},
+		&models.OAuthState{},
+		&models.AdminAuditLog{},
+		&models.Block{},
+		&models.Activity{},
+		&models.FeedItem{},
+		&models.FeedState{},
+		&models.Notification{},
+		&models.NotificationPreference{},
+		&models.SentNotice{},
+		&models.IdempotencyKey{},
+		&models.SchemaMigration{},
+	)
+
+	// the service doesnt start on a half migrated schema
+	if err != nil {
+		log.Fatalf("schema migration: %v", err)
+	}
+
+	if err := runMigrations(db); err != nil {
+		log.Fatalf("migrations: %v", err)
+	}
 
 	log.Printf("🚀 Connected to %s", os.Getenv("MYSQL_CONN"))
 
@@ -0,0 +1,25 @@
+package infrastructure
+
+import (
+	"music-sharing/user-microservice/internal/interfaces"
+	"os"
+)
+
+// Picks the mailer from MAILER ("smtp", "memory" or "log" which is the default)
+func GetMailer() interfaces.Mailer {
+
+	switch os.Getenv("MAILER") {
+	case "smtp":
+		return NewSMTPMailer(
+			os.Getenv("SMTP_HOST"),
+			os.Getenv("SMTP_PORT"),
+			os.Getenv("SMTP_USERNAME"),
+			os.Getenv("SMTP_PASSWORD"),
+			os.Getenv("MAIL_FROM"),
+		)
+	case "memory":
+		return NewInMemoryMailer()
+	default:
+		return &LogMailer{}
+	}
+}
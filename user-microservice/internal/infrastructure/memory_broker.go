@@ -0,0 +1,9 @@
+package infrastructure
+
+import "music-sharing/shared/broker"
+
+var inMemoryBroker = broker.NewInMemoryBroker()
+
+func GetBroker() *broker.InMemoryBroker {
+	return inMemoryBroker
+}
@@ -0,0 +1,49 @@
+package infrastructure
+
+import (
+	"log"
+	"sync"
+)
+
+type (
+	MailMessage struct {
+		To      string
+		Subject string
+		Body    string
+	}
+
+	// InMemoryMailer keeps the sent messages so tests can assert on them
+	InMemoryMailer struct {
+		mu       sync.RWMutex
+		messages []MailMessage
+	}
+
+	// LogMailer only writes the messages to the log, handy for local development
+	LogMailer struct{}
+)
+
+func NewInMemoryMailer() *InMemoryMailer {
+	return &InMemoryMailer{}
+}
+
+func (m *InMemoryMailer) Send(to string, subject string, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, MailMessage{To: to, Subject: subject, Body: body})
+
+	return nil
+}
+
+func (m *InMemoryMailer) Messages() []MailMessage {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return append([]MailMessage{}, m.messages...)
+}
+
+func (m *LogMailer) Send(to string, subject string, body string) error {
+	log.Printf("📧 to: %s, subject: %s\n%s", to, subject, body)
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"music-sharing/user-microservice/internal/app/events"
+	"music-sharing/user-microservice/internal/app/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// The data migrations run in order, each one once and in its own transaction
+var migrations = []struct {
+	name string
+	run  func(tx *gorm.DB) error
+}{
+	{name: "backfill_follows", run: backfillFollows},
+}
+
+func runMigrations(db *gorm.DB) error {
+	for _, migration := range migrations {
+		err := db.Transaction(func(tx *gorm.DB) error {
+
+			var count int64
+
+			if res := tx.Model(&models.SchemaMigration{}).Where("name = ?", migration.name).Count(&count); res.Error != nil || count != 0 {
+				return res.Error
+			}
+
+			if err := migration.run(tx); err != nil {
+				return err
+			}
+
+			return tx.Create(&models.SchemaMigration{Name: migration.name, RanAt: time.Now().UTC()}).Error
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Follows were only counted before the follows table existed. The edges are
+// rebuilt from the follow events of the outbox. The counters are left alone,
+// they already count the follows of these events as well as the follows older
+// than the outbox, which left no event to rebuild their edges from
+func backfillFollows(tx *gorm.DB) error {
+
+	outboxEvents := []models.OutboxEvent{}
+
+	res := tx.Where("name IN ?", []string{events.UserFollowedEvent, events.UserUnfollowedEvent}).
+		Order("occurred_at").
+		Find(&outboxEvents)
+
+	if res.Error != nil {
+		return res.Error
+	}
+
+	type edge struct {
+		follower uuid.UUID
+		followee uuid.UUID
+	}
+
+	following := map[edge]time.Time{}
+
+	for _, outboxEvent := range outboxEvents {
+		payload := &events.UserFollowed{}
+
+		if err := json.Unmarshal([]byte(outboxEvent.Payload), payload); err != nil {
+			return err
+		}
+
+		key := edge{follower: payload.FollowerID, followee: payload.FolloweeID}
+
+		if outboxEvent.Name == events.UserFollowedEvent {
+			following[key] = outboxEvent.OccurredAt
+		} else {
+			delete(following, key)
+		}
+	}
+
+	// the edges of deleted users and of blocked pairs stay out
+	for key, since := range following {
+		res := tx.Exec(`INSERT IGNORE INTO follows (follower_id, followee_id, created_at)
+			SELECT ?, ?, ? FROM DUAL
+			WHERE (SELECT COUNT(*) FROM users WHERE id IN (?, ?) AND deleted_at IS NULL) = 2
+			AND NOT EXISTS (
+				SELECT 1 FROM blocks WHERE kind = ?
+				AND ((blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?))
+			)`,
+			key.follower, key.followee, since,
+			key.follower, key.followee,
+			models.BlockKindBlock,
+			key.follower, key.followee, key.followee, key.follower,
+		)
+
+		if res.Error != nil {
+			return res.Error
+		}
+	}
+
+	return nil
+}
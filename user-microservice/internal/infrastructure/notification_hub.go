@@ -0,0 +1,67 @@
+package infrastructure
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// InMemoryNotificationHub pushes notifications to the live connections of the
+// same process. Slow subscribers miss notifications instead of blocking the
+// publisher, they still get them from the list api
+type InMemoryNotificationHub struct {
+	mu          sync.RWMutex
+	subscribers map[uuid.UUID]map[chan []byte]struct{}
+}
+
+var notificationHub = NewInMemoryNotificationHub()
+
+func NewInMemoryNotificationHub() *InMemoryNotificationHub {
+	return &InMemoryNotificationHub{
+		subscribers: map[uuid.UUID]map[chan []byte]struct{}{},
+	}
+}
+
+func (h *InMemoryNotificationHub) Subscribe(userId uuid.UUID) (<-chan []byte, func()) {
+	ch := make(chan []byte, 16)
+
+	h.mu.Lock()
+	if h.subscribers[userId] == nil {
+		h.subscribers[userId] = map[chan []byte]struct{}{}
+	}
+	h.subscribers[userId][ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+
+	unsubscribe := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+
+			delete(h.subscribers[userId], ch)
+
+			if len(h.subscribers[userId]) == 0 {
+				delete(h.subscribers, userId)
+			}
+		})
+	}
+
+	return ch, unsubscribe
+}
+
+func (h *InMemoryNotificationHub) Publish(userId uuid.UUID, payload []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for ch := range h.subscribers[userId] {
+		select {
+		case ch <- payload:
+		default:
+		}
+	}
+}
+
+func GetNotificationHub() *InMemoryNotificationHub {
+	return notificationHub
+}
@@ -0,0 +1,143 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"music-sharing/user-microservice/internal/app/events"
+	"music-sharing/user-microservice/internal/app/models"
+	"music-sharing/user-microservice/internal/interfaces"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OutboxRelay polls the outbox table and publishes pending events to the broker.
+// A batch is claimed with a short lease before publishing so several replicas
+// can relay concurrently, the rows are not locked while the broker is called.
+// An event still failing after maxAttempts is dead lettered
+type OutboxRelay struct {
+	db          *gorm.DB
+	broker      interfaces.MessageBroker
+	interval    time.Duration
+	lease       time.Duration
+	batchSize   int
+	maxAttempts uint
+}
+
+func NewOutboxRelay(db *gorm.DB, broker interfaces.MessageBroker, interval time.Duration) *OutboxRelay {
+	return &OutboxRelay{
+		db:          db,
+		broker:      broker,
+		interval:    interval,
+		lease:       30 * time.Second,
+		batchSize:   100,
+		maxAttempts: 20,
+	}
+}
+
+func (r *OutboxRelay) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := r.Flush(); err != nil {
+					log.Printf("outbox relay: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Flush publishes one batch of pending events. An event that fails stays
+// claimed until its lease ends, which spaces out its retries
+func (r *OutboxRelay) Flush() error {
+
+	pending, err := r.claim()
+
+	if err != nil {
+		return err
+	}
+
+	for i := range pending {
+		outboxEvent := &pending[i]
+
+		if res := r.db.Model(outboxEvent).Updates(r.publish(outboxEvent)); res.Error != nil {
+			return res.Error
+		}
+	}
+
+	return nil
+}
+
+// publish sends the claimed event to the broker and returns the columns of
+// the event to update with the outcome
+func (r *OutboxRelay) publish(outboxEvent *models.OutboxEvent) map[string]interface{} {
+
+	message, err := json.Marshal(events.NewEnvelope(outboxEvent))
+
+	if err == nil {
+		err = r.broker.Publish(outboxEvent.Name, message)
+	}
+
+	if err == nil {
+		return map[string]interface{}{"published_at": time.Now().UTC(), "last_error": "", "locked_until": nil}
+	}
+
+	update := map[string]interface{}{"last_error": err.Error()}
+
+	if outboxEvent.Attempts >= r.maxAttempts {
+		log.Printf("outbox relay: dead lettering %s %s after %d attempts: %v", outboxEvent.Name, outboxEvent.ID, outboxEvent.Attempts, err)
+
+		update["dead_lettered_at"] = time.Now().UTC()
+		update["locked_until"] = nil
+	}
+
+	return update
+}
+
+// claim leases a batch of the pending events that no other relay holds. The
+// transaction only lasts for the claim, it is committed before publishing
+func (r *OutboxRelay) claim() ([]models.OutboxEvent, error) {
+
+	pending := []models.OutboxEvent{}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+
+		now := time.Now().UTC()
+
+		res := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("published_at IS NULL AND dead_lettered_at IS NULL").
+			Where("locked_until IS NULL OR locked_until < ?", now).
+			Order("occurred_at").
+			Limit(r.batchSize).
+			Find(&pending)
+
+		if res.Error != nil || len(pending) == 0 {
+			return res.Error
+		}
+
+		ids := []uuid.UUID{}
+
+		for i := range pending {
+			ids = append(ids, pending[i].ID)
+			pending[i].Attempts++
+		}
+
+		return tx.Model(&models.OutboxEvent{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"locked_until": now.Add(r.lease),
+				"attempts":     gorm.Expr("attempts + 1"),
+			}).Error
+	})
+
+	return pending, err
+}
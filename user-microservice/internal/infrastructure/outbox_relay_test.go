@@ -0,0 +1,108 @@
+package infrastructure
+
+import (
+	"errors"
+	"music-sharing/shared/broker"
+	"music-sharing/user-microservice/internal/app/events"
+	"music-sharing/user-microservice/internal/app/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func pendingEvent(attempts uint) *models.OutboxEvent {
+	return &models.OutboxEvent{
+		ID:          uuid.New(),
+		Name:        events.UserFollowedEvent,
+		AggregateID: "user-1",
+		Payload:     `{"followerId":"user-1"}`,
+		OccurredAt:  time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Attempts:    attempts,
+	}
+}
+
+func TestPublishSendsTheEnvelope(t *testing.T) {
+	inMemory := broker.NewInMemoryBroker()
+	relay := NewOutboxRelay(nil, inMemory, time.Second)
+	outboxEvent := pendingEvent(1)
+
+	var received *events.Envelope
+
+	inMemory.Subscribe(events.UserFollowedEvent, func(payload []byte) error {
+		envelope, err := events.ParseEnvelope(payload)
+		received = envelope
+		return err
+	})
+
+	update := relay.publish(outboxEvent)
+
+	if received == nil {
+		t.Fatal("the subscriber did not receive the event")
+	}
+
+	if received.ID != outboxEvent.ID.String() || received.Name != outboxEvent.Name || received.AggregateID != "user-1" || !received.OccurredAt.Equal(outboxEvent.OccurredAt) {
+		t.Fatalf("received %+v, want the envelope of %+v", received, outboxEvent)
+	}
+
+	if string(received.Payload) != outboxEvent.Payload {
+		t.Fatalf("payload = %s, want %s", received.Payload, outboxEvent.Payload)
+	}
+
+	if update["published_at"] == nil || update["last_error"] != "" {
+		t.Fatalf("update = %v, want the event marked as published", update)
+	}
+}
+
+func TestPublishKeepsAFailedEventForRetry(t *testing.T) {
+	inMemory := broker.NewInMemoryBroker()
+	relay := NewOutboxRelay(nil, inMemory, time.Second)
+
+	inMemory.Subscribe(events.UserFollowedEvent, func(payload []byte) error {
+		return errors.New("subscriber failed")
+	})
+
+	update := relay.publish(pendingEvent(1))
+
+	if _, published := update["published_at"]; published {
+		t.Fatalf("update = %v, a failed event was marked as published", update)
+	}
+
+	if _, deadLettered := update["dead_lettered_at"]; deadLettered {
+		t.Fatalf("update = %v, the event was dead lettered on its first attempt", update)
+	}
+
+	// the lease is kept, it spaces out the retries
+	if _, unlocked := update["locked_until"]; unlocked {
+		t.Fatalf("update = %v, the lease of a failed event was released", update)
+	}
+
+	if update["last_error"] != "subscriber failed" {
+		t.Fatalf("last_error = %v, want the error of the subscriber", update["last_error"])
+	}
+}
+
+func TestPublishDeadLettersAfterMaxAttempts(t *testing.T) {
+	inMemory := broker.NewInMemoryBroker()
+	relay := NewOutboxRelay(nil, inMemory, time.Second)
+
+	inMemory.Subscribe(events.UserFollowedEvent, func(payload []byte) error {
+		return errors.New("subscriber failed")
+	})
+
+	update := relay.publish(pendingEvent(relay.maxAttempts - 1))
+
+	if _, deadLettered := update["dead_lettered_at"]; deadLettered {
+		t.Fatal("the event was dead lettered before its last attempt")
+	}
+
+	update = relay.publish(pendingEvent(relay.maxAttempts))
+
+	if update["dead_lettered_at"] == nil || update["last_error"] != "subscriber failed" {
+		t.Fatalf("update = %v, want the event dead lettered with its error", update)
+	}
+
+	if _, published := update["published_at"]; published {
+		t.Fatalf("update = %v, a dead lettered event was marked as published", update)
+	}
+}
@@ -0,0 +1,10 @@
+package interfaces
+
+import "music-sharing/shared/broker"
+
+type DomainEvent interface {
+	EventName() string
+	AggregateID() string
+}
+
+type MessageBroker = broker.Broker
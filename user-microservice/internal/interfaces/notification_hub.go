@@ -0,0 +1,10 @@
+package interfaces
+
+import "github.com/google/uuid"
+
+type NotificationHub interface {
+	// Returns the channel the notifications of the user are pushed to and a
+	// function that must be called once the subscriber is gone
+	Subscribe(userId uuid.UUID) (<-chan []byte, func())
+	Publish(userId uuid.UUID, payload []byte)
+}
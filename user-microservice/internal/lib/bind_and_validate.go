@@ -1,8 +1,9 @@
 package lib
 
 import (
+	"music-sharing/shared/validation"
+
 	"github.com/gin-gonic/gin"
-	"github.com/go-playground/validator/v10"
 )
 
 func BindAndValidate(body interface{}, c *gin.Context) error {
@@ -11,9 +12,9 @@ func BindAndValidate(body interface{}, c *gin.Context) error {
 		return err
 	}
 
-	if err := validator.New().Struct(body); err != nil {
+	if err := validation.Struct(body); err != nil {
 		return err
 	}
 
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,14 @@
+package lib
+
+import "music-sharing/user-microservice/internal/app/models"
+
+// Creates the session token of a user. The token version is checked on every
+// request, bumping it on the user revokes all the tokens issued before
+func CreateUserToken(user *models.User) (string, error) {
+	return CreateJWT(map[string]interface{}{
+		"userId":       user.ID.String(),
+		"isPrivate":    user.IsPrivate,
+		"tokenVersion": user.TokenVersion,
+		"role":         user.Role,
+	})
+}
@@ -0,0 +1,321 @@
+package lib
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"music-sharing/shared/apperrors"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type (
+	// OIDCProvider is a generic OpenID Connect provider configured from the
+	// environment, e.g. OIDC_GOOGLE_ISSUER, OIDC_GOOGLE_CLIENT_ID,
+	// OIDC_GOOGLE_CLIENT_SECRET and OIDC_GOOGLE_REDIRECT_URL for "google"
+	OIDCProvider struct {
+		Name         string
+		Issuer       string
+		ClientID     string
+		ClientSecret string
+		RedirectURL  string
+
+		mu        sync.Mutex
+		discovery *oidcDiscovery
+		keys      map[string]*rsa.PublicKey
+		fetchedAt time.Time
+	}
+
+	OIDCClaims struct {
+		Subject       string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+		Nonce         string `json:"nonce"`
+		jwt.RegisteredClaims
+	}
+
+	oidcDiscovery struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JwksURI               string `json:"jwks_uri"`
+	}
+
+	jsonWebKeySet struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+)
+
+const oidcCacheTTL = time.Hour
+
+var (
+	oidcClient    = &http.Client{Timeout: 10 * time.Second}
+	oidcProviders = map[string]*OIDCProvider{}
+	oidcMu        sync.Mutex
+)
+
+// Returns the provider if it is listed in OIDC_PROVIDERS
+func GetOIDCProvider(name string) (*OIDCProvider, error) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+
+	if provider, ok := oidcProviders[name]; ok {
+		return provider, nil
+	}
+
+	enabled := false
+
+	for _, provider := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		if strings.TrimSpace(provider) == name {
+			enabled = true
+		}
+	}
+
+	if !enabled || len(name) == 0 {
+		return nil, apperrors.NotFound("unknown identity provider")
+	}
+
+	prefix := "OIDC_" + strings.ToUpper(name) + "_"
+	provider := &OIDCProvider{
+		Name:         name,
+		Issuer:       strings.TrimSuffix(os.Getenv(prefix+"ISSUER"), "/"),
+		ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+		ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+		RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+	}
+
+	if len(provider.Issuer) == 0 || len(provider.ClientID) == 0 {
+		return nil, apperrors.NotFound(fmt.Sprintf("identity provider %s is not configured", name))
+	}
+
+	oidcProviders[name] = provider
+
+	return provider, nil
+}
+
+func RandomURLSafeString(n int) (string, error) {
+	data := make([]byte, n)
+
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// PKCE S256 code challenge of a code verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// The browser that starts a flow keeps StateBinding(state) in a cookie, the
+// callback of the flow is only accepted from that browser
+func StateBinding(state string) string {
+	sum := sha256.Sum256([]byte("oidc-state:" + state))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func getJSON(url string, out interface{}) error {
+	res, err := oidcClient.Get(url)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s failed with status %d", url, res.StatusCode)
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+func (p *OIDCProvider) load(force bool) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !force && p.discovery != nil && time.Since(p.fetchedAt) < oidcCacheTTL {
+		return p.discovery, nil
+	}
+
+	discovery := &oidcDiscovery{}
+
+	if err := getJSON(p.Issuer+"/.well-known/openid-configuration", discovery); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSuffix(discovery.Issuer, "/") != p.Issuer {
+		return nil, apperrors.Unauthorized("identity provider issuer mismatch")
+	}
+
+	jwks := &jsonWebKeySet{}
+
+	if err := getJSON(discovery.JwksURI, jwks); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+
+	for _, key := range jwks.Keys {
+		if key.Kty != "RSA" {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+
+		if err != nil {
+			return nil, err
+		}
+
+		keys[key.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	p.discovery = discovery
+	p.keys = keys
+	p.fetchedAt = time.Now()
+
+	return discovery, nil
+}
+
+func (p *OIDCProvider) AuthCodeURL(state string, nonce string, codeVerifier string) (string, error) {
+	discovery, err := p.load(false)
+
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.ClientID)
+	query.Set("redirect_uri", p.RedirectURL)
+	query.Set("scope", "openid email profile")
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", CodeChallenge(codeVerifier))
+	query.Set("code_challenge_method", "S256")
+
+	return discovery.AuthorizationEndpoint + "?" + query.Encode(), nil
+}
+
+// Exchanges the authorization code and returns the validated id token claims
+func (p *OIDCProvider) Exchange(code string, codeVerifier string, nonce string) (*OIDCClaims, error) {
+	discovery, err := p.load(false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("client_secret", p.ClientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	res, err := oidcClient.PostForm(discovery.TokenEndpoint, form)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token exchange failed with status %d", res.StatusCode)
+	}
+
+	tokens := struct {
+		IDToken string `json:"id_token"`
+	}{}
+
+	if err := json.NewDecoder(res.Body).Decode(&tokens); err != nil {
+		return nil, err
+	}
+
+	if len(tokens.IDToken) == 0 {
+		return nil, apperrors.Unauthorized("identity provider didnt return an id token")
+	}
+
+	return p.VerifyIDToken(tokens.IDToken, nonce)
+}
+
+func (p *OIDCProvider) VerifyIDToken(rawIDToken string, nonce string) (*OIDCClaims, error) {
+	claims := &OIDCClaims{}
+
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, p.keyFunc,
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithIssuedAt(),
+	)
+
+	if err != nil {
+		return nil, apperrors.Unauthorized("invalid id token: " + err.Error())
+	}
+
+	if claims.ExpiresAt == nil || len(claims.Subject) == 0 {
+		return nil, apperrors.Unauthorized("invalid id token")
+	}
+
+	if len(nonce) == 0 || claims.Nonce != nonce {
+		return nil, apperrors.Unauthorized("invalid id token nonce")
+	}
+
+	return claims, nil
+}
+
+func (p *OIDCProvider) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	p.mu.Unlock()
+
+	if ok {
+		return key, nil
+	}
+
+	// the provider may have rotated its keys since they were cached
+	if _, err := p.load(true); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	return nil, apperrors.Unauthorized("unknown id token signing key")
+}
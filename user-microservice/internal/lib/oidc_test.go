@@ -0,0 +1,33 @@
+package lib
+
+import "testing"
+
+// The cookie keeps a binding of the state, not the state itself, and another
+// state never matches it
+func TestStateBinding(t *testing.T) {
+	state, err := RandomURLSafeString(32)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	other, err := RandomURLSafeString(32)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	binding := StateBinding(state)
+
+	if binding != StateBinding(state) {
+		t.Fatal("the binding of a state changed between two calls")
+	}
+
+	if binding == state || binding == CodeChallenge(state) {
+		t.Fatal("the binding reveals the state or its code challenge")
+	}
+
+	if binding == StateBinding(other) {
+		t.Fatal("two states have the same binding")
+	}
+}
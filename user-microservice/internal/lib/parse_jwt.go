@@ -1,7 +1,7 @@
 package lib
 
 import (
-	"errors"
+	"music-sharing/shared/apperrors"
 	"os"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -15,12 +15,12 @@ func ParseJWT(tokenString string) (jwt.MapClaims, error) {
 		return []byte(os.Getenv("JWT_SECRET")), nil
 	})
 
-	if !token.Valid {
-		return nil, errors.New("invalid token")
+	if err != nil {
+		return nil, apperrors.Unauthorized(err.Error())
 	}
 
-	if err != nil {
-		return nil, err
+	if !token.Valid {
+		return nil, apperrors.Unauthorized("invalid token")
 	}
 
 	return claims, nil
@@ -0,0 +1,54 @@
+package lib
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(codes) != 10 {
+		t.Fatalf("generated %d codes, want 10", len(codes))
+	}
+
+	// no 0, o, 1, i or l, they are easily misread
+	format := regexp.MustCompile(`^[abcdefghjkmnpqrstuvwxyz23456789]{5}-[abcdefghjkmnpqrstuvwxyz23456789]{5}$`)
+	seen := map[string]bool{}
+
+	for _, code := range codes {
+		if !format.MatchString(code) {
+			t.Fatalf("code %q is not formatted as xxxxx-xxxxx", code)
+		}
+
+		if seen[code] {
+			t.Fatalf("code %q was generated twice", code)
+		}
+
+		seen[code] = true
+	}
+}
+
+// The codes are typed by hand, the case, the dash and the spaces around
+// them don't matter
+func TestHashRecoveryCodeNormalizesTheCode(t *testing.T) {
+	hash := HashRecoveryCode("abcde-fghjk")
+
+	for _, typed := range []string{"ABCDE-FGHJK", "abcdefghjk", " abcde-fghjk\n"} {
+		if HashRecoveryCode(typed) != hash {
+			t.Fatalf("%q hashes differently from abcde-fghjk", typed)
+		}
+	}
+
+	if HashRecoveryCode("abcde-fghjm") == hash {
+		t.Fatal("two different codes have the same hash")
+	}
+
+	if hash == "abcde-fghjk" || len(hash) != 64 {
+		t.Fatalf("hash = %q, want a sha256 hex digest", hash)
+	}
+}
@@ -0,0 +1,80 @@
+package lib
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"music-sharing/shared/apperrors"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	EmailVerificationPurpose = "email_verification"
+	PasswordResetPurpose     = "password_reset"
+	PasswordChangePurpose    = "password_change"
+	EmailChangePurpose       = "email_change"
+	MFAPurpose               = "mfa"
+	AccountDeletionPurpose   = "account_deletion"
+	StreamTicketPurpose      = "stream_ticket"
+)
+
+// Every purpose gets its own signing key derived from JWT_SECRET, so a token
+// minted for one flow can never be used as a session token or for another flow
+func purposeKey(purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("JWT_SECRET")))
+	mac.Write([]byte(purpose))
+
+	return mac.Sum(nil)
+}
+
+func CreateSignedToken(purpose string, data jwt.MapClaims, ttl time.Duration) (string, error) {
+
+	claims := jwt.MapClaims{}
+
+	for key, value := range data {
+		claims[key] = value
+	}
+
+	now := time.Now()
+	claims["purpose"] = purpose
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(ttl).Unix()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+
+	return token.SignedString(purposeKey(purpose))
+}
+
+func ParseSignedToken(purpose string, tokenString string) (jwt.MapClaims, error) {
+
+	claims := jwt.MapClaims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+		return purposeKey(purpose), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+
+	if err != nil {
+		return nil, apperrors.Validation("invalid or expired token")
+	}
+
+	if _, hasExpiry := claims["exp"]; !hasExpiry {
+		return nil, apperrors.Validation("invalid or expired token")
+	}
+
+	if !token.Valid || claims["purpose"] != purpose {
+		return nil, apperrors.Validation("invalid or expired token")
+	}
+
+	return claims, nil
+}
+
+// Short fingerprint of a secret value, used to bind tokens to the current
+// password hash so they stop working once the password changes
+func Fingerprint(value string) string {
+	sum := sha256.Sum256([]byte(value))
+
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,123 @@
+package lib
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// base32 of the "12345678901234567890" secret of the RFC 6238 test vectors
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// The SHA1 vectors of RFC 6238 appendix B, truncated to 6 digits
+func TestTOTPCodeMatchesTheRFCVectors(t *testing.T) {
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, vector := range vectors {
+		code, err := totpCode(rfcSecret, TOTPStep(time.Unix(vector.unix, 0)))
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if code != vector.code {
+			t.Fatalf("code at %d = %s, want %s", vector.unix, code, vector.code)
+		}
+	}
+}
+
+func TestValidateTOTPAcceptsOneStepOfSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := TOTPStep(now)
+
+	for _, offset := range []int64{-1, 0, 1} {
+		code, _ := totpCode(rfcSecret, current+offset)
+		step, ok := ValidateTOTP(rfcSecret, code, now)
+
+		if !ok {
+			t.Fatalf("the code of step %+d was refused", offset)
+		}
+
+		// the step is stored to refuse the code being replayed
+		if step != current+offset {
+			t.Fatalf("matched step %d, want %d", step, current+offset)
+		}
+	}
+
+	for _, offset := range []int64{-2, 2} {
+		code, _ := totpCode(rfcSecret, current+offset)
+
+		if _, ok := ValidateTOTP(rfcSecret, code, now); ok {
+			t.Fatalf("the code of step %+d was accepted", offset)
+		}
+	}
+}
+
+func TestValidateTOTPRefusesInvalidInput(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	cases := map[string]struct {
+		secret string
+		code   string
+	}{
+		"wrong code":     {rfcSecret, "000000"},
+		"empty code":     {rfcSecret, ""},
+		"longer code":    {rfcSecret, "0504710"},
+		"invalid secret": {"not base32!", "050471"},
+	}
+
+	for name, tc := range cases {
+		if _, ok := ValidateTOTP(tc.secret, tc.code, now); ok {
+			t.Fatalf("%s was accepted", name)
+		}
+	}
+}
+
+func TestGeneratedSecretsValidate(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// authenticator apps take the secret in lower case too
+	now := time.Now()
+	code, err := totpCode(strings.ToLower(secret), TOTPStep(now))
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, ok := ValidateTOTP(secret, code, now); !ok {
+		t.Fatal("the code of a generated secret was refused")
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri, err := url.Parse(TOTPProvisioningURI("Music Sharing", "jane@example.com", rfcSecret))
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Music Sharing:jane@example.com" {
+		t.Fatalf("uri = %s, want an otpauth totp uri labelled with the issuer and account", uri)
+	}
+
+	query := uri.Query()
+
+	if query.Get("secret") != rfcSecret || query.Get("issuer") != "Music Sharing" || query.Get("digits") != "6" || query.Get("period") != "30" {
+		t.Fatalf("query = %v", query)
+	}
+}
@@ -2,7 +2,9 @@ package config
 
 import (
 	"music-sharing/user-microservice/internal/infrastructure"
+	"music-sharing/user-microservice/internal/interfaces"
 	"sync"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -17,6 +19,10 @@ type (
 		CommmandBus *infrastructure.CommandBus
 		QueryBus    *infrastructure.QueryBus
 		Database    *gorm.DB
+		Broker      interfaces.MessageBroker
+		Mailer      interfaces.Mailer
+		OutboxRelay *infrastructure.OutboxRelay
+		Hub         interfaces.NotificationHub
 	}
 )
 
@@ -28,6 +34,10 @@ func Initialize() error {
 		Container.CommmandBus = infrastructure.GetCommandBus()
 		Container.QueryBus = infrastructure.GetQueryBus()
 		Container.Database = infrastructure.GetDB()
+		Container.Broker = infrastructure.GetBroker()
+		Container.Mailer = infrastructure.GetMailer()
+		Container.OutboxRelay = infrastructure.NewOutboxRelay(Container.Database, Container.Broker, 2*time.Second)
+		Container.Hub = infrastructure.GetNotificationHub()
 	})
 
 	return nil